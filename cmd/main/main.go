@@ -9,6 +9,8 @@ import (
 	"syscall"
 	"time"
 
+	"amg/internal/db"
+	"amg/internal/scheduler/schedulerimpl"
 	"amg/internal/server"
 
 	"go.uber.org/zap"
@@ -21,6 +23,12 @@ func main() {
 
 	s := server.NewServer(cfg)
 
+	runner := schedulerimpl.NewRunner(&db.SqlxDB{DB: cfg.DB}, cfg)
+	if cfg.Scheduler.Enabled {
+		runner.Start()
+		cfg.Logger.Info("Scheduler started", zap.Duration("poll_interval", cfg.Scheduler.PollInterval))
+	}
+
 	go func() {
 		err := s.Start()
 		if err != nil {
@@ -35,10 +43,15 @@ func main() {
 	<-stop
 	log.Println("Shutting down server...")
 
-	_, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err := s.Stop()
+	err := runner.Stop(ctx)
+	if err != nil {
+		log.Printf("Scheduler did not stop in time: %v", err)
+	}
+
+	err = s.Stop()
 	if err != nil {
 		log.Fatalf("Server failed to shutdown: %v", err)
 	}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"amg/internal/logger"
+	"amg/internal/mailer"
+	"amg/internal/storage"
+	util "amg/pkg/util/password"
 	"context"
 	"fmt"
 	"log"
@@ -19,8 +22,17 @@ type Config struct {
 	Logger      *logger.Logger
 	DB          *sqlx.DB
 	Pagination  PaginationConfig
-	JwtSecret   string
-	RedisClient *redis.Client
+	Scheduler   SchedulerConfig
+	Attachments AttachmentConfig
+	Auth        AuthConfig
+	Mail        MailConfig
+	OIDC        OIDCConfig
+	// PasswordPolicy applies whenever a password is set
+	PasswordPolicy *util.Policy
+	JwtSecret      string
+	RedisClient    *redis.Client
+	Blob           storage.Blob
+	Mailer         mailer.Sender
 }
 
 func getPort() string {
@@ -46,12 +58,10 @@ func getDatabaseUrl() string {
 	return dbUrl
 }
 
+// getJwtSecret returns the HS256 secret, only required when no signing key file is
+// configured (see LoadAuthConfig)
 func getJwtSecret() string {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatalf("JWT_SECRET is not set in the environment")
-	}
-	return jwtSecret
+	return os.Getenv("JWT_SECRET")
 }
 
 func Load() *Config {
@@ -99,5 +109,28 @@ func Load() *Config {
 	// Apply pagination config
 	cfg.LoadPaginationConfig()
 
+	// Apply token lifetimes
+	cfg.LoadAuthConfig()
+
+	// Apply single sign-on config
+	cfg.LoadOIDCConfig()
+
+	// Apply password policy
+	cfg.LoadPasswordPolicy()
+
+	// Apply mail config and initialize the mailer
+	cfg.LoadMailConfig()
+
+	// Apply scheduler config
+	cfg.LoadSchedulerConfig()
+
+	// Apply attachment config and initialize blob storage
+	cfg.LoadAttachmentConfig()
+
+	cfg.Blob, err = storage.NewLocal(cfg.Attachments.Dir)
+	if err != nil {
+		log.Fatalf("Error initializing attachment storage: %v\n", err)
+	}
+
 	return cfg
 }
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultAttachmentDir     = "./data/attachments"
+	DefaultAttachmentMaxSize = 10 << 20 // 10 MiB
+)
+
+var DefaultAttachmentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"application/pdf",
+	"text/plain",
+	"text/csv",
+	"application/vnd.ms-excel",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+type AttachmentConfig struct {
+	Dir          string
+	MaxSize      int64
+	AllowedTypes map[string]bool
+}
+
+func (cfg *Config) LoadAttachmentConfig() {
+	dir := os.Getenv("ATTACHMENT_DIR")
+	if dir == "" {
+		dir = DefaultAttachmentDir
+	}
+	cfg.Attachments.Dir = dir
+
+	maxSize, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_SIZE"), 10, 64)
+	if err != nil || maxSize <= 0 {
+		maxSize = DefaultAttachmentMaxSize
+	}
+	cfg.Attachments.MaxSize = maxSize
+
+	types := DefaultAttachmentTypes
+	if env := os.Getenv("ATTACHMENT_ALLOWED_TYPES"); env != "" {
+		types = strings.Split(env, ",")
+	}
+
+	cfg.Attachments.AllowedTypes = make(map[string]bool, len(types))
+	for _, t := range types {
+		cfg.Attachments.AllowedTypes[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+}
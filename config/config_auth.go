@@ -0,0 +1,161 @@
+package config
+
+import (
+	"amg/pkg/util/jwt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	DefaultTokenIssuer   = "amg"
+	DefaultTokenAudience = "amg-api"
+
+	DefaultMaxLoginAttempts   = 5
+	DefaultLockoutDuration    = 15 * time.Minute
+	DefaultLoginFailureWindow = time.Hour
+	DefaultLoginBackoffBase   = time.Second
+	DefaultLoginBackoffMax    = 5 * time.Minute
+
+	DefaultMFAIssuer       = "AMG"
+	DefaultMFAChallengeTTL = 5 * time.Minute
+
+	DefaultPermissionCacheTTL = 10 * time.Minute
+
+	DefaultRegistrationOrganization = "default"
+	DefaultSystemOrganization       = "default"
+)
+
+type AuthConfig struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Keys signs and verifies access tokens
+	Keys *jwt.KeySet
+
+	// MaxLoginAttempts failed logins for one email within LoginFailureWindow
+	// lock the account for LockoutDuration
+	MaxLoginAttempts   int
+	LockoutDuration    time.Duration
+	LoginFailureWindow time.Duration
+
+	// After each failed login, the email and the client IP have to wait
+	// LoginBackoffBase, doubled per failure up to LoginBackoffMax
+	LoginBackoffBase time.Duration
+	LoginBackoffMax  time.Duration
+
+	// MFAIssuer names the account in authenticator apps; MFAChallengeTTL is how
+	// long a user has to enter their code after the password step of a login
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+
+	// PermissionCacheTTL bounds how long the permissions of a role stay cached.
+	// Changes made through the API drop the cache right away.
+	PermissionCacheTTL time.Duration
+
+	// RegistrationOrganization is the slug of the organization self-registered
+	// and single sign-on users join
+	RegistrationOrganization string
+
+	// SystemOrganization is the slug of the organization whose admins change
+	// what all organizations share: roles, permissions, report templates and the
+	// MFA policy
+	SystemOrganization string
+}
+
+func (cfg *Config) LoadAuthConfig() {
+	cfg.Auth.AccessTokenTTL = durationEnv("ACCESS_TOKEN_TTL", DefaultAccessTokenTTL)
+	cfg.Auth.RefreshTokenTTL = durationEnv("REFRESH_TOKEN_TTL", DefaultRefreshTokenTTL)
+
+	keys, err := loadKeySet(cfg.JwtSecret)
+	if err != nil {
+		log.Fatalf("Error loading JWT keys: %v\n", err)
+	}
+	keys.Issuer = stringEnv("JWT_ISSUER", DefaultTokenIssuer)
+	keys.Audience = stringEnv("JWT_AUDIENCE", DefaultTokenAudience)
+	cfg.Auth.Keys = keys
+
+	cfg.Auth.MaxLoginAttempts = intEnv("LOGIN_MAX_ATTEMPTS", DefaultMaxLoginAttempts)
+	cfg.Auth.LockoutDuration = durationEnv("LOGIN_LOCKOUT_DURATION", DefaultLockoutDuration)
+	cfg.Auth.LoginFailureWindow = durationEnv("LOGIN_FAILURE_WINDOW", DefaultLoginFailureWindow)
+	cfg.Auth.LoginBackoffBase = durationEnv("LOGIN_BACKOFF_BASE", DefaultLoginBackoffBase)
+	cfg.Auth.LoginBackoffMax = durationEnv("LOGIN_BACKOFF_MAX", DefaultLoginBackoffMax)
+
+	cfg.Auth.MFAIssuer = stringEnv("MFA_ISSUER", DefaultMFAIssuer)
+	cfg.Auth.MFAChallengeTTL = durationEnv("MFA_CHALLENGE_TTL", DefaultMFAChallengeTTL)
+
+	cfg.Auth.PermissionCacheTTL = durationEnv("PERMISSION_CACHE_TTL", DefaultPermissionCacheTTL)
+
+	cfg.Auth.RegistrationOrganization = stringEnv("REGISTRATION_ORGANIZATION", DefaultRegistrationOrganization)
+	cfg.Auth.SystemOrganization = stringEnv("SYSTEM_ORGANIZATION", DefaultSystemOrganization)
+}
+
+// loadKeySet signs with the RSA or Ed25519 private key in JWT_SIGNING_KEY_FILE and
+// also accepts tokens signed by the keys in JWT_VERIFICATION_KEY_FILES, a comma
+// separated list of PEM files. To rotate keys, add the new key as signing key and
+// keep the old one as a verification key until its tokens have expired. Without
+// a signing key, tokens are signed with JWT_SECRET using HS256.
+func loadKeySet(secret string) (*jwt.KeySet, error) {
+	path := os.Getenv("JWT_SIGNING_KEY_FILE")
+	if path == "" {
+		if secret == "" {
+			log.Fatalf("Either JWT_SIGNING_KEY_FILE or JWT_SECRET must be set in the environment")
+		}
+		return jwt.NewHMACKeySet(secret), nil
+	}
+
+	signing, err := jwt.LoadKeyFile(path, os.Getenv("JWT_SIGNING_KEY_ID"))
+	if err != nil {
+		return nil, err
+	}
+
+	verify := make([]*jwt.Key, 0)
+	for _, file := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+
+		key, err := jwt.LoadKeyFile(file, "")
+		if err != nil {
+			return nil, err
+		}
+		verify = append(verify, key)
+	}
+
+	return jwt.NewKeySet(signing, verify...)
+}
+
+// durationEnv parses a duration such as "15m" from the environment, falling back
+// to def when it is unset or not a positive duration
+func durationEnv(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return def
+	}
+
+	return d
+}
+
+// intEnv parses a positive integer from the environment, falling back to def
+func intEnv(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n <= 0 {
+		return def
+	}
+
+	return n
+}
+
+func stringEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return def
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"amg/internal/mailer"
+	"os"
+	"time"
+)
+
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverLog  = "log"
+
+	DefaultMailFrom       = "amg <no-reply@localhost>"
+	DefaultAppURL         = "http://localhost:8000"
+	DefaultResetTokenTTL  = time.Hour
+	DefaultVerifyTokenTTL = 48 * time.Hour
+	DefaultInvitationTTL  = 7 * 24 * time.Hour
+)
+
+type MailConfig struct {
+	Driver string
+	From   string
+
+	// AppURL is the base of the links put into emails
+	AppURL string
+
+	ResetTokenTTL  time.Duration
+	VerifyTokenTTL time.Duration
+
+	// InvitationTTL is how long an invitation link can be accepted; resending
+	// the invitation starts it over
+	InvitationTTL time.Duration
+
+	// RequireVerifiedEmail refuses logins until the email address is verified
+	RequireVerifiedEmail bool
+}
+
+func (cfg *Config) LoadMailConfig() {
+	cfg.Mail.Driver = stringEnv("MAIL_DRIVER", MailDriverLog)
+	cfg.Mail.From = stringEnv("MAIL_FROM", DefaultMailFrom)
+	cfg.Mail.AppURL = stringEnv("APP_URL", DefaultAppURL)
+	cfg.Mail.ResetTokenTTL = durationEnv("PASSWORD_RESET_TOKEN_TTL", DefaultResetTokenTTL)
+	cfg.Mail.VerifyTokenTTL = durationEnv("EMAIL_VERIFICATION_TOKEN_TTL", DefaultVerifyTokenTTL)
+	cfg.Mail.InvitationTTL = durationEnv("INVITATION_TTL", DefaultInvitationTTL)
+	cfg.Mail.RequireVerifiedEmail = os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+
+	// Without SMTP settings mail is only logged, which is enough for local development
+	switch cfg.Mail.Driver {
+	case MailDriverSMTP:
+		cfg.Mailer = mailer.NewSMTP(
+			os.Getenv("SMTP_HOST"),
+			stringEnv("SMTP_PORT", "587"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			cfg.Mail.From,
+		)
+	default:
+		cfg.Mailer = mailer.NewLog()
+	}
+}
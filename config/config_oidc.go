@@ -0,0 +1,95 @@
+package config
+
+import (
+	"amg/pkg/util/oidc"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultOIDCScopes      = "openid email profile"
+	DefaultOIDCDefaultRole = "user"
+	DefaultOIDCStateTTL    = 10 * time.Minute
+)
+
+// OIDCRoleMapping assigns Role to users whose role claim contains Value
+type OIDCRoleMapping struct {
+	Value string
+	Role  string
+}
+
+type OIDCConfig struct {
+	// Provider is nil when single sign-on is not configured
+	Provider *oidc.Provider
+
+	// RoleClaim is the ID token claim holding the groups or roles of the user,
+	// a dotted path such as realm_access.roles for nested claims. RoleMappings
+	// are tried in order and the first one matching decides the role.
+	RoleClaim    string
+	RoleMappings []OIDCRoleMapping
+
+	// DefaultRole is given to provisioned users no mapping matches
+	DefaultRole string
+
+	// AutoProvision creates accounts for unknown users; otherwise only users
+	// whose verified email already has an account can log in
+	AutoProvision bool
+
+	// StateTTL bounds the time between starting the login and the callback
+	StateTTL time.Duration
+}
+
+// LoadOIDCConfig enables single sign-on when OIDC_ISSUER_URL is set:
+//
+//	OIDC_ISSUER_URL     issuer identifier, e.g. http://localhost:8080/default for a local mock issuer
+//	OIDC_CLIENT_ID      client registered at the issuer
+//	OIDC_CLIENT_SECRET  secret of a confidential client, empty for public clients
+//	OIDC_REDIRECT_URL   callback page of the frontend registered at the issuer
+//	OIDC_SCOPES         space separated, defaults to "openid email profile"
+//	OIDC_ROLE_CLAIM     claim the role mapping looks at
+//	OIDC_ROLE_MAPPING   comma separated value=role pairs, e.g. amg-admins=admin,amg-reviewers=reviewer
+//	OIDC_DEFAULT_ROLE   role of provisioned users without a matching value, defaults to user
+//	OIDC_AUTO_PROVISION "false" to only link existing accounts
+func (cfg *Config) LoadOIDCConfig() {
+	cfg.OIDC.RoleClaim = os.Getenv("OIDC_ROLE_CLAIM")
+	cfg.OIDC.DefaultRole = stringEnv("OIDC_DEFAULT_ROLE", DefaultOIDCDefaultRole)
+	cfg.OIDC.AutoProvision = os.Getenv("OIDC_AUTO_PROVISION") != "false"
+	cfg.OIDC.StateTTL = durationEnv("OIDC_STATE_TTL", DefaultOIDCStateTTL)
+
+	for _, pair := range strings.Split(os.Getenv("OIDC_ROLE_MAPPING"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		value, role, ok := strings.Cut(pair, "=")
+		if !ok || value == "" || role == "" {
+			log.Fatalf("OIDC_ROLE_MAPPING: expected value=role, got %q\n", pair)
+		}
+
+		cfg.OIDC.RoleMappings = append(cfg.OIDC.RoleMappings, OIDCRoleMapping{
+			Value: strings.TrimSpace(value),
+			Role:  strings.TrimSpace(role),
+		})
+	}
+
+	issuer := os.Getenv("OIDC_ISSUER_URL")
+	if issuer == "" {
+		return
+	}
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		IssuerURL:    issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(stringEnv("OIDC_SCOPES", DefaultOIDCScopes)),
+	})
+	if err != nil {
+		log.Fatalf("Error configuring OIDC: %v\n", err)
+	}
+
+	cfg.OIDC.Provider = provider
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	util "amg/pkg/util/password"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadPasswordPolicy builds the password policy from the defaults of
+// util.DefaultPolicy and these overrides:
+//
+//	PASSWORD_MIN_LENGTH       minimum number of characters
+//	PASSWORD_REQUIRED_CLASSES comma separated classes: lower, upper, letter, digit, symbol
+//	PASSWORD_DENYLIST_FILE    file with one refused password per line
+//	PASSWORD_HISTORY_DEPTH    number of recent passwords that may not be reused
+func (cfg *Config) LoadPasswordPolicy() {
+	policy := util.DefaultPolicy()
+
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && n > 0 {
+		policy.MinLength = n
+	}
+
+	if env, ok := os.LookupEnv("PASSWORD_REQUIRED_CLASSES"); ok {
+		policy.Classes = make([]string, 0)
+		for _, class := range strings.Split(env, ",") {
+			class = strings.ToLower(strings.TrimSpace(class))
+			if class == "" {
+				continue
+			}
+			if !util.IsValidClass(class) {
+				log.Fatalf("PASSWORD_REQUIRED_CLASSES: unknown class %q\n", class)
+			}
+			policy.Classes = append(policy.Classes, class)
+		}
+	}
+
+	if path := os.Getenv("PASSWORD_DENYLIST_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Error opening password denylist: %v\n", err)
+		}
+		defer file.Close()
+
+		err = policy.LoadDenylist(file)
+		if err != nil {
+			log.Fatalf("Error reading password denylist: %v\n", err)
+		}
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY_DEPTH")); err == nil && n >= 0 {
+		policy.HistoryDepth = n
+	}
+
+	cfg.PasswordPolicy = policy
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	DefaultSchedulerPollInterval = time.Minute
+)
+
+type SchedulerConfig struct {
+	Enabled      bool
+	PollInterval time.Duration
+}
+
+func (cfg *Config) LoadSchedulerConfig() {
+	// The scheduler runs unless explicitly disabled, e.g. on extra API replicas
+	cfg.Scheduler.Enabled = os.Getenv("SCHEDULER_ENABLED") != "false"
+
+	interval, err := time.ParseDuration(os.Getenv("SCHEDULER_POLL_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = DefaultSchedulerPollInterval
+	}
+	cfg.Scheduler.PollInterval = interval
+}
@@ -0,0 +1,30 @@
+package accesscontrol
+
+import "context"
+
+type Service interface {
+	// HasPermission reports whether role is granted permission. Lookups are
+	// cached and the cache of a role is dropped whenever its permissions change.
+	HasPermission(ctx context.Context, role, permission string) (bool, error)
+	RoleExists(ctx context.Context, role string) (bool, error)
+
+	// Authorize checks an action on a single resource: the role of the subject
+	// must be granted action and one of the policies must allow it. Denials are
+	// logged and returned as ErrAccessDenied.
+	Authorize(ctx context.Context, subject *Subject, action string, resource *Resource) error
+
+	CreateRole(ctx context.Context, cmd *CreateRoleCommand) error
+	UpdateRole(ctx context.Context, cmd *UpdateRoleCommand) error
+	GetRole(ctx context.Context, name string) (*Role, error)
+	GetRoles(ctx context.Context) ([]*Role, error)
+	DeleteRole(ctx context.Context, name string) error
+
+	// SetRolePermissions replaces every permission of a role
+	SetRolePermissions(ctx context.Context, cmd *SetRolePermissionsCommand) error
+	GrantPermission(ctx context.Context, role, permission string) error
+	RevokePermission(ctx context.Context, role, permission string) error
+
+	CreatePermission(ctx context.Context, cmd *CreatePermissionCommand) error
+	GetPermissions(ctx context.Context) ([]*Permission, error)
+	DeletePermission(ctx context.Context, name string) error
+}
@@ -0,0 +1,371 @@
+package accesscontrolimpl
+
+import (
+	"amg/config"
+	"amg/internal/db"
+	"amg/internal/identity/accesscontrol"
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// roleCachePrefix keys the cached permissions of a role, as a JSON array
+const roleCachePrefix = "rbac:role:"
+
+type service struct {
+	store       *store
+	cfg         *config.Config
+	log         *zap.Logger
+	db          db.DB
+	redisClient *redis.Client
+	policies    []*accesscontrol.Policy
+}
+
+func NewService(db db.DB, cfg *config.Config) *service {
+	return &service{
+		store:       NewStore(db),
+		cfg:         cfg,
+		db:          db,
+		redisClient: cfg.RedisClient,
+		policies:    accesscontrol.DefaultPolicies,
+		log:         zap.L().Named("accesscontrol.service"),
+	}
+}
+
+func (s *service) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	permissions, err := s.rolePermissions(ctx, role)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// rolePermissions reads the permissions of role through the cache. Redis being
+// unavailable only costs a database query.
+func (s *service) rolePermissions(ctx context.Context, role string) ([]string, error) {
+	key := roleCachePrefix + role
+
+	cached, err := s.redisClient.Get(ctx, key).Bytes()
+	if err == nil {
+		var permissions []string
+		if err := json.Unmarshal(cached, &permissions); err == nil {
+			return permissions, nil
+		}
+	} else if err != redis.Nil {
+		s.log.Warn("failed to read cached permissions", zap.String("role", role), zap.Error(err))
+	}
+
+	permissions, err := s.store.getRolePermissions(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(permissions)
+	if err == nil {
+		err = s.redisClient.Set(ctx, key, value, s.cfg.Auth.PermissionCacheTTL).Err()
+	}
+	if err != nil {
+		s.log.Warn("failed to cache permissions", zap.String("role", role), zap.Error(err))
+	}
+
+	return permissions, nil
+}
+
+// invalidate drops the cached permissions of roles. A failure is logged only:
+// the cache then expires after PermissionCacheTTL.
+func (s *service) invalidate(ctx context.Context, roles ...string) {
+	if len(roles) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(roles))
+	for _, role := range roles {
+		keys = append(keys, roleCachePrefix+role)
+	}
+
+	err := s.redisClient.Del(ctx, keys...).Err()
+	if err != nil {
+		s.log.Error("failed to invalidate cached permissions", zap.Strings("roles", roles), zap.Error(err))
+	}
+}
+
+func (s *service) RoleExists(ctx context.Context, role string) (bool, error) {
+	result, err := s.store.getRole(ctx, role)
+	if err != nil {
+		return false, err
+	}
+
+	return result != nil, nil
+}
+
+// checkPermissions fails unless every one of permissions exists
+func (s *service) checkPermissions(ctx context.Context, permissions []string) error {
+	missing, err := s.store.missingPermissions(ctx, permissions)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return accesscontrol.ErrPermissionNotFound
+	}
+
+	return nil
+}
+
+func (s *service) CreateRole(ctx context.Context, cmd *accesscontrol.CreateRoleCommand) error {
+	exists, err := s.RoleExists(ctx, cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return accesscontrol.ErrRoleAlreadyExists
+	}
+
+	err = s.checkPermissions(ctx, cmd.Permissions)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.createRole(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	// A lookup made before the role existed may have cached no permissions
+	s.invalidate(ctx, cmd.Name)
+
+	s.log.Info("role created", zap.String("role", cmd.Name), zap.Strings("permissions", cmd.Permissions))
+
+	return nil
+}
+
+func (s *service) UpdateRole(ctx context.Context, cmd *accesscontrol.UpdateRoleCommand) error {
+	updated, err := s.store.updateRole(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return accesscontrol.ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func (s *service) GetRole(ctx context.Context, name string) (*accesscontrol.Role, error) {
+	result, err := s.store.getRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, accesscontrol.ErrRoleNotFound
+	}
+
+	result.Permissions, err = s.store.getRolePermissions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *service) GetRoles(ctx context.Context) ([]*accesscontrol.Role, error) {
+	result, err := s.store.getRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.store.getAllRolePermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range result {
+		role.Permissions = permissions[role.Name]
+		if role.Permissions == nil {
+			role.Permissions = make([]string, 0)
+		}
+	}
+
+	return result, nil
+}
+
+// DeleteRole deletes a role no user holds. Built-in roles cannot be deleted.
+func (s *service) DeleteRole(ctx context.Context, name string) error {
+	role, err := s.store.getRole(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if role == nil {
+		return accesscontrol.ErrRoleNotFound
+	}
+
+	if role.Builtin {
+		return accesscontrol.ErrBuiltinRole
+	}
+
+	inUse, err := s.store.roleInUse(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if inUse {
+		return accesscontrol.ErrRoleInUse
+	}
+
+	deleted, err := s.store.deleteRole(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return accesscontrol.ErrRoleNotFound
+	}
+
+	s.invalidate(ctx, name)
+
+	s.log.Info("role deleted", zap.String("role", name))
+
+	return nil
+}
+
+func (s *service) SetRolePermissions(ctx context.Context, cmd *accesscontrol.SetRolePermissionsCommand) error {
+	exists, err := s.RoleExists(ctx, cmd.Role)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return accesscontrol.ErrRoleNotFound
+	}
+
+	if cmd.Role == accesscontrol.RoleAdmin && !slices.Contains(cmd.Permissions, accesscontrol.PermissionManageRoles) {
+		return accesscontrol.ErrAdminLockout
+	}
+
+	err = s.checkPermissions(ctx, cmd.Permissions)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.setRolePermissions(ctx, cmd.Role, cmd.Permissions)
+	if err != nil {
+		return err
+	}
+
+	s.invalidate(ctx, cmd.Role)
+
+	s.log.Info("role permissions replaced", zap.String("role", cmd.Role), zap.Strings("permissions", cmd.Permissions))
+
+	return nil
+}
+
+func (s *service) GrantPermission(ctx context.Context, role, permission string) error {
+	exists, err := s.RoleExists(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return accesscontrol.ErrRoleNotFound
+	}
+
+	err = s.checkPermissions(ctx, []string{permission})
+	if err != nil {
+		return err
+	}
+
+	err = s.store.grantPermission(ctx, role, permission)
+	if err != nil {
+		return err
+	}
+
+	s.invalidate(ctx, role)
+
+	s.log.Info("permission granted", zap.String("role", role), zap.String("permission", permission))
+
+	return nil
+}
+
+func (s *service) RevokePermission(ctx context.Context, role, permission string) error {
+	if role == accesscontrol.RoleAdmin && permission == accesscontrol.PermissionManageRoles {
+		return accesscontrol.ErrAdminLockout
+	}
+
+	revoked, err := s.store.revokePermission(ctx, role, permission)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return accesscontrol.ErrPermissionNotFound
+	}
+
+	s.invalidate(ctx, role)
+
+	s.log.Info("permission revoked", zap.String("role", role), zap.String("permission", permission))
+
+	return nil
+}
+
+func (s *service) CreatePermission(ctx context.Context, cmd *accesscontrol.CreatePermissionCommand) error {
+	taken, err := s.store.getPermission(ctx, cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	if taken != nil {
+		return accesscontrol.ErrPermissionExists
+	}
+
+	return s.store.createPermission(ctx, cmd)
+}
+
+func (s *service) GetPermissions(ctx context.Context) ([]*accesscontrol.Permission, error) {
+	return s.store.getPermissions(ctx)
+}
+
+// DeletePermission deletes a permission that is not built in, revoking it from
+// every role
+func (s *service) DeletePermission(ctx context.Context, name string) error {
+	permission, err := s.store.getPermission(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if permission == nil {
+		return accesscontrol.ErrPermissionNotFound
+	}
+
+	if permission.Builtin {
+		return accesscontrol.ErrBuiltinPermission
+	}
+
+	deleted, roles, err := s.store.deletePermission(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return accesscontrol.ErrPermissionNotFound
+	}
+
+	s.invalidate(ctx, roles...)
+
+	s.log.Info("permission deleted", zap.String("permission", name), zap.Strings("roles", roles))
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package accesscontrolimpl
+
+import (
+	"amg/internal/identity/accesscontrol"
+	"context"
+
+	"go.uber.org/zap"
+)
+
+func (s *service) Authorize(ctx context.Context, subject *accesscontrol.Subject, action string, resource *accesscontrol.Resource) error {
+	granted, err := s.HasPermission(ctx, subject.Role, action)
+	if err != nil {
+		return err
+	}
+
+	if !granted {
+		s.deny(subject, action, resource, "role lacks permission")
+		return accesscontrol.ErrAccessDenied
+	}
+
+	for _, policy := range s.policies {
+		if policy.Matches(subject, action, resource) {
+			return nil
+		}
+	}
+
+	s.deny(subject, action, resource, "no policy allows it")
+	return accesscontrol.ErrAccessDenied
+}
+
+// deny logs a denied request with enough attributes to tell why it was refused
+func (s *service) deny(subject *accesscontrol.Subject, action string, resource *accesscontrol.Resource, reason string) {
+	s.log.Warn("access denied",
+		zap.Int64("user_id", subject.UserID),
+		zap.String("role", subject.Role),
+		zap.String("team", subject.Team),
+		zap.String("action", action),
+		zap.String("resource", resource.Type),
+		zap.Int64("resource_id", resource.ID),
+		zap.Int64("owner_id", resource.OwnerID),
+		zap.String("owner_team", resource.Team),
+		zap.String("status", resource.Status),
+		zap.String("reason", reason),
+	)
+}
@@ -0,0 +1,451 @@
+package accesscontrolimpl
+
+import (
+	"amg/internal/db"
+	"amg/internal/identity/accesscontrol"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type store struct {
+	db     db.DB
+	logger *zap.Logger
+}
+
+func NewStore(db db.DB) *store {
+	return &store{
+		db:     db,
+		logger: zap.L().Named("accesscontrol.store"),
+	}
+}
+
+func (s *store) getRole(ctx context.Context, name string) (*accesscontrol.Role, error) {
+	var result accesscontrol.Role
+
+	rawSQL := `
+	SELECT
+		name,
+		description,
+		builtin,
+		created_at,
+		updated_at
+	FROM
+		roles
+	WHERE
+		name = $1
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) getRoles(ctx context.Context) ([]*accesscontrol.Role, error) {
+	result := make([]*accesscontrol.Role, 0)
+
+	rawSQL := `
+	SELECT
+		name,
+		description,
+		builtin,
+		created_at,
+		updated_at
+	FROM
+		roles
+	ORDER BY
+		name
+	`
+
+	err := s.db.Select(ctx, &result, rawSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) getRolePermissions(ctx context.Context, role string) ([]string, error) {
+	result := make([]string, 0)
+
+	rawSQL := `
+	SELECT
+		permission
+	FROM
+		role_permissions
+	WHERE
+		role = $1
+	ORDER BY
+		permission
+	`
+
+	err := s.db.Select(ctx, &result, rawSQL, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+type rolePermission struct {
+	Role       string `db:"role"`
+	Permission string `db:"permission"`
+}
+
+// getAllRolePermissions returns the permissions of every role keyed by role
+func (s *store) getAllRolePermissions(ctx context.Context) (map[string][]string, error) {
+	rows := make([]*rolePermission, 0)
+
+	rawSQL := `
+	SELECT
+		role,
+		permission
+	FROM
+		role_permissions
+	ORDER BY
+		role,
+		permission
+	`
+
+	err := s.db.Select(ctx, &rows, rawSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string)
+	for _, row := range rows {
+		result[row.Role] = append(result[row.Role], row.Permission)
+	}
+
+	return result, nil
+}
+
+// missingPermissions returns the names that are not in the permissions table
+func (s *store) missingPermissions(ctx context.Context, names []string) ([]string, error) {
+	result := make([]string, 0)
+
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	rawSQL := `
+	SELECT
+		name
+	FROM
+		unnest($1::text[]) AS name
+	WHERE
+		name NOT IN (SELECT name FROM permissions)
+	`
+
+	err := s.db.Select(ctx, &result, rawSQL, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) createRole(ctx context.Context, cmd *accesscontrol.CreateRoleCommand) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		INSERT INTO roles (
+			name,
+			description
+		) VALUES (
+			$1, $2
+		)
+	`
+
+		_, err := tx.Exec(ctx, rawSQL, cmd.Name, cmd.Description)
+		if err != nil {
+			return err
+		}
+
+		return insertRolePermissions(ctx, tx, cmd.Name, cmd.Permissions)
+	})
+}
+
+func insertRolePermissions(ctx context.Context, tx db.Tx, role string, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	rawSQL := `
+	INSERT INTO role_permissions (
+		role,
+		permission
+	)
+	SELECT
+		$1, unnest($2::text[])
+	ON CONFLICT DO NOTHING
+	`
+
+	_, err := tx.Exec(ctx, rawSQL, role, pq.Array(permissions))
+	return err
+}
+
+func (s *store) updateRole(ctx context.Context, cmd *accesscontrol.UpdateRoleCommand) (bool, error) {
+	rawSQL := `
+	UPDATE
+		roles
+	SET
+		description = $2,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE
+		name = $1
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, cmd.Name, cmd.Description)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// roleInUse reports whether a user, a membership or a pending invitation still
+// holds the role
+func (s *store) roleInUse(ctx context.Context, name string) (bool, error) {
+	var result bool
+
+	rawSQL := `
+	SELECT EXISTS (
+		SELECT 1 FROM users WHERE role = $1
+	) OR EXISTS (
+		SELECT 1 FROM organization_members WHERE role = $1
+	) OR EXISTS (
+		SELECT 1 FROM invitations WHERE role = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+	)
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, name)
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+// deleteRole deletes a role that is not built in, with its permissions
+func (s *store) deleteRole(ctx context.Context, name string) (bool, error) {
+	rawSQL := `
+	DELETE FROM
+		roles
+	WHERE
+		name = $1 AND
+		builtin = FALSE
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, name)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *store) setRolePermissions(ctx context.Context, role string, permissions []string) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		DELETE FROM
+			role_permissions
+		WHERE
+			role = $1
+	`
+
+		_, err := tx.Exec(ctx, rawSQL, role)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		UPDATE
+			roles
+		SET
+			updated_at = CURRENT_TIMESTAMP
+		WHERE
+			name = $1
+	`
+
+		_, err = tx.Exec(ctx, rawSQL, role)
+		if err != nil {
+			return err
+		}
+
+		return insertRolePermissions(ctx, tx, role, permissions)
+	})
+}
+
+func (s *store) grantPermission(ctx context.Context, role, permission string) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		return insertRolePermissions(ctx, tx, role, []string{permission})
+	})
+}
+
+func (s *store) revokePermission(ctx context.Context, role, permission string) (bool, error) {
+	rawSQL := `
+	DELETE FROM
+		role_permissions
+	WHERE
+		role = $1 AND
+		permission = $2
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, role, permission)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *store) getPermission(ctx context.Context, name string) (*accesscontrol.Permission, error) {
+	var result accesscontrol.Permission
+
+	rawSQL := `
+	SELECT
+		name,
+		description,
+		builtin,
+		created_at
+	FROM
+		permissions
+	WHERE
+		name = $1
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) getPermissions(ctx context.Context) ([]*accesscontrol.Permission, error) {
+	result := make([]*accesscontrol.Permission, 0)
+
+	rawSQL := `
+	SELECT
+		name,
+		description,
+		builtin,
+		created_at
+	FROM
+		permissions
+	ORDER BY
+		name
+	`
+
+	err := s.db.Select(ctx, &result, rawSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) createPermission(ctx context.Context, cmd *accesscontrol.CreatePermissionCommand) error {
+	rawSQL := `
+	INSERT INTO permissions (
+		name,
+		description
+	) VALUES (
+		$1, $2
+	)
+	`
+
+	_, err := s.db.Exec(ctx, rawSQL, cmd.Name, cmd.Description)
+	return err
+}
+
+// deletePermission deletes a permission that is not built in and returns the
+// roles that held it
+func (s *store) deletePermission(ctx context.Context, name string) (bool, []string, error) {
+	var (
+		deleted bool
+		roles   []string
+	)
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		DELETE FROM
+			role_permissions
+		WHERE
+			permission = $1 AND
+			EXISTS (SELECT 1 FROM permissions WHERE name = $1 AND builtin = FALSE)
+		RETURNING
+			role
+	`
+
+		rows, err := tx.Query(ctx, rawSQL, name)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var role string
+			if err := rows.Scan(&role); err != nil {
+				return err
+			}
+			roles = append(roles, role)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rawSQL = `
+		DELETE FROM
+			permissions
+		WHERE
+			name = $1 AND
+			builtin = FALSE
+	`
+
+		result, err := tx.Exec(ctx, rawSQL, name)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = n > 0
+
+		return nil
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	return deleted, roles, nil
+}
@@ -1,29 +1,58 @@
 package accesscontrol
 
+// Built-in roles. Other roles can be created at runtime through the roles API.
 const (
-	RoleAdmin = "admin"
-	RoleUser  = "user"
+	RoleAdmin    = "admin"
+	RoleUser     = "user"
+	RoleReviewer = "reviewer"
 )
 
-// Define permissions
-var rolePermissions = map[string]map[string]bool{
-	RoleAdmin: {
-		"create": true,
-		"read":   true,
-		"update": true,
-		"delete": true,
-	},
-	RoleUser: {
-		"read": true,
-	},
-}
-
-// Check if role has permission
-func HasPermission(role, permission string) bool {
-	perms, ok := rolePermissions[role]
-	if !ok {
-		return false
-	}
-
-	return perms[permission]
-}
+// User permissions
+const (
+	PermissionCreateUser = "users:create"
+	PermissionReadUser   = "users:read"
+	PermissionUpdateUser = "users:update"
+	PermissionDeleteUser = "users:delete"
+	PermissionUnlockUser = "users:unlock"
+
+	PermissionManageSessions        = "sessions:manage"
+	PermissionManageAPIKeys         = "api_keys:manage"
+	PermissionManageServiceAccounts = "service_accounts:manage"
+	PermissionManageMFA             = "mfa:manage"
+	PermissionManageInvitations     = "invitations:manage"
+)
+
+// Role permissions
+const (
+	PermissionManageRoles = "roles:manage"
+)
+
+// Organization permissions
+const (
+	PermissionCreateOrganization = "organizations:create"
+	PermissionManageOrganization = "organizations:manage"
+)
+
+// Report permissions
+const (
+	PermissionCreateReport  = "reports:create"
+	PermissionReadReport    = "reports:read"
+	PermissionUpdateReport  = "reports:update"
+	PermissionDeleteReport  = "reports:delete"
+	PermissionSubmitReport  = "reports:submit"
+	PermissionReviewReport  = "reports:review"
+	PermissionApproveReport = "reports:approve"
+	PermissionRejectReport  = "reports:reject"
+	PermissionCommentReport = "reports:comment"
+	PermissionReportStats   = "reports:stats"
+)
+
+// Report template permissions
+const (
+	PermissionManageTemplates = "report_templates:manage"
+)
+
+// Scheduler permissions
+const (
+	PermissionManageSchedules = "schedules:manage"
+)
@@ -0,0 +1,96 @@
+package accesscontrol
+
+import (
+	"amg/internal/api/errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrRoleNotFound          = errors.New("accesscontrol.role-not-found", "Role not found")
+	ErrRoleAlreadyExists     = errors.New("accesscontrol.role-already-exists", "Role already exists")
+	ErrInvalidRoleName       = errors.New("accesscontrol.invalid-role-name", "Invalid role name")
+	ErrBuiltinRole           = errors.New("accesscontrol.builtin-role", "Built-in roles cannot be deleted")
+	ErrRoleInUse             = errors.New("accesscontrol.role-in-use", "Role is assigned to users")
+	ErrPermissionNotFound    = errors.New("accesscontrol.permission-not-found", "Permission not found")
+	ErrPermissionExists      = errors.New("accesscontrol.permission-already-exists", "Permission already exists")
+	ErrInvalidPermissionName = errors.New("accesscontrol.invalid-permission-name", "Invalid permission name, expected resource:action")
+	ErrBuiltinPermission     = errors.New("accesscontrol.builtin-permission", "Built-in permissions cannot be deleted")
+	ErrAdminLockout          = errors.New("accesscontrol.admin-lockout", "The admin role cannot lose the permission to manage roles")
+)
+
+var (
+	roleNamePattern       = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+	permissionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*:[a-z][a-z0-9_]*$`)
+)
+
+type Role struct {
+	Name        string   `db:"name" json:"name"`
+	Description string   `db:"description" json:"description"`
+	Builtin     bool     `db:"builtin" json:"builtin"`
+	Permissions []string `db:"-" json:"permissions"`
+	CreatedAt   string   `db:"created_at" json:"created_at"`
+	UpdatedAt   string   `db:"updated_at" json:"updated_at"`
+}
+
+type Permission struct {
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+	Builtin     bool   `db:"builtin" json:"builtin"`
+	CreatedAt   string `db:"created_at" json:"created_at"`
+}
+
+type CreateRoleCommand struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleCommand struct {
+	Name        string `json:"-"`
+	Description string `json:"description"`
+}
+
+type SetRolePermissionsCommand struct {
+	Role        string   `json:"-"`
+	Permissions []string `json:"permissions"`
+}
+
+type CreatePermissionCommand struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// IsValidPermission checks that name has the resource:action form
+func IsValidPermission(name string) bool {
+	return permissionNamePattern.MatchString(name)
+}
+
+func validatePermissions(permissions []string) error {
+	for _, permission := range permissions {
+		if !IsValidPermission(permission) {
+			return ErrInvalidPermissionName
+		}
+	}
+	return nil
+}
+
+func (cmd *CreateRoleCommand) Validate() error {
+	cmd.Name = strings.TrimSpace(cmd.Name)
+	if !roleNamePattern.MatchString(cmd.Name) {
+		return ErrInvalidRoleName
+	}
+	return validatePermissions(cmd.Permissions)
+}
+
+func (cmd *SetRolePermissionsCommand) Validate() error {
+	return validatePermissions(cmd.Permissions)
+}
+
+func (cmd *CreatePermissionCommand) Validate() error {
+	cmd.Name = strings.TrimSpace(cmd.Name)
+	if !IsValidPermission(cmd.Name) {
+		return ErrInvalidPermissionName
+	}
+	return nil
+}
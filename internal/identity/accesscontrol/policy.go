@@ -0,0 +1,162 @@
+package accesscontrol
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/identity/reports"
+	"slices"
+)
+
+var ErrAccessDenied = errors.New("accesscontrol.access-denied", "You do not have access to this resource")
+
+// Any matches every action or resource type in a policy
+const Any = "*"
+
+// Resource types
+const (
+	ResourceUser    = "user"
+	ResourceReport  = "report"
+	ResourceComment = "comment"
+)
+
+// Subject describes who performs an action
+type Subject struct {
+	UserID int64
+	Role   string
+	Team   string
+}
+
+// Resource describes what an action is performed on. OwnerID is the user the
+// resource belongs to: the user itself, or the author of a report or comment.
+// Team is the team of the owner.
+type Resource struct {
+	Type    string
+	ID      int64
+	OwnerID int64
+	Status  string
+	Team    string
+}
+
+// Condition decides from the attributes of a request whether a policy applies
+type Condition func(subject *Subject, resource *Resource) bool
+
+// Policy allows Actions on resources of type Resource to subjects holding one of
+// Roles (any role when empty) for which Condition holds (always when nil).
+// Actions are permissions, so a policy only ever narrows down what the role of
+// the subject is granted.
+type Policy struct {
+	Name      string
+	Roles     []string
+	Actions   []string
+	Resource  string
+	Condition Condition
+}
+
+// Matches reports whether the policy allows action on resource to subject
+func (p *Policy) Matches(subject *Subject, action string, resource *Resource) bool {
+	if len(p.Roles) > 0 && !slices.Contains(p.Roles, subject.Role) {
+		return false
+	}
+	if !slices.Contains(p.Actions, Any) && !slices.Contains(p.Actions, action) {
+		return false
+	}
+	if p.Resource != Any && p.Resource != resource.Type {
+		return false
+	}
+
+	return p.Condition == nil || p.Condition(subject, resource)
+}
+
+// DefaultPolicies are evaluated for every resource-level check. Anything they do
+// not allow is denied.
+var DefaultPolicies = []*Policy{
+	{
+		Name:     "admins may do anything",
+		Roles:    []string{RoleAdmin},
+		Actions:  []string{Any},
+		Resource: Any,
+	},
+	{
+		Name:      "users may read themselves",
+		Actions:   []string{PermissionReadUser},
+		Resource:  ResourceUser,
+		Condition: IsOwner,
+	},
+	{
+		Name:      "authors may create reports for themselves",
+		Actions:   []string{PermissionCreateReport},
+		Resource:  ResourceReport,
+		Condition: IsOwner,
+	},
+	{
+		Name:      "authors may read, submit and comment on their own reports",
+		Actions:   []string{PermissionReadReport, PermissionSubmitReport, PermissionCommentReport},
+		Resource:  ResourceReport,
+		Condition: IsOwner,
+	},
+	{
+		Name:      "authors may update their own draft or rejected reports",
+		Actions:   []string{PermissionUpdateReport},
+		Resource:  ResourceReport,
+		Condition: All(IsOwner, IsEditable),
+	},
+	{
+		Name:      "reviewers may read and comment on submitted reports in their team",
+		Roles:     []string{RoleReviewer},
+		Actions:   []string{PermissionReadReport, PermissionCommentReport},
+		Resource:  ResourceReport,
+		Condition: All(IsSubmitted, InTeam),
+	},
+	{
+		Name:      "reviewers may review submitted reports in their team they did not write",
+		Roles:     []string{RoleReviewer},
+		Actions:   []string{PermissionReviewReport, PermissionApproveReport, PermissionRejectReport},
+		Resource:  ResourceReport,
+		Condition: All(IsSubmitted, InTeam, Not(IsOwner)),
+	},
+	{
+		Name:      "authors may delete their own comments",
+		Actions:   []string{PermissionCommentReport},
+		Resource:  ResourceComment,
+		Condition: IsOwner,
+	},
+}
+
+// IsOwner holds when the resource belongs to the subject
+func IsOwner(subject *Subject, resource *Resource) bool {
+	return resource.OwnerID != 0 && resource.OwnerID == subject.UserID
+}
+
+// InTeam holds when the owner of the resource is in the team of the subject.
+// Subjects without a team are in no team, not in a team of everyone without one.
+func InTeam(subject *Subject, resource *Resource) bool {
+	return subject.Team != "" && resource.Team == subject.Team
+}
+
+// IsEditable holds for reports their author may still change
+func IsEditable(_ *Subject, resource *Resource) bool {
+	return reports.IsEditable(resource.Status)
+}
+
+// IsSubmitted holds for reports that have left the draft stage
+func IsSubmitted(_ *Subject, resource *Resource) bool {
+	return resource.Status != "" && resource.Status != reports.StatusDraft
+}
+
+// Not holds when condition does not
+func Not(condition Condition) Condition {
+	return func(subject *Subject, resource *Resource) bool {
+		return !condition(subject, resource)
+	}
+}
+
+// All holds when every one of conditions holds
+func All(conditions ...Condition) Condition {
+	return func(subject *Subject, resource *Resource) bool {
+		for _, condition := range conditions {
+			if !condition(subject, resource) {
+				return false
+			}
+		}
+		return true
+	}
+}
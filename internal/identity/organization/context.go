@@ -0,0 +1,33 @@
+package organization
+
+import "context"
+
+type contextKey struct{}
+
+// ContextKey holds the id of the organization a request acts in. The auth
+// middleware stores it in the request locals, which fiber exposes through the
+// context handlers pass on to services and stores.
+var ContextKey = contextKey{}
+
+// WithID returns a copy of ctx acting in organization id
+func WithID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, ContextKey, id)
+}
+
+// FromContext returns the organization ctx acts in, if any
+func FromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(ContextKey).(int64)
+	return id, ok && id > 0
+}
+
+// Require returns the organization ctx acts in. Stores holding tenant data call
+// it for every query, so that a missing organization fails instead of reading
+// across organizations.
+func Require(ctx context.Context) (int64, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return 0, ErrNoOrganization
+	}
+
+	return id, nil
+}
@@ -0,0 +1,102 @@
+package organization
+
+import (
+	"amg/internal/api/errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization.not-found", "Organization not found")
+	ErrSlugTaken            = errors.New("organization.slug-taken", "Organization slug is already taken")
+	ErrInvalidName          = errors.New("organization.invalid-name", "Invalid organization name")
+	ErrInvalidSlug          = errors.New("organization.invalid-slug", "Invalid organization slug")
+	ErrNoOrganization       = errors.New("organization.missing", "No organization selected")
+	ErrNotMember            = errors.New("organization.not-member", "You are not a member of this organization")
+	ErrMemberNotFound       = errors.New("organization.member-not-found", "Member not found")
+	ErrAlreadyMember        = errors.New("organization.already-member", "User is already a member")
+	ErrInvalidRole          = errors.New("organization.invalid-role", "Invalid role")
+	ErrChangeSelf           = errors.New("organization.change-self", "You cannot change your own membership")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,98}[a-z0-9]$`)
+
+type Organization struct {
+	ID        int64  `db:"id" json:"id"`
+	Name      string `db:"name" json:"name"`
+	Slug      string `db:"slug" json:"slug"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+	UpdatedAt string `db:"updated_at" json:"updated_at"`
+}
+
+// Membership is an organization as seen by one of its members
+type Membership struct {
+	OrganizationID int64  `db:"organization_id" json:"organization_id"`
+	Name           string `db:"name" json:"name"`
+	Slug           string `db:"slug" json:"slug"`
+	Role           string `db:"role" json:"role"`
+	JoinedAt       string `db:"joined_at" json:"joined_at"`
+}
+
+// Member is a user as seen by an organization
+type Member struct {
+	UserID    int64  `db:"user_id" json:"user_id"`
+	FirstName string `db:"first_name" json:"first_name"`
+	LastName  string `db:"last_name" json:"last_name"`
+	Email     string `db:"email" json:"email"`
+	Role      string `db:"role" json:"role"`
+	JoinedAt  string `db:"joined_at" json:"joined_at"`
+}
+
+type CreateOrganizationCommand struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+	// OwnerID becomes the first member, as admin
+	OwnerID int64 `json:"-"`
+}
+
+type UpdateOrganizationCommand struct {
+	ID   int64  `json:"-"`
+	Name string `json:"name"`
+}
+
+type UpdateMemberCommand struct {
+	UserID int64  `json:"-"`
+	Role   string `json:"role"`
+	// ActorID is the member making the change
+	ActorID int64 `json:"-"`
+}
+
+type RemoveMemberCommand struct {
+	UserID  int64 `json:"-"`
+	ActorID int64 `json:"-"`
+}
+
+func (cmd *CreateOrganizationCommand) Validate() error {
+	cmd.Name = strings.TrimSpace(cmd.Name)
+	cmd.Slug = strings.ToLower(strings.TrimSpace(cmd.Slug))
+
+	if len(cmd.Name) == 0 || len(cmd.Name) > 255 {
+		return ErrInvalidName
+	}
+	if !slugPattern.MatchString(cmd.Slug) {
+		return ErrInvalidSlug
+	}
+	return nil
+}
+
+func (cmd *UpdateOrganizationCommand) Validate() error {
+	cmd.Name = strings.TrimSpace(cmd.Name)
+
+	if len(cmd.Name) == 0 || len(cmd.Name) > 255 {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+func (cmd *UpdateMemberCommand) Validate() error {
+	if len(strings.TrimSpace(cmd.Role)) == 0 {
+		return ErrInvalidRole
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package organization
+
+import "context"
+
+type Service interface {
+	CreateOrganization(ctx context.Context, cmd *CreateOrganizationCommand) (*Organization, error)
+	UpdateOrganization(ctx context.Context, cmd *UpdateOrganizationCommand) error
+	GetByOrganizationID(ctx context.Context, id int64) (*Organization, error)
+	GetBySlug(ctx context.Context, slug string) (*Organization, error)
+	// GetMemberships lists the organizations of a user with their role in each
+	GetMemberships(ctx context.Context, userID int64) ([]*Membership, error)
+
+	// The member commands act on the organization of ctx
+	GetMembers(ctx context.Context) ([]*Member, error)
+	UpdateMember(ctx context.Context, cmd *UpdateMemberCommand) error
+	RemoveMember(ctx context.Context, cmd *RemoveMemberCommand) error
+}
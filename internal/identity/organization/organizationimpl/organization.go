@@ -0,0 +1,180 @@
+package organizationimpl
+
+import (
+	"amg/config"
+	"amg/internal/db"
+	"amg/internal/identity/accesscontrol"
+	"amg/internal/identity/organization"
+	"amg/internal/identity/user"
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type service struct {
+	store  *store
+	cfg    *config.Config
+	log    *zap.Logger
+	db     db.DB
+	access accesscontrol.Service
+	users  user.Service
+}
+
+func NewService(db db.DB, cfg *config.Config, access accesscontrol.Service, users user.Service) *service {
+	return &service{
+		store:  NewStore(db),
+		cfg:    cfg,
+		db:     db,
+		access: access,
+		users:  users,
+		log:    zap.L().Named("organization.service"),
+	}
+}
+
+// checkRole fails with ErrInvalidRole unless role exists
+func (s *service) checkRole(ctx context.Context, role string) error {
+	exists, err := s.access.RoleExists(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return organization.ErrInvalidRole
+	}
+
+	return nil
+}
+
+// CreateOrganization creates an organization administered by its creator
+func (s *service) CreateOrganization(ctx context.Context, cmd *organization.CreateOrganizationCommand) (*organization.Organization, error) {
+	taken, err := s.store.getBySlug(ctx, cmd.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if taken != nil {
+		return nil, organization.ErrSlugTaken
+	}
+
+	id, err := s.store.create(ctx, cmd, accesscontrol.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("organization created", zap.Int64("organization_id", id), zap.Int64("owner_id", cmd.OwnerID))
+
+	return s.GetByOrganizationID(ctx, id)
+}
+
+func (s *service) UpdateOrganization(ctx context.Context, cmd *organization.UpdateOrganizationCommand) error {
+	updated, err := s.store.update(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return organization.ErrOrganizationNotFound
+	}
+
+	return nil
+}
+
+func (s *service) GetByOrganizationID(ctx context.Context, id int64) (*organization.Organization, error) {
+	result, err := s.store.getByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, organization.ErrOrganizationNotFound
+	}
+
+	return result, nil
+}
+
+func (s *service) GetBySlug(ctx context.Context, slug string) (*organization.Organization, error) {
+	result, err := s.store.getBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, organization.ErrOrganizationNotFound
+	}
+
+	return result, nil
+}
+
+func (s *service) GetMemberships(ctx context.Context, userID int64) ([]*organization.Membership, error) {
+	return s.store.getMemberships(ctx, userID)
+}
+
+func (s *service) GetMembers(ctx context.Context) ([]*organization.Member, error) {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.getMembers(ctx, orgID)
+}
+
+// UpdateMember changes the role of a member. The new role applies to their
+// tokens from the next refresh on.
+func (s *service) UpdateMember(ctx context.Context, cmd *organization.UpdateMemberCommand) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cmd.UserID == cmd.ActorID {
+		return organization.ErrChangeSelf
+	}
+
+	err = s.checkRole(ctx, cmd.Role)
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.store.updateMember(ctx, orgID, cmd.UserID, cmd.Role)
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return organization.ErrMemberNotFound
+	}
+
+	s.log.Info("member role changed",
+		zap.Int64("organization_id", orgID),
+		zap.Int64("user_id", cmd.UserID),
+		zap.String("role", cmd.Role),
+	)
+
+	return nil
+}
+
+// RemoveMember takes a user out of the organization of ctx and ends their
+// sessions in it. Their account and other memberships are left alone.
+func (s *service) RemoveMember(ctx context.Context, cmd *organization.RemoveMemberCommand) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cmd.UserID == cmd.ActorID {
+		return organization.ErrChangeSelf
+	}
+
+	removed, err := s.store.removeMember(ctx, orgID, cmd.UserID)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		return organization.ErrMemberNotFound
+	}
+
+	s.log.Info("member removed", zap.Int64("organization_id", orgID), zap.Int64("user_id", cmd.UserID))
+
+	return s.users.RevokeAllSessions(ctx, cmd.UserID)
+}
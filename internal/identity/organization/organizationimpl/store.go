@@ -0,0 +1,270 @@
+package organizationimpl
+
+import (
+	"amg/internal/db"
+	"amg/internal/identity/organization"
+	"context"
+	"database/sql"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+type store struct {
+	db     db.DB
+	logger *zap.Logger
+}
+
+func NewStore(db db.DB) *store {
+	return &store{
+		db:     db,
+		logger: zap.L().Named("organization.store"),
+	}
+}
+
+// create inserts the organization with ownerID as its first member
+func (s *store) create(ctx context.Context, cmd *organization.CreateOrganizationCommand, ownerRole string) (int64, error) {
+	var id int64
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		INSERT INTO organizations (
+			name,
+			slug
+		) VALUES (
+			$1, $2
+		) RETURNING id
+		`
+
+		err := tx.QueryRow(ctx, rawSQL, cmd.Name, cmd.Slug).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		INSERT INTO organization_members (
+			organization_id,
+			user_id,
+			role
+		) VALUES (
+			$1, $2, $3
+		)
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, id, cmd.OwnerID, ownerRole)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *store) getByID(ctx context.Context, id int64) (*organization.Organization, error) {
+	var result organization.Organization
+
+	rawSQL := `
+	SELECT
+		id,
+		name,
+		slug,
+		created_at,
+		updated_at
+	FROM
+		organizations
+	WHERE
+		id = $1
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) getBySlug(ctx context.Context, slug string) (*organization.Organization, error) {
+	var result organization.Organization
+
+	rawSQL := `
+	SELECT
+		id,
+		name,
+		slug,
+		created_at,
+		updated_at
+	FROM
+		organizations
+	WHERE
+		slug = $1
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) update(ctx context.Context, cmd *organization.UpdateOrganizationCommand) (bool, error) {
+	rawSQL := `
+	UPDATE
+		organizations
+	SET
+		name = $1,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE
+		id = $2
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, cmd.Name, cmd.ID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *store) getMemberships(ctx context.Context, userID int64) ([]*organization.Membership, error) {
+	result := make([]*organization.Membership, 0)
+
+	rawSQL := `
+	SELECT
+		o.id AS organization_id,
+		o.name,
+		o.slug,
+		m.role,
+		m.created_at AS joined_at
+	FROM
+		organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+	WHERE
+		m.user_id = $1
+	ORDER BY m.created_at, o.id
+	`
+
+	err := s.db.Select(ctx, &result, rawSQL, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) getMembers(ctx context.Context, orgID int64) ([]*organization.Member, error) {
+	result := make([]*organization.Member, 0)
+
+	rawSQL := `
+	SELECT
+		u.id AS user_id,
+		u.first_name,
+		u.last_name,
+		u.email,
+		m.role,
+		m.created_at AS joined_at
+	FROM
+		organization_members m
+		JOIN users u ON u.id = m.user_id
+	WHERE
+		m.organization_id = $1
+	ORDER BY u.first_name, u.last_name, u.id
+	`
+
+	err := s.db.Select(ctx, &result, rawSQL, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) getMember(ctx context.Context, orgID, userID int64) (*organization.Member, error) {
+	var result organization.Member
+
+	rawSQL := `
+	SELECT
+		u.id AS user_id,
+		u.first_name,
+		u.last_name,
+		u.email,
+		m.role,
+		m.created_at AS joined_at
+	FROM
+		organization_members m
+		JOIN users u ON u.id = m.user_id
+	WHERE
+		m.organization_id = $1 AND
+		m.user_id = $2
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, orgID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) updateMember(ctx context.Context, orgID, userID int64, role string) (bool, error) {
+	rawSQL := `
+	UPDATE
+		organization_members
+	SET
+		role = $1
+	WHERE
+		organization_id = $2 AND
+		user_id = $3
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, role, orgID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *store) removeMember(ctx context.Context, orgID, userID int64) (bool, error) {
+	rawSQL := `
+	DELETE
+	FROM
+		organization_members
+	WHERE
+		organization_id = $1 AND
+		user_id = $2
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, orgID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
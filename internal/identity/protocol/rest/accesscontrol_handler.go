@@ -0,0 +1,229 @@
+package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/identity/accesscontrol"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type accessControlHandler struct {
+	s accesscontrol.Service
+}
+
+func NewAccessControlHandler(s accesscontrol.Service) *accessControlHandler {
+	return &accessControlHandler{
+		s: s,
+	}
+}
+
+func accessControlError(err error) error {
+	switch err {
+	case accesscontrol.ErrRoleNotFound, accesscontrol.ErrPermissionNotFound:
+		return errors.ErrorNotFound(err)
+	case accesscontrol.ErrRoleAlreadyExists, accesscontrol.ErrPermissionExists,
+		accesscontrol.ErrBuiltinRole, accesscontrol.ErrBuiltinPermission,
+		accesscontrol.ErrRoleInUse, accesscontrol.ErrAdminLockout:
+		return errors.ErrorBadRequest(err)
+	}
+	return errors.ErrorInternalServerError(err)
+}
+
+func (h *accessControlHandler) CreateRole(ctx *fiber.Ctx) error {
+	var cmd accesscontrol.CreateRoleCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.CreateRole(ctx.Context(), &cmd)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	result, err := h.s.GetRole(ctx.Context(), cmd.Name)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"role data": result,
+	})
+}
+
+func (h *accessControlHandler) GetRoles(ctx *fiber.Ctx) error {
+	result, err := h.s.GetRoles(ctx.Context())
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"roles data": result,
+	})
+}
+
+func (h *accessControlHandler) GetRole(ctx *fiber.Ctx) error {
+	result, err := h.s.GetRole(ctx.Context(), ctx.Params("name"))
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"role data": result,
+	})
+}
+
+func (h *accessControlHandler) UpdateRole(ctx *fiber.Ctx) error {
+	var cmd accesscontrol.UpdateRoleCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.Name = ctx.Params("name")
+
+	err = h.s.UpdateRole(ctx.Context(), &cmd)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	result, err := h.s.GetRole(ctx.Context(), cmd.Name)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"role data": result,
+	})
+}
+
+func (h *accessControlHandler) DeleteRole(ctx *fiber.Ctx) error {
+	err := h.s.DeleteRole(ctx.Context(), ctx.Params("name"))
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "role deleted successfully!",
+	})
+}
+
+// SetRolePermissions replaces the permissions of a role with the ones in the body
+func (h *accessControlHandler) SetRolePermissions(ctx *fiber.Ctx) error {
+	var cmd accesscontrol.SetRolePermissionsCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.Role = ctx.Params("name")
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.SetRolePermissions(ctx.Context(), &cmd)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	result, err := h.s.GetRole(ctx.Context(), cmd.Role)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"role data": result,
+	})
+}
+
+func (h *accessControlHandler) GrantPermission(ctx *fiber.Ctx) error {
+	role := ctx.Params("name")
+
+	err := h.s.GrantPermission(ctx.Context(), role, ctx.Params("permission"))
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	result, err := h.s.GetRole(ctx.Context(), role)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"role data": result,
+	})
+}
+
+func (h *accessControlHandler) RevokePermission(ctx *fiber.Ctx) error {
+	role := ctx.Params("name")
+
+	err := h.s.RevokePermission(ctx.Context(), role, ctx.Params("permission"))
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	result, err := h.s.GetRole(ctx.Context(), role)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"role data": result,
+	})
+}
+
+func (h *accessControlHandler) CreatePermission(ctx *fiber.Ctx) error {
+	var cmd accesscontrol.CreatePermissionCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.CreatePermission(ctx.Context(), &cmd)
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"permission data": cmd,
+	})
+}
+
+func (h *accessControlHandler) GetPermissions(ctx *fiber.Ctx) error {
+	result, err := h.s.GetPermissions(ctx.Context())
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"permissions data": result,
+	})
+}
+
+func (h *accessControlHandler) DeletePermission(ctx *fiber.Ctx) error {
+	err := h.s.DeletePermission(ctx.Context(), ctx.Params("permission"))
+	if err != nil {
+		return accessControlError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "permission deleted successfully!",
+	})
+}
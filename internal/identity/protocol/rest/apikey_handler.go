@@ -0,0 +1,138 @@
+package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/identity/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func apiKeyError(err error) error {
+	switch err {
+	case user.ErrAPIKeyNotFound, user.ErrUserNotFound:
+		return errors.ErrorNotFound(err)
+	case user.ErrInvalidAPIKeyScopes, user.ErrUserAlreadyExists, user.ErrorInvalidRole:
+		return errors.ErrorBadRequest(err)
+	}
+	return errors.ErrorInternalServerError(err)
+}
+
+// createAPIKey creates a key owned by ownerID; the key is in the response only
+func (h *userHandler) createAPIKey(ctx *fiber.Ctx, ownerID int64) error {
+	var cmd user.CreateAPIKeyCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.UserID = ownerID
+	cmd.CreatedBy, _ = ctx.Locals("userID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	result, err := h.s.CreateAPIKey(ctx.Context(), &cmd)
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"api key data": result,
+	})
+}
+
+func (h *userHandler) getAPIKeys(ctx *fiber.Ctx, ownerID int64) error {
+	result, err := h.s.GetAPIKeys(ctx.Context(), ownerID)
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"api keys data": result,
+	})
+}
+
+func (h *userHandler) revokeAPIKey(ctx *fiber.Ctx, ownerID int64) error {
+	keyID, err := ctx.ParamsInt("keyID")
+	if err != nil || keyID <= 0 {
+		return errors.ErrorNotFound(user.ErrAPIKeyNotFound)
+	}
+
+	err = h.s.RevokeAPIKey(ctx.Context(), ownerID, int64(keyID))
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "api key revoked successfully!",
+	})
+}
+
+func (h *userHandler) CreateMyAPIKey(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+	return h.createAPIKey(ctx, userID)
+}
+
+func (h *userHandler) GetMyAPIKeys(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+	return h.getAPIKeys(ctx, userID)
+}
+
+func (h *userHandler) RevokeMyAPIKey(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+	return h.revokeAPIKey(ctx, userID)
+}
+
+// CreateUserAPIKey lets admins issue keys for other users and service accounts
+func (h *userHandler) CreateUserAPIKey(ctx *fiber.Ctx) error {
+	id, _ := ctx.ParamsInt("id")
+	return h.createAPIKey(ctx, int64(id))
+}
+
+func (h *userHandler) GetUserAPIKeys(ctx *fiber.Ctx) error {
+	id, _ := ctx.ParamsInt("id")
+	return h.getAPIKeys(ctx, int64(id))
+}
+
+func (h *userHandler) RevokeUserAPIKey(ctx *fiber.Ctx) error {
+	id, _ := ctx.ParamsInt("id")
+	return h.revokeAPIKey(ctx, int64(id))
+}
+
+func (h *userHandler) CreateServiceAccount(ctx *fiber.Ctx) error {
+	var cmd user.CreateServiceAccountCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	result, err := h.s.CreateServiceAccount(ctx.Context(), &cmd)
+	if err != nil {
+		return apiKeyError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"user data": result,
+	})
+}
+
+func (h *userHandler) GetServiceAccounts(ctx *fiber.Ctx) error {
+	result, err := h.s.GetServiceAccounts(ctx.Context())
+	if err != nil {
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"users data": result,
+	})
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"amg/internal/identity/accesscontrol"
+	"amg/internal/identity/reports"
+	"amg/internal/identity/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// subject describes the caller for policy checks. The role is the one of the
+// credentials, as for the permission checks of the routes.
+func subject(ctx *fiber.Ctx, current *user.User) *accesscontrol.Subject {
+	role, _ := ctx.Locals("role").(string)
+
+	return &accesscontrol.Subject{
+		UserID: current.ID,
+		Role:   role,
+		Team:   current.Team,
+	}
+}
+
+func userResource(u *user.User) *accesscontrol.Resource {
+	return &accesscontrol.Resource{
+		Type:    accesscontrol.ResourceUser,
+		ID:      u.ID,
+		OwnerID: u.ID,
+		Team:    u.Team,
+	}
+}
+
+func reportResource(report *reports.Report) *accesscontrol.Resource {
+	return &accesscontrol.Resource{
+		Type:    accesscontrol.ResourceReport,
+		ID:      report.ID,
+		OwnerID: report.AuthorID,
+		Status:  report.Status,
+		Team:    report.AuthorTeam,
+	}
+}
+
+func commentResource(comment *reports.Comment) *accesscontrol.Resource {
+	return &accesscontrol.Resource{
+		Type:    accesscontrol.ResourceComment,
+		ID:      comment.ID,
+		OwnerID: comment.AuthorID,
+	}
+}
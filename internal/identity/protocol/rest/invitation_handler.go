@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/identity/organization"
+	"amg/internal/identity/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func invitationError(err error) error {
+	if e, ok := passwordPolicyError(err); ok {
+		return e
+	}
+	switch err {
+	case user.ErrInvitationNotFound:
+		return errors.ErrorNotFound(err)
+	case user.ErrInvitationPending, user.ErrInvalidInvitation, user.ErrorInvalidRole, organization.ErrAlreadyMember,
+		user.ErrInvalidFirstName, user.ErrInvalidLastName, user.ErrInvalidPassword, user.ErrInvalidPhoneNumber:
+		return errors.ErrorBadRequest(err)
+	}
+	return errors.ErrorInternalServerError(err)
+}
+
+// CreateInvitation invites an email to the organization of the caller with a
+// role and mails them a link to accept
+func (h *userHandler) CreateInvitation(ctx *fiber.Ctx) error {
+	var cmd user.CreateInvitationCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.InvitedBy, _ = ctx.Locals("userID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	result, err := h.s.CreateInvitation(ctx.Context(), &cmd)
+	if err != nil {
+		return invitationError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"invitation data": result,
+	})
+}
+
+// GetInvitations lists the pending invitations of the organization of the caller
+func (h *userHandler) GetInvitations(ctx *fiber.Ctx) error {
+	result, err := h.s.GetInvitations(ctx.Context())
+	if err != nil {
+		return invitationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"invitations data": result,
+	})
+}
+
+func (h *userHandler) ResendInvitation(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return errors.ErrorNotFound(user.ErrInvitationNotFound)
+	}
+
+	result, err := h.s.ResendInvitation(ctx.Context(), int64(id))
+	if err != nil {
+		return invitationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"invitation data": result,
+	})
+}
+
+func (h *userHandler) RevokeInvitation(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return errors.ErrorNotFound(user.ErrInvitationNotFound)
+	}
+
+	err = h.s.RevokeInvitation(ctx.Context(), int64(id))
+	if err != nil {
+		return invitationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "invitation revoked successfully!",
+	})
+}
+
+// AcceptInvitation answers an invitation with the token from its link. Invitees
+// without an account choose their password and profile here.
+func (h *userHandler) AcceptInvitation(ctx *fiber.Ctx) error {
+	var cmd user.AcceptInvitationCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.AcceptInvitation(ctx.Context(), &cmd)
+	if err != nil {
+		return invitationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "invitation accepted successfully!",
+	})
+}
@@ -0,0 +1,28 @@
+package rest
+
+import (
+	"amg/pkg/util/jwt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jwksCacheControl lets verifiers cache the key set while still picking up a
+// rotated key within minutes
+const jwksCacheControl = "public, max-age=300"
+
+type jwksHandler struct {
+	keys *jwt.KeySet
+}
+
+func NewJWKSHandler(keys *jwt.KeySet) *jwksHandler {
+	return &jwksHandler{
+		keys: keys,
+	}
+}
+
+// GetJWKS serves the public verification keys as a bare JWK set, outside the API
+// response envelope, since it is read by standard JWT libraries
+func (h *jwksHandler) GetJWKS(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderCacheControl, jwksCacheControl)
+	return ctx.JSON(h.keys.JWKS())
+}
@@ -0,0 +1,212 @@
+package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/identity/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// mfaError maps the two-factor errors shared by the handlers below
+func mfaError(err error) error {
+	switch err {
+	case user.ErrInvalidMFACode, user.ErrInvalidPassword, user.ErrMFAAlreadyEnabled,
+		user.ErrMFANotEnabled, user.ErrMFANotEnrolled:
+		return errors.ErrorBadRequest(err)
+	case user.ErrInvalidMFAToken:
+		return errors.ErrorUnauthorized(err, err.Error())
+	case user.ErrMFARequired:
+		return errors.NewApiError(err, fiber.StatusForbidden, err.Error(), nil)
+	case user.ErrUserNotFound:
+		return errors.ErrorUnauthorized(err, "Invalid or expired JWT")
+	}
+	return errors.ErrorInternalServerError(err)
+}
+
+// LoginMFA is the second step of a login with two-factor authentication
+func (h *userHandler) LoginMFA(ctx *fiber.Ctx) error {
+	var cmd user.LoginMFACommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	result, err := h.s.LoginMFA(ctx.Context(), &cmd)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+// EnrollMFALogin returns a new authenticator secret to a user who has to set up
+// two-factor authentication before their login can complete
+func (h *userHandler) EnrollMFALogin(ctx *fiber.Ctx) error {
+	var cmd user.EnrollMFALoginCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	result, err := h.s.EnrollMFALogin(ctx.Context(), &cmd)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"totp data": result,
+	})
+}
+
+func (h *userHandler) GetMyMFA(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+
+	result, err := h.s.GetMFAStatus(ctx.Context(), userID)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"mfa data": result,
+	})
+}
+
+// EnrollTOTP starts setting up an authenticator app for the authenticated user
+func (h *userHandler) EnrollTOTP(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+
+	result, err := h.s.EnrollTOTP(ctx.Context(), userID)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"totp data": result,
+	})
+}
+
+func (h *userHandler) ConfirmTOTP(ctx *fiber.Ctx) error {
+	var cmd user.ConfirmTOTPCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, _ = ctx.Locals("userID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	codes, err := h.s.ConfirmTOTP(ctx.Context(), &cmd)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *userHandler) DisableTOTP(ctx *fiber.Ctx) error {
+	var cmd user.DisableTOTPCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, _ = ctx.Locals("userID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.DisableTOTP(ctx.Context(), &cmd)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+func (h *userHandler) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	var cmd user.RegenerateRecoveryCodesCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, _ = ctx.Locals("userID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	codes, err := h.s.RegenerateRecoveryCodes(ctx.Context(), &cmd)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *userHandler) GetMFAPolicy(ctx *fiber.Ctx) error {
+	result, err := h.s.GetMFAPolicy(ctx.Context())
+	if err != nil {
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"policy data": result,
+	})
+}
+
+// UpdateMFAPolicy sets the roles that must use two-factor authentication
+func (h *userHandler) UpdateMFAPolicy(ctx *fiber.Ctx) error {
+	var policy user.MFAPolicy
+
+	err := ctx.BodyParser(&policy)
+	if err != nil {
+		return err
+	}
+
+	err = policy.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.UpdateMFAPolicy(ctx.Context(), &policy)
+	if err != nil {
+		if err == user.ErrorInvalidRole {
+			return errors.ErrorBadRequest(err)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"policy data": policy,
+	})
+}
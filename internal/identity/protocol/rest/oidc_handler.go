@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/identity/organization"
+	"amg/internal/identity/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OIDCAuthorize returns the URL of the identity provider to start a single
+// sign-on login at
+func (h *userHandler) OIDCAuthorize(ctx *fiber.Ctx) error {
+	result, err := h.s.OIDCAuthorize(ctx.Context())
+	if err != nil {
+		if err == user.ErrOIDCDisabled {
+			return errors.ErrorNotFound(err)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+// OIDCCallback completes a single sign-on login with the code and state the
+// identity provider redirected back with
+func (h *userHandler) OIDCCallback(ctx *fiber.Ctx) error {
+	var cmd user.OIDCLoginCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	cmd.UserAgent = ctx.Get(fiber.HeaderUserAgent)
+	cmd.IPAddress = ctx.IP()
+
+	result, err := h.s.OIDCLogin(ctx.Context(), &cmd)
+	if err != nil {
+		switch err {
+		case user.ErrOIDCDisabled:
+			return errors.ErrorNotFound(err)
+		case user.ErrInvalidOIDCState, user.ErrInvalidOIDCCode:
+			return errors.ErrorUnauthorized(err, err.Error())
+		case user.ErrOIDCEmailNotVerified, user.ErrOIDCAccountNotAllowed, organization.ErrNotMember:
+			return errors.NewApiError(err, fiber.StatusForbidden, err.Error(), nil)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
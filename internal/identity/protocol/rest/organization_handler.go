@@ -0,0 +1,171 @@
+package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/identity/organization"
+	"amg/internal/identity/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type organizationHandler struct {
+	s organization.Service
+}
+
+func NewOrganizationHandler(s organization.Service) *organizationHandler {
+	return &organizationHandler{
+		s: s,
+	}
+}
+
+func organizationError(err error) error {
+	switch err {
+	case organization.ErrOrganizationNotFound, organization.ErrMemberNotFound, user.ErrUserNotFound:
+		return errors.ErrorNotFound(err)
+	case organization.ErrSlugTaken, organization.ErrAlreadyMember, organization.ErrInvalidRole,
+		organization.ErrChangeSelf:
+		return errors.ErrorBadRequest(err)
+	}
+	return errors.ErrorInternalServerError(err)
+}
+
+// CreateOrganization creates a new organization with the caller as its admin
+func (h *organizationHandler) CreateOrganization(ctx *fiber.Ctx) error {
+	var cmd organization.CreateOrganizationCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.OwnerID, _ = ctx.Locals("userID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	result, err := h.s.CreateOrganization(ctx.Context(), &cmd)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"organization data": result,
+	})
+}
+
+// GetMyOrganizations lists the organizations of the authenticated user, to pick
+// one to switch to
+func (h *organizationHandler) GetMyOrganizations(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+
+	result, err := h.s.GetMemberships(ctx.Context(), userID)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"organizations data": result,
+	})
+}
+
+// GetOrganization returns the organization the caller acts in
+func (h *organizationHandler) GetOrganization(ctx *fiber.Ctx) error {
+	orgID, _ := ctx.Locals("organizationID").(int64)
+
+	result, err := h.s.GetByOrganizationID(ctx.Context(), orgID)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"organization data": result,
+	})
+}
+
+func (h *organizationHandler) UpdateOrganization(ctx *fiber.Ctx) error {
+	var cmd organization.UpdateOrganizationCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, _ = ctx.Locals("organizationID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.UpdateOrganization(ctx.Context(), &cmd)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	result, err := h.s.GetByOrganizationID(ctx.Context(), cmd.ID)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"organization data": result,
+	})
+}
+
+func (h *organizationHandler) GetMembers(ctx *fiber.Ctx) error {
+	result, err := h.s.GetMembers(ctx.Context())
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"members data": result,
+	})
+}
+
+func (h *organizationHandler) UpdateMember(ctx *fiber.Ctx) error {
+	var cmd organization.UpdateMemberCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	id, _ := ctx.ParamsInt("userID")
+
+	cmd.UserID = int64(id)
+	cmd.ActorID, _ = ctx.Locals("userID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.UpdateMember(ctx.Context(), &cmd)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "member updated successfully!",
+	})
+}
+
+func (h *organizationHandler) RemoveMember(ctx *fiber.Ctx) error {
+	id, _ := ctx.ParamsInt("userID")
+
+	cmd := organization.RemoveMemberCommand{UserID: int64(id)}
+	cmd.ActorID, _ = ctx.Locals("userID").(int64)
+
+	err := h.s.RemoveMember(ctx.Context(), &cmd)
+	if err != nil {
+		return organizationError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "member removed successfully!",
+	})
+}
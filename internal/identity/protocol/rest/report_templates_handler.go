@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/identity/reports"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type reportTemplatesHandler struct {
+	s reports.Service
+}
+
+func NewReportTemplatesHandler(s reports.Service) *reportTemplatesHandler {
+	return &reportTemplatesHandler{
+		s: s,
+	}
+}
+
+func templateID(ctx *fiber.Ctx) (int64, error) {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, errors.ErrorBadRequest(reports.ErrInvalidTemplateID)
+	}
+
+	return int64(id), nil
+}
+
+func (h *reportTemplatesHandler) CreateTemplate(ctx *fiber.Ctx) error {
+	var cmd reports.CreateTemplateCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.CreateTemplate(ctx.Context(), &cmd)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"template data": cmd,
+	})
+}
+
+func (h *reportTemplatesHandler) GetByTemplateID(ctx *fiber.Ctx) error {
+	id, err := templateID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.GetByTemplateID(ctx.Context(), id)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"template data": result,
+	})
+}
+
+func (h *reportTemplatesHandler) UpdateTemplate(ctx *fiber.Ctx) error {
+	var cmd reports.UpdateTemplateCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, err = templateID(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.UpdateTemplate(ctx.Context(), &cmd)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"template data": cmd,
+	})
+}
+
+func (h *reportTemplatesHandler) SearchTemplate(ctx *fiber.Ctx) error {
+	var query reports.SearchTemplateQuery
+
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.SearchTemplate(ctx.Context(), &query)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+func (h *reportTemplatesHandler) DeleteTemplate(ctx *fiber.Ctx) error {
+	id, err := templateID(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.s.DeleteTemplate(ctx.Context(), id)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "template deleted successfully!",
+	})
+}
@@ -1 +1,858 @@
 package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/identity/accesscontrol"
+	"amg/internal/identity/organization"
+	"amg/internal/identity/reports"
+	"amg/internal/identity/reports/export"
+	"amg/internal/identity/user"
+	"bufio"
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// exportFlushEvery is the number of rows written between flushes of a streamed bulk export
+const exportFlushEvery = 100
+
+type reportsHandler struct {
+	s      reports.Service
+	users  user.Service
+	access accesscontrol.Service
+	log    *zap.Logger
+}
+
+func NewReportsHandler(s reports.Service, users user.Service, access accesscontrol.Service) *reportsHandler {
+	return &reportsHandler{
+		s:      s,
+		users:  users,
+		access: access,
+		log:    zap.L().Named("reports.handler"),
+	}
+}
+
+// currentUser resolves the authenticated user from the JWT locals
+func (h *reportsHandler) currentUser(ctx *fiber.Ctx) (*user.User, error) {
+	id, _ := ctx.Locals("userID").(int64)
+
+	return h.users.GetByUserID(ctx.Context(), id)
+}
+
+func hasRole(ctx *fiber.Ctx, role string) bool {
+	current, _ := ctx.Locals("role").(string)
+	return current == role
+}
+
+func isAdmin(ctx *fiber.Ctx) bool {
+	return hasRole(ctx, accesscontrol.RoleAdmin)
+}
+
+// authorize checks action on a single report against the access policies
+func (h *reportsHandler) authorize(ctx *fiber.Ctx, current *user.User, action string, report *reports.Report) error {
+	err := h.access.Authorize(ctx.Context(), subject(ctx, current), action, reportResource(report))
+	if err != nil {
+		return reportError(err)
+	}
+
+	return nil
+}
+
+func reportError(err error) error {
+	if e, ok := err.(*reports.TemplateValidationError); ok {
+		return errors.NewApiError(err, fiber.StatusUnprocessableEntity, e.Error(), e.Fields)
+	}
+
+	switch err {
+	case reports.ErrReportNotFound, reports.ErrAttachmentNotFound, reports.ErrCommentNotFound,
+		reports.ErrTemplateNotFound, reports.ErrRevisionNotFound:
+		return errors.ErrorNotFound(err)
+	case reports.ErrAccessDenied, accesscontrol.ErrAccessDenied:
+		return errors.ErrorForbidden(err)
+	case reports.ErrInvalidTransition, reports.ErrNotEditable, export.ErrUnsupportedFormat,
+		reports.ErrInvalidAttachment, reports.ErrAttachmentTypeNotAllowed, reports.ErrInvalidParent,
+		reports.ErrTemplateExists, reports.ErrTemplateInUse, reports.ErrInvalidSchema,
+		reports.ErrInvalidRevision:
+		return errors.ErrorBadRequest(err)
+	case reports.ErrAttachmentTooLarge:
+		return errors.NewApiError(err, fiber.StatusRequestEntityTooLarge, err.Error(), nil)
+	case user.ErrUserNotFound:
+		return errors.ErrorUnauthorized(err, "Invalid or expired JWT")
+	}
+	return errors.ErrorInternalServerError(err)
+}
+
+func reportID(ctx *fiber.Ctx) (int64, error) {
+	return reportParam(ctx, "id")
+}
+
+func reportParam(ctx *fiber.Ctx, name string) (int64, error) {
+	id, err := ctx.ParamsInt(name)
+	if err != nil || id <= 0 {
+		return 0, errors.ErrorBadRequest(reports.ErrInvalidID)
+	}
+
+	return int64(id), nil
+}
+
+// readableReport loads the report named by the :id param and checks that the
+// caller may read it
+func (h *reportsHandler) readableReport(ctx *fiber.Ctx) (*reports.Report, *user.User, error) {
+	return h.authorizedReport(ctx, accesscontrol.PermissionReadReport)
+}
+
+// authorizedReport loads the report named by the :id param and checks that the
+// caller may perform action on it
+func (h *reportsHandler) authorizedReport(ctx *fiber.Ctx, action string) (*reports.Report, *user.User, error) {
+	result, current, err := h.loadReport(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = h.authorize(ctx, current, action, result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, current, nil
+}
+
+// loadReport loads the report named by the :id param and the caller
+func (h *reportsHandler) loadReport(ctx *fiber.Ctx) (*reports.Report, *user.User, error) {
+	id, err := reportID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err := h.currentUser(ctx)
+	if err != nil {
+		return nil, nil, reportError(err)
+	}
+
+	result, err := h.s.GetByReportID(ctx.Context(), id)
+	if err != nil {
+		return nil, nil, reportError(err)
+	}
+
+	return result, current, nil
+}
+
+// editableReport loads the report named by the :id param and checks that the
+// caller may change it: admins always, authors while it is editable
+func (h *reportsHandler) editableReport(ctx *fiber.Ctx) (*reports.Report, *user.User, error) {
+	result, current, err := h.loadReport(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = h.access.Authorize(ctx.Context(), subject(ctx, current), accesscontrol.PermissionUpdateReport, reportResource(result))
+	if err == accesscontrol.ErrAccessDenied && result.AuthorID == current.ID && !reports.IsEditable(result.Status) {
+		// Authors are told why they cannot change their own report
+		return nil, nil, reportError(reports.ErrNotEditable)
+	}
+	if err != nil {
+		return nil, nil, reportError(err)
+	}
+
+	return result, current, nil
+}
+
+func (h *reportsHandler) CreateReport(ctx *fiber.Ctx) error {
+	var cmd reports.CreateReportCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	current, err := h.currentUser(ctx)
+	if err != nil {
+		return reportError(err)
+	}
+
+	if cmd.AuthorID == 0 {
+		cmd.AuthorID = current.ID
+	}
+
+	// Only admins may file a report on behalf of another user
+	err = h.authorize(ctx, current, accesscontrol.PermissionCreateReport, &reports.Report{AuthorID: cmd.AuthorID})
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	// The author has to belong to the organization the report is filed in
+	if cmd.AuthorID != current.ID {
+		_, err = h.users.GetByUserID(ctx.Context(), cmd.AuthorID)
+		if err == user.ErrUserNotFound {
+			return errors.ErrorBadRequest(reports.ErrInvalidAuthor)
+		}
+		if err != nil {
+			return errors.ErrorInternalServerError(err)
+		}
+	}
+
+	err = h.s.CreateReport(ctx.Context(), &cmd)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"report data": cmd,
+	})
+}
+
+func (h *reportsHandler) GetByReportID(ctx *fiber.Ctx) error {
+	result, _, err := h.readableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"report data": result,
+	})
+}
+
+func (h *reportsHandler) UpdateReport(ctx *fiber.Ctx) error {
+	var cmd reports.UpdateReportCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, err = reportID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Authors may only edit their own reports while they are still a draft
+	// or after they have been sent back
+	_, current, err := h.editableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	cmd.EditorID = current.ID
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.UpdateReport(ctx.Context(), &cmd)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"report data": cmd,
+	})
+}
+
+func (h *reportsHandler) SearchReport(ctx *fiber.Ctx) error {
+	var query reports.SearchReportQuery
+
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return err
+	}
+
+	err = h.scopeSearch(ctx, &query)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.SearchReport(ctx.Context(), &query)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+// scopeSearch restricts a search to the reports the caller is allowed to see
+func (h *reportsHandler) scopeSearch(ctx *fiber.Ctx, query *reports.SearchReportQuery) error {
+	current, err := h.currentUser(ctx)
+	if err != nil {
+		return reportError(err)
+	}
+
+	// The same scopes as the report policies of accesscontrol.DefaultPolicies
+	switch {
+	case isAdmin(ctx):
+	case hasRole(ctx, accesscontrol.RoleReviewer) && current.Team != "":
+		query.ExcludeDrafts = true
+		query.AuthorTeam = &current.Team
+	default:
+		// Regular users, and reviewers without a team, only ever see their own
+		// reports
+		query.AuthorID = current.ID
+	}
+
+	return nil
+}
+
+func (h *reportsHandler) GetReportStats(ctx *fiber.Ctx) error {
+	var query reports.ReportStatsQuery
+
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return err
+	}
+
+	err = query.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	result, err := h.s.GetReportStats(ctx.Context(), &query)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+func (h *reportsHandler) DeleteReport(ctx *fiber.Ctx) error {
+	report, _, err := h.authorizedReport(ctx, accesscontrol.PermissionDeleteReport)
+	if err != nil {
+		return err
+	}
+
+	err = h.s.DeleteReport(ctx.Context(), report.ID)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "report deleted successfully!",
+	})
+}
+
+func (h *reportsHandler) SubmitReport(ctx *fiber.Ctx) error {
+	return h.transition(ctx, reports.StatusSubmitted, accesscontrol.PermissionSubmitReport)
+}
+
+func (h *reportsHandler) ReviewReport(ctx *fiber.Ctx) error {
+	return h.transition(ctx, reports.StatusReviewed, accesscontrol.PermissionReviewReport)
+}
+
+func (h *reportsHandler) ApproveReport(ctx *fiber.Ctx) error {
+	return h.transition(ctx, reports.StatusApproved, accesscontrol.PermissionApproveReport)
+}
+
+func (h *reportsHandler) RejectReport(ctx *fiber.Ctx) error {
+	return h.transition(ctx, reports.StatusRejected, accesscontrol.PermissionRejectReport)
+}
+
+// transition moves a report to the given status, if the caller may perform
+// action on the report
+func (h *reportsHandler) transition(ctx *fiber.Ctx, status, action string) error {
+	var cmd reports.TransitionReportCommand
+
+	// The body is optional; it only carries the reviewer comment
+	if len(ctx.Body()) > 0 {
+		err := ctx.BodyParser(&cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	report, current, err := h.authorizedReport(ctx, action)
+	if err != nil {
+		return err
+	}
+
+	cmd.ReportID = report.ID
+	cmd.ActorID = current.ID
+	cmd.Status = status
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.TransitionReport(ctx.Context(), &cmd)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"report data": cmd,
+	})
+}
+
+func (h *reportsHandler) GetReportHistory(ctx *fiber.Ctx) error {
+	report, _, err := h.readableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.GetReportHistory(ctx.Context(), report.ID)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"history": result,
+	})
+}
+
+func revisionParam(ctx *fiber.Ctx) (int, error) {
+	revision, err := ctx.ParamsInt("rev")
+	if err != nil || revision <= 0 {
+		return 0, errors.ErrorBadRequest(reports.ErrInvalidRevision)
+	}
+
+	return revision, nil
+}
+
+func (h *reportsHandler) GetRevisions(ctx *fiber.Ctx) error {
+	report, _, err := h.readableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.GetRevisions(ctx.Context(), report.ID)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"revisions": result,
+	})
+}
+
+// DiffRevisions returns the line diff of the body from ?against= (by default the
+// previous revision) to :rev
+func (h *reportsHandler) DiffRevisions(ctx *fiber.Ctx) error {
+	report, _, err := h.readableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	revision, err := revisionParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	against := ctx.QueryInt("against", 0)
+	if against < 0 {
+		return errors.ErrorBadRequest(reports.ErrInvalidRevision)
+	}
+
+	result, err := h.s.DiffRevisions(ctx.Context(), report.ID, revision, against)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"diff": result,
+	})
+}
+
+func (h *reportsHandler) RestoreRevision(ctx *fiber.Ctx) error {
+	report, current, err := h.editableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	revision, err := revisionParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	cmd := reports.RestoreRevisionCommand{
+		ReportID: report.ID,
+		Revision: revision,
+		EditorID: current.ID,
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.RestoreRevision(ctx.Context(), &cmd)
+	if err != nil {
+		return reportError(err)
+	}
+
+	result, err := h.s.GetByReportID(ctx.Context(), report.ID)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"report data": result,
+	})
+}
+
+func exportFormat(ctx *fiber.Ctx) (string, error) {
+	format := ctx.Query("format", export.FormatCSV)
+	if !export.IsValidFormat(format) {
+		return "", errors.ErrorBadRequest(export.ErrUnsupportedFormat)
+	}
+
+	return format, nil
+}
+
+func setExportHeaders(ctx *fiber.Ctx, format, name string) {
+	// Attachment sets a content type guessed from the file name, so it has to
+	// come first
+	if format != export.FormatHTML {
+		ctx.Attachment(export.FileName(name, format))
+	}
+	ctx.Set(fiber.HeaderContentType, export.ContentType(format))
+}
+
+func (h *reportsHandler) ExportReport(ctx *fiber.Ctx) error {
+	format, err := exportFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, _, err := h.readableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	setExportHeaders(ctx, format, fmt.Sprintf("report-%d", result.ID))
+
+	enc, err := export.NewEncoder(format, ctx.Response().BodyWriter())
+	if err != nil {
+		return reportError(err)
+	}
+
+	err = enc.Begin()
+	if err == nil {
+		err = enc.Encode(result)
+	}
+	if err == nil {
+		err = enc.End()
+	}
+	if err != nil {
+		return reportError(err)
+	}
+
+	return nil
+}
+
+// ExportReports streams every report matching the search filters. The body is
+// produced after the handler returns, so failures part way through can only be
+// logged and end the stream early.
+func (h *reportsHandler) ExportReports(ctx *fiber.Ctx) error {
+	var query reports.SearchReportQuery
+
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return err
+	}
+
+	format, err := exportFormat(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = h.scopeSearch(ctx, &query)
+	if err != nil {
+		return err
+	}
+
+	// The request context is gone by the time the body is written, so the
+	// organization is carried over by hand
+	orgID, _ := ctx.Locals("organizationID").(int64)
+	exportCtx := organization.WithID(context.Background(), orgID)
+
+	setExportHeaders(ctx, format, "reports-"+time.Now().Format("20060102-150405"))
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		enc, err := export.NewEncoder(format, w)
+		if err != nil {
+			h.log.Error("export failed", zap.Error(err))
+			return
+		}
+
+		err = enc.Begin()
+		if err != nil {
+			h.log.Error("export failed", zap.Error(err))
+			return
+		}
+
+		rows := 0
+		err = h.s.ExportReports(exportCtx, &query, func(report *reports.Report) error {
+			err := enc.Encode(report)
+			if err != nil {
+				return err
+			}
+
+			rows++
+			if rows%exportFlushEvery == 0 {
+				return w.Flush()
+			}
+
+			return nil
+		})
+		if err != nil {
+			h.log.Error("export failed", zap.Error(err), zap.Int("rows", rows))
+			return
+		}
+
+		err = enc.End()
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			h.log.Error("export failed", zap.Error(err), zap.Int("rows", rows))
+		}
+	})
+
+	return nil
+}
+
+func (h *reportsHandler) UploadAttachment(ctx *fiber.Ctx) error {
+	report, current, err := h.editableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		return errors.ErrorBadRequest(reports.ErrInvalidAttachment)
+	}
+
+	cmd := reports.AddAttachmentCommand{
+		ReportID:    report.ID,
+		FileName:    filepath.Base(file.Filename),
+		ContentType: file.Header.Get(fiber.HeaderContentType),
+		Size:        file.Size,
+		UploadedBy:  current.ID,
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		return errors.ErrorBadRequest(reports.ErrInvalidAttachment)
+	}
+	defer content.Close()
+
+	result, err := h.s.AddAttachment(ctx.Context(), &cmd, content)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"attachment data": result,
+	})
+}
+
+func (h *reportsHandler) GetAttachments(ctx *fiber.Ctx) error {
+	report, _, err := h.readableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.GetAttachments(ctx.Context(), report.ID)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"attachments": result,
+	})
+}
+
+func (h *reportsHandler) DownloadAttachment(ctx *fiber.Ctx) error {
+	report, _, err := h.readableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := reportParam(ctx, "attachmentID")
+	if err != nil {
+		return err
+	}
+
+	attachment, err := h.s.GetByAttachmentID(ctx.Context(), report.ID, id)
+	if err != nil {
+		return reportError(err)
+	}
+
+	content, err := h.s.OpenAttachment(ctx.Context(), attachment)
+	if err != nil {
+		return reportError(err)
+	}
+
+	// Attachment guesses a content type from the file name; the stored one wins
+	ctx.Attachment(attachment.FileName)
+	ctx.Set(fiber.HeaderContentType, attachment.ContentType)
+	ctx.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+
+	// The stream is closed once the response has been sent
+	return ctx.SendStream(content, int(attachment.Size))
+}
+
+func (h *reportsHandler) DeleteAttachment(ctx *fiber.Ctx) error {
+	report, _, err := h.editableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := reportParam(ctx, "attachmentID")
+	if err != nil {
+		return err
+	}
+
+	err = h.s.DeleteAttachment(ctx.Context(), report.ID, id)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "attachment deleted successfully!",
+	})
+}
+
+func (h *reportsHandler) SearchComment(ctx *fiber.Ctx) error {
+	var query reports.SearchCommentQuery
+
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return err
+	}
+
+	report, _, err := h.readableReport(ctx)
+	if err != nil {
+		return err
+	}
+
+	query.ReportID = report.ID
+
+	result, err := h.s.SearchComment(ctx.Context(), &query)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+func (h *reportsHandler) CreateComment(ctx *fiber.Ctx) error {
+	var cmd reports.CreateCommentCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	report, current, err := h.authorizedReport(ctx, accesscontrol.PermissionCommentReport)
+	if err != nil {
+		return err
+	}
+
+	cmd.ReportID = report.ID
+	cmd.AuthorID = current.ID
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.CreateComment(ctx.Context(), &cmd)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"comment data": cmd,
+	})
+}
+
+func (h *reportsHandler) UpdateComment(ctx *fiber.Ctx) error {
+	var cmd reports.UpdateCommentCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	report, current, err := h.authorizedReport(ctx, accesscontrol.PermissionCommentReport)
+	if err != nil {
+		return err
+	}
+
+	cmd.ReportID = report.ID
+	cmd.ID, err = reportParam(ctx, "commentID")
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	comment, err := h.s.GetByCommentID(ctx.Context(), cmd.ReportID, cmd.ID)
+	if err != nil {
+		return reportError(err)
+	}
+
+	// Comments can only be edited by whoever wrote them
+	if comment.AuthorID != current.ID {
+		return reportError(reports.ErrAccessDenied)
+	}
+
+	err = h.s.UpdateComment(ctx.Context(), &cmd)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"comment data": cmd,
+	})
+}
+
+func (h *reportsHandler) DeleteComment(ctx *fiber.Ctx) error {
+	report, current, err := h.authorizedReport(ctx, accesscontrol.PermissionCommentReport)
+	if err != nil {
+		return err
+	}
+
+	id, err := reportParam(ctx, "commentID")
+	if err != nil {
+		return err
+	}
+
+	comment, err := h.s.GetByCommentID(ctx.Context(), report.ID, id)
+	if err != nil {
+		return reportError(err)
+	}
+
+	// Authors delete their own comments; admins may delete any of them
+	err = h.access.Authorize(ctx.Context(), subject(ctx, current), accesscontrol.PermissionCommentReport, commentResource(comment))
+	if err != nil {
+		return reportError(err)
+	}
+
+	err = h.s.DeleteComment(ctx.Context(), report.ID, id)
+	if err != nil {
+		return reportError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "comment deleted successfully!",
+	})
+}
@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/api/response"
+	"amg/internal/scheduler"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type schedulesHandler struct {
+	s scheduler.Service
+}
+
+func NewSchedulesHandler(s scheduler.Service) *schedulesHandler {
+	return &schedulesHandler{
+		s: s,
+	}
+}
+
+func scheduleError(err error) error {
+	switch err {
+	case scheduler.ErrScheduleNotFound, scheduler.ErrRunNotFound:
+		return errors.ErrorNotFound(err)
+	case scheduler.ErrInvalidSpec:
+		return errors.ErrorBadRequest(err)
+	}
+	return errors.ErrorInternalServerError(err)
+}
+
+func scheduleParam(ctx *fiber.Ctx, name string) (int64, error) {
+	id, err := ctx.ParamsInt(name)
+	if err != nil || id <= 0 {
+		return 0, errors.ErrorBadRequest(scheduler.ErrInvalidID)
+	}
+
+	return int64(id), nil
+}
+
+func (h *schedulesHandler) CreateSchedule(ctx *fiber.Ctx) error {
+	var cmd scheduler.CreateScheduleCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.CreateSchedule(ctx.Context(), &cmd)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return response.Created(ctx, fiber.Map{
+		"schedule data": cmd,
+	})
+}
+
+func (h *schedulesHandler) GetByScheduleID(ctx *fiber.Ctx) error {
+	id, err := scheduleParam(ctx, "id")
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.GetByScheduleID(ctx.Context(), id)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"schedule data": result,
+	})
+}
+
+func (h *schedulesHandler) UpdateSchedule(ctx *fiber.Ctx) error {
+	var cmd scheduler.UpdateScheduleCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, err = scheduleParam(ctx, "id")
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.UpdateSchedule(ctx.Context(), &cmd)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"schedule data": cmd,
+	})
+}
+
+func (h *schedulesHandler) SearchSchedule(ctx *fiber.Ctx) error {
+	var query scheduler.SearchScheduleQuery
+
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.SearchSchedule(ctx.Context(), &query)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+func (h *schedulesHandler) DeleteSchedule(ctx *fiber.Ctx) error {
+	id, err := scheduleParam(ctx, "id")
+	if err != nil {
+		return err
+	}
+
+	err = h.s.DeleteSchedule(ctx.Context(), id)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "schedule deleted successfully!",
+	})
+}
+
+func (h *schedulesHandler) SearchRun(ctx *fiber.Ctx) error {
+	var query scheduler.SearchRunQuery
+
+	err := ctx.QueryParser(&query)
+	if err != nil {
+		return err
+	}
+
+	query.ScheduleID, err = scheduleParam(ctx, "id")
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.SearchRun(ctx.Context(), &query)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+func (h *schedulesHandler) GetByRunID(ctx *fiber.Ctx) error {
+	scheduleID, err := scheduleParam(ctx, "id")
+	if err != nil {
+		return err
+	}
+
+	runID, err := scheduleParam(ctx, "runID")
+	if err != nil {
+		return err
+	}
+
+	result, err := h.s.GetByRunID(ctx.Context(), scheduleID, runID)
+	if err != nil {
+		return scheduleError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"run data": result,
+	})
+}
@@ -3,42 +3,70 @@ package rest
 import (
 	"amg/internal/api/errors"
 	"amg/internal/api/response"
+	"amg/internal/identity/accesscontrol"
+	"amg/internal/identity/organization"
 	"amg/internal/identity/user"
+	"math"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type userHandler struct {
-	s user.Service
+	s      user.Service
+	access accesscontrol.Service
 }
 
-func NewUserHandler(s user.Service) *userHandler {
+func NewUserHandler(s user.Service, access accesscontrol.Service) *userHandler {
 	return &userHandler{
-		s: s,
+		s:      s,
+		access: access,
 	}
 }
 
-func (h *userHandler) CreateUser(ctx *fiber.Ctx) error {
-	var cmd user.CreateUserCommand
+// currentUser resolves the authenticated user from the JWT locals
+func (h *userHandler) currentUser(ctx *fiber.Ctx) (*user.User, error) {
+	id, _ := ctx.Locals("userID").(int64)
 
-	err := ctx.BodyParser(&cmd)
+	result, err := h.s.GetByUserID(ctx.Context(), id)
 	if err != nil {
-		return err
+		if err == user.ErrUserNotFound {
+			return nil, errors.ErrorUnauthorized(err, "Invalid or expired JWT")
+		}
+		return nil, errors.ErrorInternalServerError(err)
 	}
 
-	err = cmd.Validate()
+	return result, nil
+}
+
+// authorizeUser checks the policies for permission on the user userID on behalf
+// of the caller. It runs before the user is loaded, so that the response does not
+// tell who exists.
+func (h *userHandler) authorizeUser(ctx *fiber.Ctx, permission string, userID int64) error {
+	current, err := h.currentUser(ctx)
 	if err != nil {
-		return errors.ErrorBadRequest(err)
+		return err
 	}
 
-	err = h.s.CreateUser(ctx.Context(), &cmd)
+	err = h.access.Authorize(ctx.Context(), subject(ctx, current), permission, userResource(&user.User{ID: userID}))
 	if err != nil {
+		if err == accesscontrol.ErrAccessDenied {
+			return errors.ErrorForbidden(err)
+		}
 		return errors.ErrorInternalServerError(err)
 	}
 
-	return response.Created(ctx, fiber.Map{
-		"user data": cmd,
-	})
+	return nil
+}
+
+// passwordPolicyError reports the broken password rules as the error data
+func passwordPolicyError(err error) (error, bool) {
+	e, ok := err.(*user.PasswordPolicyError)
+	if !ok {
+		return nil, false
+	}
+
+	return errors.NewApiError(err, fiber.StatusBadRequest, e.Error(), e.Violations), true
 }
 
 func (h *userHandler) GetByUserID(ctx *fiber.Ctx) error {
@@ -46,8 +74,16 @@ func (h *userHandler) GetByUserID(ctx *fiber.Ctx) error {
 
 	userID := int64(id)
 
+	err := h.authorizeUser(ctx, accesscontrol.PermissionReadUser, userID)
+	if err != nil {
+		return err
+	}
+
 	result, err := h.s.GetByUserID(ctx.Context(), userID)
 	if err != nil {
+		if err == user.ErrUserNotFound {
+			return errors.ErrorNotFound(err)
+		}
 		return errors.ErrorInternalServerError(err)
 	}
 
@@ -56,21 +92,46 @@ func (h *userHandler) GetByUserID(ctx *fiber.Ctx) error {
 	})
 }
 
+// UpdateUser saves the user named by the route. A body naming another user is
+// refused rather than followed.
 func (h *userHandler) UpdateUser(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return errors.ErrorNotFound(user.ErrUserNotFound)
+	}
+
 	var cmd user.UpdateUserCommand
 
-	err := ctx.BodyParser(&cmd)
+	err = ctx.BodyParser(&cmd)
 	if err != nil {
 		return err
 	}
 
+	if cmd.ID != 0 && cmd.ID != int64(id) {
+		return errors.ErrorBadRequest(user.ErrInvalidID)
+	}
+	cmd.ID = int64(id)
+
 	err = cmd.Validate()
 	if err != nil {
 		return errors.ErrorBadRequest(err)
 	}
 
+	err = h.authorizeUser(ctx, accesscontrol.PermissionUpdateUser, cmd.ID)
+	if err != nil {
+		return err
+	}
+
 	err = h.s.UpdateUser(ctx.Context(), &cmd)
 	if err != nil {
+		switch err {
+		case user.ErrorInvalidRole, user.ErrUserAlreadyExists:
+			return errors.ErrorBadRequest(err)
+		case user.ErrUserNotFound:
+			return errors.ErrorNotFound(err)
+		case user.ErrSharedUser:
+			return errors.ErrorForbidden(err)
+		}
 		return errors.ErrorInternalServerError(err)
 	}
 
@@ -79,6 +140,60 @@ func (h *userHandler) UpdateUser(ctx *fiber.Ctx) error {
 	})
 }
 
+// GetMe returns the profile of the authenticated user
+func (h *userHandler) GetMe(ctx *fiber.Ctx) error {
+	id, _ := ctx.Locals("userID").(int64)
+
+	result, err := h.s.GetByUserID(ctx.Context(), id)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return errors.ErrorUnauthorized(err, "Invalid or expired JWT")
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"user data": result,
+	})
+}
+
+// UpdateMe lets the authenticated user edit their own profile, but not their role
+func (h *userHandler) UpdateMe(ctx *fiber.Ctx) error {
+	var cmd user.UpdateProfileCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, _ = ctx.Locals("userID").(int64)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.UpdateProfile(ctx.Context(), &cmd)
+	if err != nil {
+		switch err {
+		case user.ErrUserNotFound:
+			return errors.ErrorUnauthorized(err, "Invalid or expired JWT")
+		case user.ErrEmailAlreadyExists:
+			return errors.ErrorBadRequest(err)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	result, err := h.s.GetByUserID(ctx.Context(), cmd.ID)
+	if err != nil {
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"user data": result,
+	})
+}
+
 func (h *userHandler) SearchUser(ctx *fiber.Ctx) error {
 	var query user.SearchUserQuery
 
@@ -87,6 +202,11 @@ func (h *userHandler) SearchUser(ctx *fiber.Ctx) error {
 		return err
 	}
 
+	// Only admins see other users, as in the policies of GetByUserID
+	if !isAdmin(ctx) {
+		query.ID, _ = ctx.Locals("userID").(int64)
+	}
+
 	result, err := h.s.SearchUser(ctx.Context(), &query)
 	if err != nil {
 		return errors.ErrorInternalServerError(err)
@@ -96,12 +216,23 @@ func (h *userHandler) SearchUser(ctx *fiber.Ctx) error {
 }
 
 func (h *userHandler) DeleteUser(ctx *fiber.Ctx) error {
-	id, _ := ctx.ParamsInt("id")
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return errors.ErrorNotFound(user.ErrUserNotFound)
+	}
 
 	userID := int64(id)
 
-	err := h.s.DeleteUser(ctx.Context(), userID)
+	err = h.authorizeUser(ctx, accesscontrol.PermissionDeleteUser, userID)
+	if err != nil {
+		return err
+	}
+
+	err = h.s.DeleteUser(ctx.Context(), userID)
 	if err != nil {
+		if err == user.ErrUserNotFound {
+			return errors.ErrorNotFound(err)
+		}
 		return errors.ErrorInternalServerError(err)
 	}
 
@@ -125,6 +256,9 @@ func (h *userHandler) RegisterDefaultUser(ctx *fiber.Ctx) error {
 
 	err = h.s.RegisterDefaultUser(ctx.Context(), &cmd)
 	if err != nil {
+		if e, ok := passwordPolicyError(err); ok {
+			return e
+		}
 		return errors.ErrorInternalServerError(err)
 	}
 
@@ -146,34 +280,200 @@ func (h *userHandler) LoginUser(ctx *fiber.Ctx) error {
 		return errors.ErrorBadRequest(err)
 	}
 
+	cmd.UserAgent = ctx.Get(fiber.HeaderUserAgent)
+	cmd.IPAddress = ctx.IP()
+
 	result, err := h.s.GetUserByEmail(ctx.Context(), &cmd)
 	if err != nil {
-		if err == user.ErrUserNotFound || err == user.ErrInvalidPassword {
+		if e, ok := err.(*user.LoginThrottledError); ok {
+			seconds := int64(math.Ceil(e.RetryAfter.Seconds()))
+			ctx.Set(fiber.HeaderRetryAfter, strconv.FormatInt(seconds, 10))
+			return errors.NewApiError(e.Err, fiber.StatusTooManyRequests, e.Error(), fiber.Map{
+				"code":        e.Err.Code,
+				"retry_after": seconds,
+			})
+		}
+		if err == user.ErrUserNotFound || err == user.ErrInvalidPassword || err == user.ErrServiceAccountLogin {
 			return errors.ErrorUnauthorized(err, "Invalid email or password")
 		}
+		if err == user.ErrEmailNotVerified || err == organization.ErrNotMember {
+			return errors.ErrorForbidden(err)
+		}
 		return errors.ErrorInternalServerError(err)
 	}
 
 	return response.Ok(ctx, result)
 }
 
-func (h *userHandler) LogoutUser(ctx *fiber.Ctx) error {
-	token := ctx.Get("Authorization")
+func (h *userHandler) ForgotPassword(ctx *fiber.Ctx) error {
+	var cmd user.ForgotPasswordCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.ForgotPassword(ctx.Context(), &cmd)
+	if err != nil {
+		return errors.ErrorInternalServerError(err)
+	}
+
+	// Same answer whether or not the address has an account
+	return response.Ok(ctx, fiber.Map{
+		"message": "if the email belongs to an account, a reset link has been sent",
+	})
+}
 
-	if token == "" {
-		return &fiber.Error{
-			Code:    fiber.StatusBadRequest,
-			Message: "No token provided",
+func (h *userHandler) ResetPassword(ctx *fiber.Ctx) error {
+	var cmd user.ResetPasswordCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.ResetPassword(ctx.Context(), &cmd)
+	if err != nil {
+		if e, ok := passwordPolicyError(err); ok {
+			return e
+		}
+		switch err {
+		case user.ErrInvalidAccountToken, user.ErrPasswordReused:
+			return errors.ErrorBadRequest(err)
 		}
+		return errors.ErrorInternalServerError(err)
 	}
 
-	// Remove "Bearer " prefix if present
-	if len(token) > 7 && token[:7] == "Bearer " {
-		token = token[7:]
+	return response.Ok(ctx, fiber.Map{
+		"message": "password reset successfully!",
+	})
+}
+
+// ChangePassword sets a new password for the authenticated user, who must give
+// the current one. Other sessions are signed out, the current one stays.
+func (h *userHandler) ChangePassword(ctx *fiber.Ctx) error {
+	var cmd user.ChangePasswordCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.ID, _ = ctx.Locals("userID").(int64)
+	cmd.SessionID, _ = ctx.Locals("sessionID").(string)
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
 	}
 
-	err := h.s.InvalidateToken(ctx.Context(), token)
+	err = h.s.ChangePassword(ctx.Context(), &cmd)
 	if err != nil {
+		if e, ok := passwordPolicyError(err); ok {
+			return e
+		}
+		switch err {
+		case user.ErrInvalidCurrentPassword, user.ErrPasswordReused:
+			return errors.ErrorBadRequest(err)
+		case user.ErrUserNotFound:
+			return errors.ErrorUnauthorized(err, "Invalid or expired JWT")
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "password changed successfully!",
+	})
+}
+
+func (h *userHandler) VerifyEmail(ctx *fiber.Ctx) error {
+	var cmd user.VerifyEmailCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	err = h.s.VerifyEmail(ctx.Context(), &cmd)
+	if err != nil {
+		if err == user.ErrInvalidAccountToken {
+			return errors.ErrorBadRequest(err)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "email verified successfully!",
+	})
+}
+
+// ResendVerificationEmail mails a new verification link to the authenticated user
+func (h *userHandler) ResendVerificationEmail(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+
+	err := h.s.SendVerificationEmail(ctx.Context(), userID)
+	if err != nil {
+		switch err {
+		case user.ErrEmailAlreadyVerified:
+			return errors.ErrorBadRequest(err)
+		case user.ErrUserNotFound:
+			return errors.ErrorUnauthorized(err, "Invalid or expired JWT")
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "verification email sent",
+	})
+}
+
+func (h *userHandler) RefreshToken(ctx *fiber.Ctx) error {
+	var cmd user.RefreshTokenCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorUnauthorized(err, err.Error())
+	}
+
+	result, err := h.s.RefreshToken(ctx.Context(), &cmd)
+	if err != nil {
+		if err == user.ErrInvalidRefreshToken || err == user.ErrRefreshTokenReused {
+			return errors.ErrorUnauthorized(err, err.Error())
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+// LogoutUser ends the session of the presented token, which invalidates its
+// access and refresh tokens
+func (h *userHandler) LogoutUser(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+	sessionID, _ := ctx.Locals("sessionID").(string)
+
+	err := h.s.RevokeSession(ctx.Context(), userID, sessionID)
+	if err != nil && err != user.ErrSessionNotFound {
 		return errors.ErrorInternalServerError(err)
 	}
 
@@ -181,3 +481,124 @@ func (h *userHandler) LogoutUser(ctx *fiber.Ctx) error {
 		"message": "user logged out successfully!",
 	})
 }
+
+// GetMySessions lists the active sessions of the authenticated user
+func (h *userHandler) GetMySessions(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+	sessionID, _ := ctx.Locals("sessionID").(string)
+
+	result, err := h.s.GetSessions(ctx.Context(), userID, sessionID)
+	if err != nil {
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"sessions": result,
+	})
+}
+
+// RevokeMySession ends one of the sessions of the authenticated user
+func (h *userHandler) RevokeMySession(ctx *fiber.Ctx) error {
+	userID, _ := ctx.Locals("userID").(int64)
+
+	err := h.s.RevokeSession(ctx.Context(), userID, ctx.Params("sessionID"))
+	if err != nil {
+		switch err {
+		case user.ErrSessionNotFound:
+			return errors.ErrorNotFound(err)
+		case user.ErrInvalidSessionID:
+			return errors.ErrorBadRequest(err)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "session revoked successfully!",
+	})
+}
+
+// SwitchOrganization ends the current session and returns the tokens of a new
+// one acting in another organization of the user
+func (h *userHandler) SwitchOrganization(ctx *fiber.Ctx) error {
+	var cmd user.SwitchOrganizationCommand
+
+	err := ctx.BodyParser(&cmd)
+	if err != nil {
+		return err
+	}
+
+	cmd.UserID, _ = ctx.Locals("userID").(int64)
+	cmd.SessionID, _ = ctx.Locals("sessionID").(string)
+	cmd.UserAgent = ctx.Get(fiber.HeaderUserAgent)
+	cmd.IPAddress = ctx.IP()
+
+	err = cmd.Validate()
+	if err != nil {
+		return errors.ErrorBadRequest(err)
+	}
+
+	result, err := h.s.SwitchOrganization(ctx.Context(), &cmd)
+	if err != nil {
+		if err == organization.ErrNotMember {
+			return errors.ErrorForbidden(err)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, result)
+}
+
+// GetUserSessions lists the active sessions of any user, for admins
+func (h *userHandler) GetUserSessions(ctx *fiber.Ctx) error {
+	id, _ := ctx.ParamsInt("id")
+
+	result, err := h.s.GetSessions(ctx.Context(), int64(id), "")
+	if err != nil {
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"sessions": result,
+	})
+}
+
+// RevokeUserSessions logs a user out everywhere
+func (h *userHandler) RevokeUserSessions(ctx *fiber.Ctx) error {
+	id, _ := ctx.ParamsInt("id")
+
+	userID := int64(id)
+
+	_, err := h.s.GetByUserID(ctx.Context(), userID)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return errors.ErrorNotFound(err)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	err = h.s.RevokeAllSessions(ctx.Context(), userID)
+	if err != nil {
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "user logged out everywhere successfully!",
+	})
+}
+
+// UnlockUser lifts a login lockout of a user before it expires
+func (h *userHandler) UnlockUser(ctx *fiber.Ctx) error {
+	id, _ := ctx.ParamsInt("id")
+
+	err := h.s.UnlockUser(ctx.Context(), int64(id))
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			return errors.ErrorNotFound(err)
+		}
+		return errors.ErrorInternalServerError(err)
+	}
+
+	return response.Ok(ctx, fiber.Map{
+		"message": "user unlocked successfully!",
+	})
+}
@@ -0,0 +1,174 @@
+package export
+
+import (
+	"amg/internal/api/errors"
+	"amg/internal/identity/reports"
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("report.unsupported-export-format", "Unsupported export format")
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatTSV  = "tsv"
+	FormatHTML = "html"
+	FormatJSON = "json"
+)
+
+var contentTypes = map[string]string{
+	FormatCSV:  "text/csv; charset=utf-8",
+	FormatTSV:  "text/tab-separated-values; charset=utf-8",
+	FormatHTML: "text/html; charset=utf-8",
+	FormatJSON: "application/json; charset=utf-8",
+}
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// columns is the header row shared by the delimited formats
+var columns = []string{"id", "title", "body", "author_id", "status", "created_at", "updated_at"}
+
+// Encoder writes reports one at a time so exports never need the full result set in memory
+type Encoder interface {
+	Begin() error
+	Encode(report *reports.Report) error
+	End() error
+}
+
+func IsValidFormat(format string) bool {
+	_, ok := contentTypes[format]
+	return ok
+}
+
+// ContentType returns the HTTP content type for the format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// FileName returns the attachment file name for an export in the given format
+func FileName(name, format string) string {
+	return name + "." + format
+}
+
+// NewEncoder returns an Encoder writing the given format to w
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return &delimitedEncoder{w: csv.NewWriter(w)}, nil
+	case FormatTSV:
+		cw := csv.NewWriter(w)
+		cw.Comma = '\t'
+		return &delimitedEncoder{w: cw}, nil
+	case FormatHTML:
+		return &htmlEncoder{w: w}, nil
+	case FormatJSON:
+		return &jsonEncoder{w: w}, nil
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+type delimitedEncoder struct {
+	w *csv.Writer
+}
+
+func (e *delimitedEncoder) Begin() error {
+	return e.w.Write(columns)
+}
+
+func (e *delimitedEncoder) Encode(report *reports.Report) error {
+	err := e.w.Write([]string{
+		strconv.FormatInt(report.ID, 10),
+		escapeFormula(report.Title),
+		escapeFormula(report.Body),
+		strconv.FormatInt(report.AuthorID, 10),
+		report.Status,
+		report.CreatedAt,
+		report.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Flush per row so the data reaches the client as it is produced
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *delimitedEncoder) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeFormula keeps spreadsheets from running user text as a formula by
+// prefixing cells that start like one with a quote
+func escapeFormula(cell string) string {
+	if len(cell) > 0 && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+
+	return cell
+}
+
+type htmlEncoder struct {
+	w io.Writer
+}
+
+func (e *htmlEncoder) Begin() error {
+	return templates.ExecuteTemplate(e.w, "header", map[string]interface{}{
+		"GeneratedAt": time.Now().Format(time.RFC1123),
+	})
+}
+
+func (e *htmlEncoder) Encode(report *reports.Report) error {
+	return templates.ExecuteTemplate(e.w, "report", report)
+}
+
+func (e *htmlEncoder) End() error {
+	return templates.ExecuteTemplate(e.w, "footer", nil)
+}
+
+// jsonEncoder writes a JSON array element by element
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonEncoder) Begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonEncoder) Encode(report *reports.Report) error {
+	if e.count > 0 {
+		_, err := io.WriteString(e.w, ",")
+		if err != nil {
+			return err
+		}
+	}
+	e.count++
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) End() error {
+	_, err := io.WriteString(e.w, "]")
+	return err
+}
@@ -1 +1,451 @@
 package reports
+
+import (
+	"amg/internal/api/errors"
+	"amg/pkg/util/diff"
+	"strings"
+	"time"
+)
+
+var (
+	ErrReportNotFound = errors.New("report.not-found", "Report not found")
+	ErrInvalidID      = errors.New("report.invalid-id", "Invalid id")
+	ErrInvalidTitle   = errors.New("report.invalid-title", "Invalid title")
+	ErrInvalidBody    = errors.New("report.invalid-body", "Invalid body")
+	ErrInvalidAuthor  = errors.New("report.invalid-author", "Invalid author")
+	ErrInvalidStatus  = errors.New("report.invalid-status", "Invalid status")
+	ErrAccessDenied   = errors.New("report.access-denied", "Access denied")
+
+	ErrInvalidTransition = errors.New("report.invalid-transition", "Invalid status transition")
+	ErrCommentRequired   = errors.New("report.comment-required", "Comment is required")
+	ErrNotEditable       = errors.New("report.not-editable", "Report can no longer be edited")
+
+	ErrInvalidDateRange = errors.New("report.invalid-date-range", "Invalid date range")
+	ErrInvalidInterval  = errors.New("report.invalid-interval", "Invalid interval")
+
+	ErrAttachmentNotFound       = errors.New("report.attachment-not-found", "Attachment not found")
+	ErrInvalidAttachment        = errors.New("report.invalid-attachment", "Invalid attachment")
+	ErrAttachmentTooLarge       = errors.New("report.attachment-too-large", "Attachment is too large")
+	ErrAttachmentTypeNotAllowed = errors.New("report.attachment-type-not-allowed", "Attachment type is not allowed")
+
+	ErrCommentNotFound = errors.New("report.comment-not-found", "Comment not found")
+	ErrInvalidComment  = errors.New("report.invalid-comment", "Invalid comment")
+	ErrInvalidParent   = errors.New("report.invalid-parent-comment", "Invalid parent comment")
+
+	ErrRevisionNotFound = errors.New("report.revision-not-found", "Revision not found")
+	ErrInvalidRevision  = errors.New("report.invalid-revision", "Invalid revision")
+)
+
+const (
+	StatusDraft     = "draft"
+	StatusSubmitted = "submitted"
+	StatusReviewed  = "reviewed"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+)
+
+type Report struct {
+	ID         int64  `db:"id" json:"id"`
+	Title      string `db:"title" json:"title"`
+	Body       string `db:"body" json:"body"`
+	AuthorID   int64  `db:"author_id" json:"author_id"`
+	TemplateID *int64 `db:"template_id" json:"template_id"`
+	Status     string `db:"status" json:"status"`
+	CreatedAt  string `db:"created_at" json:"created_at"`
+	UpdatedAt  string `db:"updated_at" json:"updated_at"`
+
+	// AuthorTeam is the team of the author, used for authorization. It is only
+	// loaded for single reports.
+	AuthorTeam string `db:"author_team" json:"-"`
+
+	// Rank and Snippet are only set by a full-text search. Snippet is HTML escaped
+	// with the matched terms wrapped in <mark> tags.
+	Rank    float64 `db:"rank" json:"rank,omitempty"`
+	Snippet string  `db:"snippet" json:"snippet,omitempty"`
+}
+
+var validStatuses = map[string]bool{
+	StatusDraft:     true,
+	StatusSubmitted: true,
+	StatusReviewed:  true,
+	StatusApproved:  true,
+	StatusRejected:  true,
+}
+
+func IsValidStatus(status string) bool {
+	return validStatuses[status]
+}
+
+// transitions lists, for every status, the statuses a report may move to next.
+// Approved is terminal; a rejected report can be reworked and submitted again.
+var transitions = map[string]map[string]bool{
+	StatusDraft: {
+		StatusSubmitted: true,
+	},
+	StatusSubmitted: {
+		StatusReviewed: true,
+		StatusApproved: true,
+		StatusRejected: true,
+	},
+	StatusReviewed: {
+		StatusApproved: true,
+		StatusRejected: true,
+	},
+	StatusRejected: {
+		StatusSubmitted: true,
+	},
+}
+
+// CanTransition reports whether a report in status from may move to status to
+func CanTransition(from, to string) bool {
+	return transitions[from][to]
+}
+
+// IsEditable reports whether a report in the given status may still be edited by its author
+func IsEditable(status string) bool {
+	return status == StatusDraft || status == StatusRejected
+}
+
+type CreateReportCommand struct {
+	ID         int64  `json:"id"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	AuthorID   int64  `json:"author_id"`
+	TemplateID *int64 `json:"template_id"`
+}
+
+type UpdateReportCommand struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	EditorID int64  `json:"editor_id"`
+}
+
+// SearchReportQuery filters reports. Q switches to full-text search over title and
+// body, ordered by relevance; it accepts web search syntax such as "exact phrase",
+// or and -excluded.
+type SearchReportQuery struct {
+	Q             string `query:"q"`
+	Title         string `query:"title"`
+	AuthorID      int64  `query:"author_id"`
+	Status        string `query:"status"`
+	ExcludeDrafts bool   `query:"-"`
+	// AuthorTeam limits the search to reports whose author is in the team
+	AuthorTeam *string `query:"-"`
+	Page       int     `query:"page"`
+	PerPage    int     `query:"per_page"`
+}
+
+type SearchReportResult struct {
+	TotalCount int64     `json:"total_count"`
+	Reports    []*Report `json:"reports"`
+	Page       int       `json:"page"`
+	PerPage    int       `json:"per_page"`
+}
+
+func (cmd *CreateReportCommand) Validate() error {
+	if len(strings.TrimSpace(cmd.Title)) == 0 || len(cmd.Title) > 255 {
+		return ErrInvalidTitle
+	}
+	if len(strings.TrimSpace(cmd.Body)) == 0 {
+		return ErrInvalidBody
+	}
+	if cmd.AuthorID <= 0 {
+		return ErrInvalidAuthor
+	}
+	if cmd.TemplateID != nil && *cmd.TemplateID <= 0 {
+		return ErrInvalidTemplateID
+	}
+
+	return nil
+}
+
+func (cmd *UpdateReportCommand) Validate() error {
+	if cmd.ID <= 0 {
+		return ErrInvalidID
+	}
+	if len(strings.TrimSpace(cmd.Title)) == 0 || len(cmd.Title) > 255 {
+		return ErrInvalidTitle
+	}
+	if len(strings.TrimSpace(cmd.Body)) == 0 {
+		return ErrInvalidBody
+	}
+	if cmd.EditorID <= 0 {
+		return ErrInvalidAuthor
+	}
+
+	return nil
+}
+
+// Revision is an immutable snapshot of a report's title and body. Revision 1 is
+// the report as created; every update adds the next one.
+type Revision struct {
+	ID        int64  `db:"id" json:"id"`
+	ReportID  int64  `db:"report_id" json:"report_id"`
+	Revision  int    `db:"revision" json:"revision"`
+	Title     string `db:"title" json:"title"`
+	Body      string `db:"body" json:"body"`
+	EditorID  int64  `db:"editor_id" json:"editor_id"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
+
+// RevisionDiff is the line diff of the body going from revision From to revision To
+type RevisionDiff struct {
+	ReportID  int64       `json:"report_id"`
+	From      int         `json:"from"`
+	To        int         `json:"to"`
+	FromTitle string      `json:"from_title"`
+	ToTitle   string      `json:"to_title"`
+	Lines     []diff.Line `json:"lines"`
+}
+
+// RestoreRevisionCommand copies an earlier revision back into the report, which
+// records it as a new revision
+type RestoreRevisionCommand struct {
+	ReportID int64 `json:"report_id"`
+	Revision int   `json:"revision"`
+	EditorID int64 `json:"editor_id"`
+}
+
+func (cmd *RestoreRevisionCommand) Validate() error {
+	if cmd.ReportID <= 0 {
+		return ErrInvalidID
+	}
+	if cmd.Revision <= 0 {
+		return ErrInvalidRevision
+	}
+	if cmd.EditorID <= 0 {
+		return ErrInvalidAuthor
+	}
+
+	return nil
+}
+
+type ReportHistory struct {
+	ID         int64  `db:"id" json:"id"`
+	ReportID   int64  `db:"report_id" json:"report_id"`
+	FromStatus string `db:"from_status" json:"from_status"`
+	ToStatus   string `db:"to_status" json:"to_status"`
+	ActorID    int64  `db:"actor_id" json:"actor_id"`
+	Comment    string `db:"comment" json:"comment"`
+	CreatedAt  string `db:"created_at" json:"created_at"`
+}
+
+type TransitionReportCommand struct {
+	ReportID int64  `json:"report_id"`
+	ActorID  int64  `json:"actor_id"`
+	Status   string `json:"status"`
+	Comment  string `json:"comment"`
+}
+
+func (cmd *TransitionReportCommand) Validate() error {
+	if cmd.ReportID <= 0 {
+		return ErrInvalidID
+	}
+	if cmd.ActorID <= 0 {
+		return ErrInvalidAuthor
+	}
+	if !IsValidStatus(cmd.Status) {
+		return ErrInvalidStatus
+	}
+	if cmd.Status == StatusRejected && len(strings.TrimSpace(cmd.Comment)) == 0 {
+		return ErrCommentRequired
+	}
+
+	return nil
+}
+
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+
+	// DateLayout is the format of the from/to parameters of the stats endpoint
+	DateLayout = "2006-01-02"
+
+	// DefaultStatsRange is the range used when the stats query omits from
+	DefaultStatsRange = 30 * 24 * time.Hour
+)
+
+var validIntervals = map[string]bool{
+	IntervalDay:   true,
+	IntervalWeek:  true,
+	IntervalMonth: true,
+}
+
+type ReportStatsQuery struct {
+	From     string `query:"from"`
+	To       string `query:"to"`
+	Interval string `query:"interval"`
+
+	FromDate time.Time `query:"-"`
+	ToDate   time.Time `query:"-"`
+}
+
+type StatusCount struct {
+	Status string `db:"status" json:"status"`
+	Count  int64  `db:"count" json:"count"`
+}
+
+type AuthorCount struct {
+	AuthorID  int64  `db:"author_id" json:"author_id"`
+	FirstName string `db:"first_name" json:"first_name"`
+	LastName  string `db:"last_name" json:"last_name"`
+	Count     int64  `db:"count" json:"count"`
+}
+
+type PeriodCount struct {
+	Period string `db:"period" json:"period"`
+	Count  int64  `db:"count" json:"count"`
+}
+
+type ReportStats struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Interval   string         `json:"interval"`
+	TotalCount int64          `json:"total_count"`
+	ByStatus   []*StatusCount `json:"by_status"`
+	ByAuthor   []*AuthorCount `json:"by_author"`
+	ByPeriod   []*PeriodCount `json:"by_period"`
+}
+
+// Validate fills in the defaults (the last 30 days, grouped by day) and parses the
+// requested range into FromDate and ToDate. Both ends of the range are inclusive.
+func (query *ReportStatsQuery) Validate() error {
+	if len(query.Interval) == 0 {
+		query.Interval = IntervalDay
+	}
+	if !validIntervals[query.Interval] {
+		return ErrInvalidInterval
+	}
+
+	var err error
+
+	if len(query.To) == 0 {
+		query.ToDate = time.Now().UTC().Truncate(24 * time.Hour)
+	} else {
+		query.ToDate, err = time.Parse(DateLayout, query.To)
+		if err != nil {
+			return ErrInvalidDateRange
+		}
+	}
+
+	if len(query.From) == 0 {
+		query.FromDate = query.ToDate.Add(-DefaultStatsRange)
+	} else {
+		query.FromDate, err = time.Parse(DateLayout, query.From)
+		if err != nil {
+			return ErrInvalidDateRange
+		}
+	}
+
+	if query.FromDate.After(query.ToDate) {
+		return ErrInvalidDateRange
+	}
+
+	query.From = query.FromDate.Format(DateLayout)
+	query.To = query.ToDate.Format(DateLayout)
+
+	return nil
+}
+
+type Attachment struct {
+	ID          int64  `db:"id" json:"id"`
+	ReportID    int64  `db:"report_id" json:"report_id"`
+	FileName    string `db:"file_name" json:"file_name"`
+	ContentType string `db:"content_type" json:"content_type"`
+	Size        int64  `db:"size" json:"size"`
+	StorageKey  string `db:"storage_key" json:"-"`
+	UploadedBy  int64  `db:"uploaded_by" json:"uploaded_by"`
+	CreatedAt   string `db:"created_at" json:"created_at"`
+}
+
+type AddAttachmentCommand struct {
+	ReportID    int64  `json:"report_id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	UploadedBy  int64  `json:"uploaded_by"`
+}
+
+func (cmd *AddAttachmentCommand) Validate() error {
+	if cmd.ReportID <= 0 {
+		return ErrInvalidID
+	}
+	if cmd.UploadedBy <= 0 {
+		return ErrInvalidAuthor
+	}
+	if len(strings.TrimSpace(cmd.FileName)) == 0 || len(cmd.FileName) > 255 || cmd.Size <= 0 {
+		return ErrInvalidAttachment
+	}
+
+	return nil
+}
+
+type Comment struct {
+	ID        int64      `db:"id" json:"id"`
+	ReportID  int64      `db:"report_id" json:"report_id"`
+	ParentID  *int64     `db:"parent_id" json:"parent_id"`
+	AuthorID  int64      `db:"author_id" json:"author_id"`
+	Body      string     `db:"body" json:"body"`
+	CreatedAt string     `db:"created_at" json:"created_at"`
+	UpdatedAt string     `db:"updated_at" json:"updated_at"`
+	Replies   []*Comment `db:"-" json:"replies"`
+}
+
+type CreateCommentCommand struct {
+	ID       int64  `json:"id"`
+	ReportID int64  `json:"report_id"`
+	ParentID *int64 `json:"parent_id"`
+	AuthorID int64  `json:"author_id"`
+	Body     string `json:"body"`
+}
+
+type UpdateCommentCommand struct {
+	ID       int64  `json:"id"`
+	ReportID int64  `json:"report_id"`
+	Body     string `json:"body"`
+}
+
+// SearchCommentQuery pages through the top-level comments of a report; every
+// page carries the complete reply threads of its comments
+type SearchCommentQuery struct {
+	ReportID int64 `query:"-"`
+	Page     int   `query:"page"`
+	PerPage  int   `query:"per_page"`
+}
+
+type SearchCommentResult struct {
+	TotalCount int64      `json:"total_count"`
+	Comments   []*Comment `json:"comments"`
+	Page       int        `json:"page"`
+	PerPage    int        `json:"per_page"`
+}
+
+func (cmd *CreateCommentCommand) Validate() error {
+	if cmd.ReportID <= 0 {
+		return ErrInvalidID
+	}
+	if cmd.AuthorID <= 0 {
+		return ErrInvalidAuthor
+	}
+	if cmd.ParentID != nil && *cmd.ParentID <= 0 {
+		return ErrInvalidParent
+	}
+	if len(strings.TrimSpace(cmd.Body)) == 0 || len(cmd.Body) > 10000 {
+		return ErrInvalidComment
+	}
+
+	return nil
+}
+
+func (cmd *UpdateCommentCommand) Validate() error {
+	if cmd.ID <= 0 || cmd.ReportID <= 0 {
+		return ErrInvalidID
+	}
+	if len(strings.TrimSpace(cmd.Body)) == 0 || len(cmd.Body) > 10000 {
+		return ErrInvalidComment
+	}
+
+	return nil
+}
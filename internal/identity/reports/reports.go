@@ -1 +1,48 @@
 package reports
+
+import (
+	"context"
+	"io"
+)
+
+type Service interface {
+	CreateReport(ctx context.Context, cmd *CreateReportCommand) error
+	UpdateReport(ctx context.Context, cmd *UpdateReportCommand) error
+	GetByReportID(ctx context.Context, id int64) (*Report, error)
+	DeleteReport(ctx context.Context, id int64) error
+	SearchReport(ctx context.Context, query *SearchReportQuery) (*SearchReportResult, error)
+
+	TransitionReport(ctx context.Context, cmd *TransitionReportCommand) error
+	GetReportHistory(ctx context.Context, reportID int64) ([]*ReportHistory, error)
+
+	GetRevisions(ctx context.Context, reportID int64) ([]*Revision, error)
+	GetRevision(ctx context.Context, reportID int64, revision int) (*Revision, error)
+	// DiffRevisions diffs the body of revision against another revision of the same
+	// report; against 0 means the revision before it
+	DiffRevisions(ctx context.Context, reportID int64, revision, against int) (*RevisionDiff, error)
+	RestoreRevision(ctx context.Context, cmd *RestoreRevisionCommand) error
+
+	// ExportReports calls fn for every report matching the query, in search order,
+	// without paginating or buffering the result set
+	ExportReports(ctx context.Context, query *SearchReportQuery, fn func(*Report) error) error
+
+	GetReportStats(ctx context.Context, query *ReportStatsQuery) (*ReportStats, error)
+
+	AddAttachment(ctx context.Context, cmd *AddAttachmentCommand, content io.Reader) (*Attachment, error)
+	GetAttachments(ctx context.Context, reportID int64) ([]*Attachment, error)
+	GetByAttachmentID(ctx context.Context, reportID, id int64) (*Attachment, error)
+	OpenAttachment(ctx context.Context, attachment *Attachment) (io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, reportID, id int64) error
+
+	CreateComment(ctx context.Context, cmd *CreateCommentCommand) error
+	UpdateComment(ctx context.Context, cmd *UpdateCommentCommand) error
+	GetByCommentID(ctx context.Context, reportID, id int64) (*Comment, error)
+	DeleteComment(ctx context.Context, reportID, id int64) error
+	SearchComment(ctx context.Context, query *SearchCommentQuery) (*SearchCommentResult, error)
+
+	CreateTemplate(ctx context.Context, cmd *CreateTemplateCommand) error
+	UpdateTemplate(ctx context.Context, cmd *UpdateTemplateCommand) error
+	GetByTemplateID(ctx context.Context, id int64) (*Template, error)
+	DeleteTemplate(ctx context.Context, id int64) error
+	SearchTemplate(ctx context.Context, query *SearchTemplateQuery) (*SearchTemplateResult, error)
+}
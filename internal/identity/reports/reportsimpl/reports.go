@@ -1 +1,626 @@
 package reportsimpl
+
+import (
+	"amg/config"
+	"amg/internal/db"
+	"amg/internal/identity/reports"
+	"amg/internal/storage"
+	"amg/pkg/util/diff"
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type service struct {
+	store *store
+	cfg   *config.Config
+	log   *zap.Logger
+	db    db.DB
+	blob  storage.Blob
+}
+
+func NewService(db db.DB, cfg *config.Config) *service {
+	return &service{
+		store: NewStore(db),
+		cfg:   cfg,
+		db:    db,
+		blob:  cfg.Blob,
+		log:   zap.L().Named("reports.service"),
+	}
+}
+
+func (s *service) CreateReport(ctx context.Context, cmd *reports.CreateReportCommand) error {
+	if cmd.TemplateID != nil {
+		err := s.validateAgainstTemplate(ctx, *cmd.TemplateID, cmd.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.store.create(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateAgainstTemplate checks a report body against the schema of its template
+func (s *service) validateAgainstTemplate(ctx context.Context, templateID int64, body string) error {
+	template, err := s.GetByTemplateID(ctx, templateID)
+	if err != nil {
+		return err
+	}
+
+	schema, err := reports.ParseTemplateSchema(template.Schema)
+	if err != nil {
+		return err
+	}
+
+	return schema.ValidateBody(body)
+}
+
+func (s *service) GetByReportID(ctx context.Context, id int64) (*reports.Report, error) {
+	result, err := s.store.getReportByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, reports.ErrReportNotFound
+	}
+
+	return result, nil
+}
+
+func (s *service) UpdateReport(ctx context.Context, cmd *reports.UpdateReportCommand) error {
+	result, err := s.store.getReportByID(ctx, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return reports.ErrReportNotFound
+	}
+
+	if result.TemplateID != nil {
+		err = s.validateAgainstTemplate(ctx, *result.TemplateID, cmd.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.store.update(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) SearchReport(ctx context.Context, query *reports.SearchReportQuery) (*reports.SearchReportResult, error) {
+	if query.Page <= 0 {
+		query.Page = s.cfg.Pagination.Page
+	}
+
+	if query.PerPage <= 0 {
+		query.PerPage = s.cfg.Pagination.PageLimit
+	}
+
+	result, err := s.store.search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PerPage = query.PerPage
+	result.Page = query.Page
+
+	return result, nil
+}
+
+func (s *service) DeleteReport(ctx context.Context, id int64) error {
+	result, err := s.store.getReportByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return reports.ErrReportNotFound
+	}
+
+	// The attachment rows go with the report, so collect the blobs to remove first
+	attachments, err := s.store.getAttachments(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.delete(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		s.deleteBlob(ctx, attachment.StorageKey)
+	}
+
+	return nil
+}
+
+func (s *service) TransitionReport(ctx context.Context, cmd *reports.TransitionReportCommand) error {
+	result, err := s.store.getReportByID(ctx, cmd.ReportID)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return reports.ErrReportNotFound
+	}
+
+	if !reports.CanTransition(result.Status, cmd.Status) {
+		return reports.ErrInvalidTransition
+	}
+
+	err = s.store.transition(ctx, cmd, result.Status)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("report status changed",
+		zap.Int64("report_id", cmd.ReportID),
+		zap.String("from", result.Status),
+		zap.String("to", cmd.Status),
+		zap.Int64("actor_id", cmd.ActorID),
+	)
+
+	return nil
+}
+
+func (s *service) GetReportHistory(ctx context.Context, reportID int64) ([]*reports.ReportHistory, error) {
+	result, err := s.store.getReportByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, reports.ErrReportNotFound
+	}
+
+	return s.store.getHistory(ctx, reportID)
+}
+
+func (s *service) GetRevisions(ctx context.Context, reportID int64) ([]*reports.Revision, error) {
+	result, err := s.store.getReportByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, reports.ErrReportNotFound
+	}
+
+	return s.store.getRevisions(ctx, reportID)
+}
+
+func (s *service) GetRevision(ctx context.Context, reportID int64, revision int) (*reports.Revision, error) {
+	result, err := s.store.getRevision(ctx, reportID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, reports.ErrRevisionNotFound
+	}
+
+	return result, nil
+}
+
+func (s *service) DiffRevisions(ctx context.Context, reportID int64, revision, against int) (*reports.RevisionDiff, error) {
+	if revision <= 0 || against < 0 {
+		return nil, reports.ErrInvalidRevision
+	}
+
+	to, err := s.GetRevision(ctx, reportID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if against == 0 {
+		against = revision - 1
+	}
+
+	// The first revision is compared with an empty report
+	from := &reports.Revision{ReportID: reportID}
+	if against > 0 {
+		from, err = s.GetRevision(ctx, reportID, against)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &reports.RevisionDiff{
+		ReportID:  reportID,
+		From:      against,
+		To:        revision,
+		FromTitle: from.Title,
+		ToTitle:   to.Title,
+		Lines:     diff.Lines(from.Body, to.Body),
+	}, nil
+}
+
+func (s *service) RestoreRevision(ctx context.Context, cmd *reports.RestoreRevisionCommand) error {
+	revision, err := s.GetRevision(ctx, cmd.ReportID, cmd.Revision)
+	if err != nil {
+		return err
+	}
+
+	// Restoring is an ordinary update, so it is checked against the template
+	// and recorded as the newest revision
+	err = s.UpdateReport(ctx, &reports.UpdateReportCommand{
+		ID:       cmd.ReportID,
+		Title:    revision.Title,
+		Body:     revision.Body,
+		EditorID: cmd.EditorID,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("report revision restored",
+		zap.Int64("report_id", cmd.ReportID),
+		zap.Int("revision", cmd.Revision),
+		zap.Int64("editor_id", cmd.EditorID),
+	)
+
+	return nil
+}
+
+func (s *service) ExportReports(ctx context.Context, query *reports.SearchReportQuery, fn func(*reports.Report) error) error {
+	return s.store.iterate(ctx, query, fn)
+}
+
+func (s *service) GetReportStats(ctx context.Context, query *reports.ReportStatsQuery) (*reports.ReportStats, error) {
+	result, err := s.store.getStats(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result.From = query.From
+	result.To = query.To
+	result.Interval = query.Interval
+
+	return result, nil
+}
+
+// attachmentContentType resolves the media type of an upload. The declared type
+// is used when present; otherwise it is sniffed from the first bytes of content.
+func attachmentContentType(declared string, content *bufio.Reader) string {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err == nil && mediaType != "application/octet-stream" {
+		return strings.ToLower(mediaType)
+	}
+
+	head, _ := content.Peek(512)
+	mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+
+	return mediaType
+}
+
+func newStorageKey(reportID int64) (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("reports/%d/%s", reportID, hex.EncodeToString(b)), nil
+}
+
+func (s *service) deleteBlob(ctx context.Context, key string) {
+	err := s.blob.Delete(ctx, key)
+	if err != nil {
+		s.log.Warn("failed to delete attachment blob", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (s *service) AddAttachment(ctx context.Context, cmd *reports.AddAttachmentCommand, content io.Reader) (*reports.Attachment, error) {
+	if cmd.Size > s.cfg.Attachments.MaxSize {
+		return nil, reports.ErrAttachmentTooLarge
+	}
+
+	report, err := s.store.getReportByID(ctx, cmd.ReportID)
+	if err != nil {
+		return nil, err
+	}
+
+	if report == nil {
+		return nil, reports.ErrReportNotFound
+	}
+
+	buffered := bufio.NewReader(content)
+
+	contentType := attachmentContentType(cmd.ContentType, buffered)
+	if !s.cfg.Attachments.AllowedTypes[contentType] {
+		return nil, reports.ErrAttachmentTypeNotAllowed
+	}
+
+	key, err := newStorageKey(cmd.ReportID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read one byte past the limit so that an understated size is still caught
+	size, err := s.blob.Put(ctx, key, io.LimitReader(buffered, s.cfg.Attachments.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if size > s.cfg.Attachments.MaxSize {
+		s.deleteBlob(ctx, key)
+		return nil, reports.ErrAttachmentTooLarge
+	}
+
+	attachment := &reports.Attachment{
+		ReportID:    cmd.ReportID,
+		FileName:    cmd.FileName,
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  key,
+		UploadedBy:  cmd.UploadedBy,
+	}
+
+	err = s.store.createAttachment(ctx, attachment)
+	if err != nil {
+		s.deleteBlob(ctx, key)
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+func (s *service) GetAttachments(ctx context.Context, reportID int64) ([]*reports.Attachment, error) {
+	return s.store.getAttachments(ctx, reportID)
+}
+
+func (s *service) GetByAttachmentID(ctx context.Context, reportID, id int64) (*reports.Attachment, error) {
+	result, err := s.store.getAttachmentByID(ctx, reportID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, reports.ErrAttachmentNotFound
+	}
+
+	return result, nil
+}
+
+func (s *service) OpenAttachment(ctx context.Context, attachment *reports.Attachment) (io.ReadCloser, error) {
+	content, err := s.blob.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, reports.ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+
+	return content, nil
+}
+
+func (s *service) DeleteAttachment(ctx context.Context, reportID, id int64) error {
+	result, err := s.GetByAttachmentID(ctx, reportID, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.deleteAttachment(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	s.deleteBlob(ctx, result.StorageKey)
+
+	return nil
+}
+
+func (s *service) CreateComment(ctx context.Context, cmd *reports.CreateCommentCommand) error {
+	report, err := s.store.getReportByID(ctx, cmd.ReportID)
+	if err != nil {
+		return err
+	}
+
+	if report == nil {
+		return reports.ErrReportNotFound
+	}
+
+	// Replies must stay within the thread of the same report
+	if cmd.ParentID != nil {
+		parent, err := s.store.getCommentByID(ctx, cmd.ReportID, *cmd.ParentID)
+		if err != nil {
+			return err
+		}
+
+		if parent == nil {
+			return reports.ErrInvalidParent
+		}
+	}
+
+	err = s.store.createComment(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) GetByCommentID(ctx context.Context, reportID, id int64) (*reports.Comment, error) {
+	result, err := s.store.getCommentByID(ctx, reportID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, reports.ErrCommentNotFound
+	}
+
+	return result, nil
+}
+
+func (s *service) UpdateComment(ctx context.Context, cmd *reports.UpdateCommentCommand) error {
+	_, err := s.GetByCommentID(ctx, cmd.ReportID, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.updateComment(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) DeleteComment(ctx context.Context, reportID, id int64) error {
+	_, err := s.GetByCommentID(ctx, reportID, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.deleteComment(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) SearchComment(ctx context.Context, query *reports.SearchCommentQuery) (*reports.SearchCommentResult, error) {
+	if query.Page <= 0 {
+		query.Page = s.cfg.Pagination.Page
+	}
+
+	if query.PerPage <= 0 {
+		query.PerPage = s.cfg.Pagination.PageLimit
+	}
+
+	result, err := s.store.searchComments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PerPage = query.PerPage
+	result.Page = query.Page
+
+	return result, nil
+}
+
+func (s *service) CreateTemplate(ctx context.Context, cmd *reports.CreateTemplateCommand) error {
+	result, err := s.store.templateTaken(ctx, 0, cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	if len(result) > 0 {
+		return reports.ErrTemplateExists
+	}
+
+	err = s.store.createTemplate(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) GetByTemplateID(ctx context.Context, id int64) (*reports.Template, error) {
+	result, err := s.store.getTemplateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, reports.ErrTemplateNotFound
+	}
+
+	return result, nil
+}
+
+// UpdateTemplate replaces the template schema. Reports created earlier keep their
+// body; they are checked against the new schema the next time they are edited.
+func (s *service) UpdateTemplate(ctx context.Context, cmd *reports.UpdateTemplateCommand) error {
+	result, err := s.store.templateTaken(ctx, cmd.ID, cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, template := range result {
+		if template.ID == cmd.ID {
+			found = true
+		} else {
+			return reports.ErrTemplateExists
+		}
+	}
+
+	if !found {
+		return reports.ErrTemplateNotFound
+	}
+
+	err = s.store.updateTemplate(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) SearchTemplate(ctx context.Context, query *reports.SearchTemplateQuery) (*reports.SearchTemplateResult, error) {
+	if query.Page <= 0 {
+		query.Page = s.cfg.Pagination.Page
+	}
+
+	if query.PerPage <= 0 {
+		query.PerPage = s.cfg.Pagination.PageLimit
+	}
+
+	result, err := s.store.searchTemplates(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PerPage = query.PerPage
+	result.Page = query.Page
+
+	return result, nil
+}
+
+func (s *service) DeleteTemplate(ctx context.Context, id int64) error {
+	_, err := s.GetByTemplateID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.store.templateUsage(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return reports.ErrTemplateInUse
+	}
+
+	err = s.store.deleteTemplate(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
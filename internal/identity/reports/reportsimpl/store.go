@@ -1 +1,1291 @@
 package reportsimpl
+
+import (
+	"amg/internal/db"
+	"amg/internal/identity/organization"
+	"amg/internal/identity/reports"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type store struct {
+	db     db.DB
+	logger *zap.Logger
+}
+
+func NewStore(db db.DB) *store {
+	return &store{
+		db:     db,
+		logger: zap.L().Named("reports.store"),
+	}
+}
+
+// Reports belong to an organization, and every query below only sees the
+// reports of the organization ctx acts in; without one they fail with
+// organization.ErrNoOrganization. Queries on the attachments, comments,
+// revisions and history of a report go through the report, see inOrganization.
+
+// inOrganization restricts a report_id column to the reports of an organization
+func inOrganization(column string, param int) string {
+	return fmt.Sprintf("%s IN (SELECT id FROM reports WHERE organization_id = $%d)", column, param)
+}
+
+// guarded fails with ErrReportNotFound when a guarded statement changed nothing
+func guarded(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return reports.ErrReportNotFound
+	}
+
+	return nil
+}
+
+func (s *store) create(ctx context.Context, cmd *reports.CreateReportCommand) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		INSERT INTO reports (
+			organization_id,
+			title,
+			body,
+			author_id,
+			template_id,
+			status
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		) RETURNING id
+	`
+
+		err := tx.QueryRow(
+			ctx,
+			rawSQL,
+			orgID,
+			cmd.Title,
+			cmd.Body,
+			cmd.AuthorID,
+			cmd.TemplateID,
+			reports.StatusDraft,
+		).Scan(&cmd.ID)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		INSERT INTO report_revisions (
+			report_id,
+			revision,
+			title,
+			body,
+			editor_id
+		) VALUES (
+			$1, 1, $2, $3, $4
+		)
+		`
+
+		_, err = tx.Exec(
+			ctx,
+			rawSQL,
+			cmd.ID,
+			cmd.Title,
+			cmd.Body,
+			cmd.AuthorID,
+		)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) getReportByID(ctx context.Context, id int64) (*reports.Report, error) {
+	var result reports.Report
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		title,
+		body,
+		author_id,
+		template_id,
+		status,
+		created_at,
+		updated_at,
+		COALESCE((
+			SELECT team FROM organization_members m
+			WHERE m.user_id = reports.author_id AND m.organization_id = reports.organization_id
+		), '') AS author_team
+	FROM
+		reports
+	WHERE
+		id = $1 AND
+		organization_id = $2
+	`
+
+	err = s.db.Get(ctx, &result, rawSQL, id, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) update(ctx context.Context, cmd *reports.UpdateReportCommand) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			reports
+		SET
+			title = $1,
+			body = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE
+			id = $3 AND
+			organization_id = $4
+		`
+
+		res, err := tx.Exec(
+			ctx,
+			rawSQL,
+			cmd.Title,
+			cmd.Body,
+			cmd.ID,
+			orgID,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = guarded(res)
+		if err != nil {
+			return err
+		}
+
+		// The update above holds the report row lock, so concurrent edits number
+		// their revisions one after the other
+		rawSQL = `
+		INSERT INTO report_revisions (
+			report_id,
+			revision,
+			title,
+			body,
+			editor_id
+		)
+		SELECT
+			$1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4
+		FROM
+			report_revisions
+		WHERE
+			report_id = $1
+		`
+
+		_, err = tx.Exec(
+			ctx,
+			rawSQL,
+			cmd.ID,
+			cmd.Title,
+			cmd.Body,
+			cmd.EditorID,
+		)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) search(ctx context.Context, query *reports.SearchReportQuery) (*reports.SearchReportResult, error) {
+	var (
+		result = reports.SearchReportResult{
+			Reports: make([]*reports.Report, 0),
+		}
+	)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sql, whereParams, paramIndex := buildSearchSQL(orgID, query)
+
+	count, err := s.getCount(ctx, sql, whereParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.PerPage > 0 {
+		offset := query.PerPage * (query.Page - 1)
+		sql.WriteString(fmt.Sprintf(" LIMIT $%d OFFSET $%d", paramIndex, paramIndex+1))
+		whereParams = append(whereParams, query.PerPage, offset)
+	}
+
+	err = s.db.Select(ctx, &result.Reports, sql.String(), whereParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, report := range result.Reports {
+		report.Snippet = highlight(report.Snippet)
+	}
+
+	result.TotalCount = count
+
+	return &result, nil
+}
+
+// iterate runs the search query without pagination and hands every matching
+// report to fn as it is read from the database, so that callers can stream
+// large result sets without holding them in memory
+func (s *store) iterate(ctx context.Context, query *reports.SearchReportQuery, fn func(*reports.Report) error) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	sql, whereParams, _ := buildSearchSQL(orgID, query)
+
+	rows, err := s.db.Queryx(ctx, sql.String(), whereParams...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report reports.Report
+
+		err = rows.StructScan(&report)
+		if err != nil {
+			return err
+		}
+		report.Snippet = highlight(report.Snippet)
+
+		err = fn(&report)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// buildSearchSQL builds the filtered and ordered query on the reports of orgID
+// shared by search and iterate. It returns the next free parameter index for
+// callers that append more.
+func buildSearchSQL(orgID int64, query *reports.SearchReportQuery) (bytes.Buffer, []interface{}, int) {
+	var (
+		sql            bytes.Buffer
+		whereCondition = []string{"organization_id = $1"}
+		whereParams    = []interface{}{orgID}
+		paramIndex     = 2
+	)
+
+	sql.WriteString(`
+	SELECT
+		id,
+		title,
+		body,
+		author_id,
+		template_id,
+		status,
+		created_at,
+		updated_at`)
+
+	// Full-text search ranks title matches above body matches (see the weights of
+	// search_vector) and highlights the matched terms of the body
+	if len(query.Q) > 0 {
+		tsquery := fmt.Sprintf("websearch_to_tsquery('english', $%d)", paramIndex)
+
+		sql.WriteString(fmt.Sprintf(`,
+		ts_rank_cd(search_vector, %[1]s) AS rank,
+		ts_headline('english', translate(body, '%[3]s%[4]s', '  '), %[1]s, '%[2]s') AS snippet`, tsquery, headlineOptions, snippetStart, snippetStop))
+
+		whereCondition = append(whereCondition, "search_vector @@ "+tsquery)
+		whereParams = append(whereParams, query.Q)
+		paramIndex++
+	}
+
+	sql.WriteString(`
+	FROM
+		reports
+	`)
+
+	if len(query.Title) > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("title ILIKE $%d", paramIndex))
+		whereParams = append(whereParams, "%"+query.Title+"%")
+		paramIndex++
+	}
+
+	if query.AuthorID > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("author_id = $%d", paramIndex))
+		whereParams = append(whereParams, query.AuthorID)
+		paramIndex++
+	}
+
+	if len(query.Status) > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("status = $%d", paramIndex))
+		whereParams = append(whereParams, query.Status)
+		paramIndex++
+	}
+
+	if query.ExcludeDrafts {
+		whereCondition = append(whereCondition, fmt.Sprintf("status <> $%d", paramIndex))
+		whereParams = append(whereParams, reports.StatusDraft)
+		paramIndex++
+	}
+
+	if query.AuthorTeam != nil {
+		whereCondition = append(whereCondition, fmt.Sprintf("author_id IN (SELECT user_id FROM organization_members m WHERE m.organization_id = reports.organization_id AND m.team = $%d)", paramIndex))
+		whereParams = append(whereParams, *query.AuthorTeam)
+		paramIndex++
+	}
+
+	sql.WriteString(" WHERE " + strings.Join(whereCondition, " AND "))
+
+	if len(query.Q) > 0 {
+		sql.WriteString(" ORDER BY rank DESC, created_at DESC")
+	} else {
+		sql.WriteString(" ORDER BY created_at DESC")
+	}
+
+	return sql, whereParams, paramIndex
+}
+
+// headlineOptions marks matches with sentinels rather than tags so the snippet can
+// be HTML escaped before the tags are put in. The sentinels are left untouched by
+// the escaping; the ones already in a body are blanked before the headline is
+// built so that they cannot produce unbalanced tags.
+const headlineOptions = "StartSel=" + snippetStart + ", StopSel=" + snippetStop + ", MaxFragments=2, MinWords=10, MaxWords=30"
+
+const (
+	snippetStart = "⟦"
+	snippetStop  = "⟧"
+)
+
+var snippetReplacer = strings.NewReplacer(snippetStart, "<mark>", snippetStop, "</mark>")
+
+// highlight escapes a ts_headline snippet and turns its match sentinels into <mark> tags
+func highlight(snippet string) string {
+	return snippetReplacer.Replace(html.EscapeString(snippet))
+}
+
+func (s *store) getCount(ctx context.Context, sql bytes.Buffer, whereParams []interface{}) (int64, error) {
+	var count int64
+
+	rawSQL := "SELECT COUNT(*) FROM (" + sql.String() + ") as t1"
+
+	err := s.db.Get(ctx, &count, rawSQL, whereParams...)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (s *store) delete(ctx context.Context, id int64) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+			DELETE
+			FROM
+				reports
+			WHERE
+				id = $1 AND
+				organization_id = $2
+		`
+
+		res, err := tx.Exec(ctx, rawSQL, id, orgID)
+		if err != nil {
+			return err
+		}
+
+		return guarded(res)
+	})
+}
+
+// transition moves the report from one status to another and records the change in
+// report_status_history. The update is guarded on the current status so that two
+// concurrent transitions cannot both succeed.
+func (s *store) transition(ctx context.Context, cmd *reports.TransitionReportCommand, from string) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			reports
+		SET
+			status = $1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE
+			id = $2 AND
+			status = $3 AND
+			organization_id = $4
+		`
+
+		res, err := tx.Exec(ctx, rawSQL, cmd.Status, cmd.ReportID, from, orgID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return reports.ErrInvalidTransition
+		}
+
+		rawSQL = `
+		INSERT INTO report_status_history (
+			report_id,
+			from_status,
+			to_status,
+			actor_id,
+			comment
+		) VALUES (
+			$1, $2, $3, $4, $5
+		)
+		`
+
+		_, err = tx.Exec(
+			ctx,
+			rawSQL,
+			cmd.ReportID,
+			from,
+			cmd.Status,
+			cmd.ActorID,
+			cmd.Comment,
+		)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) getHistory(ctx context.Context, reportID int64) ([]*reports.ReportHistory, error) {
+	result := make([]*reports.ReportHistory, 0)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		report_id,
+		from_status,
+		to_status,
+		actor_id,
+		comment,
+		created_at
+	FROM
+		report_status_history
+	WHERE
+		report_id = $1 AND
+		` + inOrganization("report_id", 2) + `
+	ORDER BY created_at ASC, id ASC
+	`
+
+	err = s.db.Select(ctx, &result, rawSQL, reportID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) getRevisions(ctx context.Context, reportID int64) ([]*reports.Revision, error) {
+	result := make([]*reports.Revision, 0)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		report_id,
+		revision,
+		title,
+		body,
+		editor_id,
+		created_at
+	FROM
+		report_revisions
+	WHERE
+		report_id = $1 AND
+		` + inOrganization("report_id", 2) + `
+	ORDER BY revision DESC
+	`
+
+	err = s.db.Select(ctx, &result, rawSQL, reportID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) getRevision(ctx context.Context, reportID int64, revision int) (*reports.Revision, error) {
+	var result reports.Revision
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		report_id,
+		revision,
+		title,
+		body,
+		editor_id,
+		created_at
+	FROM
+		report_revisions
+	WHERE
+		report_id = $1 AND
+		revision = $2 AND
+		` + inOrganization("report_id", 3) + `
+	`
+
+	err = s.db.Get(ctx, &result, rawSQL, reportID, revision, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// getStats aggregates the reports created within the query range by status, author
+// and period. The upper bound is exclusive of the day after To so that To is included.
+func (s *store) getStats(ctx context.Context, query *reports.ReportStatsQuery) (*reports.ReportStats, error) {
+	var (
+		result = reports.ReportStats{
+			ByStatus: make([]*reports.StatusCount, 0),
+			ByAuthor: make([]*reports.AuthorCount, 0),
+			ByPeriod: make([]*reports.PeriodCount, 0),
+		}
+		from = query.FromDate
+		to   = query.ToDate.AddDate(0, 0, 1)
+	)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		status,
+		COUNT(*) AS count
+	FROM
+		reports
+	WHERE
+		organization_id = $3 AND
+		created_at >= $1 AND
+		created_at < $2
+	GROUP BY
+		status
+	ORDER BY
+		count DESC, status
+	`
+
+	err = s.db.Select(ctx, &result.ByStatus, rawSQL, from, to, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL = `
+	SELECT
+		r.author_id,
+		COALESCE(u.first_name, '') AS first_name,
+		COALESCE(u.last_name, '') AS last_name,
+		COUNT(*) AS count
+	FROM
+		reports r
+	LEFT JOIN
+		users u ON u.id = r.author_id
+	WHERE
+		r.organization_id = $3 AND
+		r.created_at >= $1 AND
+		r.created_at < $2
+	GROUP BY
+		r.author_id, u.first_name, u.last_name
+	ORDER BY
+		count DESC, r.author_id
+	`
+
+	err = s.db.Select(ctx, &result.ByAuthor, rawSQL, from, to, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The interval has already been checked against the whitelist in Validate
+	rawSQL = `
+	SELECT
+		to_char(date_trunc($3, created_at), 'YYYY-MM-DD') AS period,
+		COUNT(*) AS count
+	FROM
+		reports
+	WHERE
+		organization_id = $4 AND
+		created_at >= $1 AND
+		created_at < $2
+	GROUP BY
+		period
+	ORDER BY
+		period
+	`
+
+	err = s.db.Select(ctx, &result.ByPeriod, rawSQL, from, to, query.Interval, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range result.ByStatus {
+		result.TotalCount += status.Count
+	}
+
+	return &result, nil
+}
+
+func (s *store) createAttachment(ctx context.Context, attachment *reports.Attachment) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	rawSQL := `
+	INSERT INTO report_attachments (
+		report_id,
+		file_name,
+		content_type,
+		size,
+		storage_key,
+		uploaded_by
+	)
+	SELECT
+		$1, $2, $3, $4, $5, $6
+	WHERE
+		` + inOrganization("$1", 7) + `
+	RETURNING id, created_at
+	`
+
+	err = s.db.Get(
+		ctx,
+		attachment,
+		rawSQL,
+		attachment.ReportID,
+		attachment.FileName,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.StorageKey,
+		attachment.UploadedBy,
+		orgID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reports.ErrReportNotFound
+	}
+
+	return err
+}
+
+func (s *store) getAttachments(ctx context.Context, reportID int64) ([]*reports.Attachment, error) {
+	result := make([]*reports.Attachment, 0)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		report_id,
+		file_name,
+		content_type,
+		size,
+		storage_key,
+		uploaded_by,
+		created_at
+	FROM
+		report_attachments
+	WHERE
+		report_id = $1 AND
+		` + inOrganization("report_id", 2) + `
+	ORDER BY created_at ASC, id ASC
+	`
+
+	err = s.db.Select(ctx, &result, rawSQL, reportID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) getAttachmentByID(ctx context.Context, reportID, id int64) (*reports.Attachment, error) {
+	var result reports.Attachment
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		report_id,
+		file_name,
+		content_type,
+		size,
+		storage_key,
+		uploaded_by,
+		created_at
+	FROM
+		report_attachments
+	WHERE
+		id = $1 AND
+		report_id = $2 AND
+		` + inOrganization("report_id", 3) + `
+	`
+
+	err = s.db.Get(ctx, &result, rawSQL, id, reportID, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) deleteAttachment(ctx context.Context, id int64) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+			DELETE
+			FROM
+				report_attachments
+			WHERE
+				id = $1 AND
+				` + inOrganization("report_id", 2) + `
+		`
+
+		_, err := tx.Exec(ctx, rawSQL, id, orgID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) createComment(ctx context.Context, cmd *reports.CreateCommentCommand) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		INSERT INTO report_comments (
+			report_id,
+			parent_id,
+			author_id,
+			body
+		)
+		SELECT
+			$1, $2, $3, $4
+		WHERE
+			` + inOrganization("$1", 5) + `
+		RETURNING id
+	`
+
+		err := tx.QueryRow(
+			ctx,
+			rawSQL,
+			cmd.ReportID,
+			cmd.ParentID,
+			cmd.AuthorID,
+			cmd.Body,
+			orgID,
+		).Scan(&cmd.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return reports.ErrReportNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) getCommentByID(ctx context.Context, reportID, id int64) (*reports.Comment, error) {
+	var result reports.Comment
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		report_id,
+		parent_id,
+		author_id,
+		body,
+		created_at,
+		updated_at
+	FROM
+		report_comments
+	WHERE
+		id = $1 AND
+		report_id = $2 AND
+		` + inOrganization("report_id", 3) + `
+	`
+
+	err = s.db.Get(ctx, &result, rawSQL, id, reportID, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) updateComment(ctx context.Context, cmd *reports.UpdateCommentCommand) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			report_comments
+		SET
+			body = $1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE
+			id = $2 AND
+			report_id = $3 AND
+			` + inOrganization("report_id", 4) + `
+		`
+
+		_, err := tx.Exec(ctx, rawSQL, cmd.Body, cmd.ID, cmd.ReportID, orgID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// deleteComment removes a comment together with its replies (ON DELETE CASCADE)
+func (s *store) deleteComment(ctx context.Context, id int64) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+			DELETE
+			FROM
+				report_comments
+			WHERE
+				id = $1 AND
+				` + inOrganization("report_id", 2) + `
+		`
+
+		_, err := tx.Exec(ctx, rawSQL, id, orgID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// searchComments returns one page of top-level comments, oldest first, with every
+// reply below them attached as a tree
+func (s *store) searchComments(ctx context.Context, query *reports.SearchCommentQuery) (*reports.SearchCommentResult, error) {
+	var (
+		result = reports.SearchCommentResult{
+			Comments: make([]*reports.Comment, 0),
+		}
+		sql bytes.Buffer
+	)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	whereParams := []interface{}{query.ReportID, orgID}
+
+	sql.WriteString(`
+	SELECT
+		id,
+		report_id,
+		parent_id,
+		author_id,
+		body,
+		created_at,
+		updated_at
+	FROM
+		report_comments
+	WHERE
+		report_id = $1 AND
+		` + inOrganization("report_id", 2) + ` AND
+		parent_id IS NULL
+	ORDER BY created_at ASC, id ASC
+	`)
+
+	count, err := s.getCount(ctx, sql, whereParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.PerPage > 0 {
+		offset := query.PerPage * (query.Page - 1)
+		sql.WriteString(" LIMIT $3 OFFSET $4")
+		whereParams = append(whereParams, query.PerPage, offset)
+	}
+
+	err = s.db.Select(ctx, &result.Comments, sql.String(), whereParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	result.TotalCount = count
+
+	if len(result.Comments) == 0 {
+		return &result, nil
+	}
+
+	rootIDs := make([]int64, 0, len(result.Comments))
+	for _, comment := range result.Comments {
+		rootIDs = append(rootIDs, comment.ID)
+	}
+
+	rawSQL := `
+	WITH RECURSIVE thread AS (
+		SELECT
+			id, report_id, parent_id, author_id, body, created_at, updated_at
+		FROM
+			report_comments
+		WHERE
+			parent_id = ANY($1::BIGINT[])
+		UNION ALL
+		SELECT
+			c.id, c.report_id, c.parent_id, c.author_id, c.body, c.created_at, c.updated_at
+		FROM
+			report_comments c
+		JOIN
+			thread t ON c.parent_id = t.id
+	)
+	SELECT
+		id,
+		report_id,
+		parent_id,
+		author_id,
+		body,
+		created_at,
+		updated_at
+	FROM
+		thread
+	ORDER BY created_at ASC, id ASC
+	`
+
+	replies := make([]*reports.Comment, 0)
+
+	err = s.db.Select(ctx, &replies, rawSQL, pq.Array(rootIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	buildCommentTree(result.Comments, replies)
+
+	return &result, nil
+}
+
+// buildCommentTree attaches replies to their parents. Replies are ordered oldest
+// first, so every reply is appended after the ones it follows.
+func buildCommentTree(roots, replies []*reports.Comment) {
+	byID := make(map[int64]*reports.Comment, len(roots)+len(replies))
+
+	for _, comment := range roots {
+		comment.Replies = make([]*reports.Comment, 0)
+		byID[comment.ID] = comment
+	}
+	for _, comment := range replies {
+		comment.Replies = make([]*reports.Comment, 0)
+		byID[comment.ID] = comment
+	}
+
+	for _, comment := range replies {
+		if parent, ok := byID[*comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+}
+
+func (s *store) createTemplate(ctx context.Context, cmd *reports.CreateTemplateCommand) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		INSERT INTO report_templates (
+			name,
+			description,
+			schema
+		) VALUES (
+			$1, $2, $3
+		) RETURNING id
+	`
+
+		err := tx.QueryRow(
+			ctx,
+			rawSQL,
+			cmd.Name,
+			cmd.Description,
+			string(cmd.Schema),
+		).Scan(&cmd.ID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) templateTaken(ctx context.Context, id int64, name string) ([]*reports.Template, error) {
+	result := make([]*reports.Template, 0)
+
+	rawSQL := `
+	SELECT
+		id,
+		name
+	FROM
+		report_templates
+	WHERE
+		id = $1 OR
+		name = $2
+	`
+
+	err := s.db.Select(ctx, &result, rawSQL, id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) getTemplateByID(ctx context.Context, id int64) (*reports.Template, error) {
+	var result reports.Template
+
+	rawSQL := `
+	SELECT
+		id,
+		name,
+		description,
+		schema,
+		created_at,
+		updated_at
+	FROM
+		report_templates
+	WHERE
+		id = $1
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) updateTemplate(ctx context.Context, cmd *reports.UpdateTemplateCommand) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			report_templates
+		SET
+			name = $1,
+			description = $2,
+			schema = $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE
+			id = $4
+		`
+
+		_, err := tx.Exec(
+			ctx,
+			rawSQL,
+			cmd.Name,
+			cmd.Description,
+			string(cmd.Schema),
+			cmd.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) searchTemplates(ctx context.Context, query *reports.SearchTemplateQuery) (*reports.SearchTemplateResult, error) {
+	var (
+		result = reports.SearchTemplateResult{
+			Templates: make([]*reports.Template, 0),
+		}
+		sql            bytes.Buffer
+		whereCondition = make([]string, 0)
+		whereParams    = make([]interface{}, 0)
+		paramIndex     = 1
+	)
+
+	sql.WriteString(`
+	SELECT
+		id,
+		name,
+		description,
+		schema,
+		created_at,
+		updated_at
+	FROM
+		report_templates
+	`)
+
+	if len(query.Name) > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("name ILIKE $%d", paramIndex))
+		whereParams = append(whereParams, "%"+query.Name+"%")
+		paramIndex++
+	}
+
+	if len(whereCondition) > 0 {
+		sql.WriteString(" WHERE " + strings.Join(whereCondition, " AND "))
+	}
+
+	sql.WriteString(" ORDER BY name ASC")
+
+	count, err := s.getCount(ctx, sql, whereParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.PerPage > 0 {
+		offset := query.PerPage * (query.Page - 1)
+		sql.WriteString(fmt.Sprintf(" LIMIT $%d OFFSET $%d", paramIndex, paramIndex+1))
+		whereParams = append(whereParams, query.PerPage, offset)
+	}
+
+	err = s.db.Select(ctx, &result.Templates, sql.String(), whereParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	result.TotalCount = count
+
+	return &result, nil
+}
+
+func (s *store) templateUsage(ctx context.Context, id int64) (int64, error) {
+	var count int64
+
+	rawSQL := `
+	SELECT
+		COUNT(*)
+	FROM
+		reports
+	WHERE
+		template_id = $1
+	`
+
+	err := s.db.Get(ctx, &count, rawSQL, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (s *store) deleteTemplate(ctx context.Context, id int64) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+			DELETE
+			FROM
+				report_templates
+			WHERE
+				id = $1
+		`
+
+		_, err := tx.Exec(ctx, rawSQL, id)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,359 @@
+package reports
+
+import (
+	"amg/internal/api/errors"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTemplateNotFound  = errors.New("report.template-not-found", "Report template not found")
+	ErrInvalidTemplate   = errors.New("report.invalid-template", "Invalid report template")
+	ErrTemplateExists    = errors.New("report.template-already-exists", "Report template already exists")
+	ErrTemplateInUse     = errors.New("report.template-in-use", "Report template is used by existing reports")
+	ErrTemplateMismatch  = errors.New("report.template-mismatch", "Report body does not match its template")
+	ErrInvalidSchema     = errors.New("report.invalid-template-schema", "Invalid report template schema")
+	ErrInvalidTemplateID = errors.New("report.invalid-template-id", "Invalid template id")
+)
+
+const (
+	FieldTypeString  = "string"
+	FieldTypeText    = "text"
+	FieldTypeNumber  = "number"
+	FieldTypeInteger = "integer"
+	FieldTypeBoolean = "boolean"
+	FieldTypeDate    = "date"
+	FieldTypeEnum    = "enum"
+)
+
+var validFieldTypes = map[string]bool{
+	FieldTypeString:  true,
+	FieldTypeText:    true,
+	FieldTypeNumber:  true,
+	FieldTypeInteger: true,
+	FieldTypeBoolean: true,
+	FieldTypeDate:    true,
+	FieldTypeEnum:    true,
+}
+
+// TemplateSchema describes the body of reports created from a template. Such a
+// body is a JSON object keyed by section, each section an object keyed by field:
+//
+//	{"summary": {"impact": "high", "started_at": "2024-01-31"}}
+type TemplateSchema struct {
+	Sections []TemplateSection `json:"sections"`
+}
+
+type TemplateSection struct {
+	Key      string          `json:"key"`
+	Title    string          `json:"title"`
+	Required bool            `json:"required"`
+	Fields   []TemplateField `json:"fields"`
+}
+
+type TemplateField struct {
+	Key       string   `json:"key"`
+	Label     string   `json:"label"`
+	Type      string   `json:"type"`
+	Required  bool     `json:"required"`
+	Options   []string `json:"options,omitempty"`
+	MinLength *int     `json:"min_length,omitempty"`
+	MaxLength *int     `json:"max_length,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+}
+
+type Template struct {
+	ID          int64           `db:"id" json:"id"`
+	Name        string          `db:"name" json:"name"`
+	Description string          `db:"description" json:"description"`
+	Schema      json.RawMessage `db:"schema" json:"schema"`
+	CreatedAt   string          `db:"created_at" json:"created_at"`
+	UpdatedAt   string          `db:"updated_at" json:"updated_at"`
+}
+
+type CreateTemplateCommand struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+}
+
+type UpdateTemplateCommand struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+}
+
+type SearchTemplateQuery struct {
+	Name    string `query:"name"`
+	Page    int    `query:"page"`
+	PerPage int    `query:"per_page"`
+}
+
+type SearchTemplateResult struct {
+	TotalCount int64       `json:"total_count"`
+	Templates  []*Template `json:"templates"`
+	Page       int         `json:"page"`
+	PerPage    int         `json:"per_page"`
+}
+
+// FieldError describes one problem with a templated report body. Field is the
+// dotted path of the offending value, e.g. "summary.impact".
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// TemplateValidationError carries every field error found in a report body
+type TemplateValidationError struct {
+	Fields []FieldError
+}
+
+func (e *TemplateValidationError) Error() string {
+	return ErrTemplateMismatch.Message
+}
+
+func (cmd *CreateTemplateCommand) Validate() error {
+	if len(strings.TrimSpace(cmd.Name)) == 0 || len(cmd.Name) > 255 {
+		return ErrInvalidTemplate
+	}
+
+	_, err := ParseTemplateSchema(cmd.Schema)
+	return err
+}
+
+func (cmd *UpdateTemplateCommand) Validate() error {
+	if cmd.ID <= 0 {
+		return ErrInvalidTemplateID
+	}
+	if len(strings.TrimSpace(cmd.Name)) == 0 || len(cmd.Name) > 255 {
+		return ErrInvalidTemplate
+	}
+
+	_, err := ParseTemplateSchema(cmd.Schema)
+	return err
+}
+
+// ParseTemplateSchema decodes a schema and checks that it is well formed: at least
+// one section, unique non-empty keys, known field types and options for enums
+func ParseTemplateSchema(raw json.RawMessage) (*TemplateSchema, error) {
+	var schema TemplateSchema
+
+	err := json.Unmarshal(raw, &schema)
+	if err != nil || len(schema.Sections) == 0 {
+		return nil, ErrInvalidSchema
+	}
+
+	sections := make(map[string]bool, len(schema.Sections))
+	for _, section := range schema.Sections {
+		if len(section.Key) == 0 || sections[section.Key] || len(section.Fields) == 0 {
+			return nil, ErrInvalidSchema
+		}
+		sections[section.Key] = true
+
+		fields := make(map[string]bool, len(section.Fields))
+		for _, field := range section.Fields {
+			if len(field.Key) == 0 || fields[field.Key] || !validFieldTypes[field.Type] {
+				return nil, ErrInvalidSchema
+			}
+			if field.Type == FieldTypeEnum && len(field.Options) == 0 {
+				return nil, ErrInvalidSchema
+			}
+			fields[field.Key] = true
+		}
+	}
+
+	return &schema, nil
+}
+
+// ValidateBody checks a report body against the schema. It returns nil or a
+// *TemplateValidationError listing every problem found.
+func (schema *TemplateSchema) ValidateBody(body string) error {
+	var (
+		values   map[string]map[string]interface{}
+		problems = make([]FieldError, 0)
+	)
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	err := decoder.Decode(&values)
+	if err != nil {
+		return &TemplateValidationError{
+			Fields: []FieldError{{
+				Field:   "body",
+				Code:    "invalid-json",
+				Message: "Body must be a JSON object of sections",
+			}},
+		}
+	}
+
+	known := make(map[string]bool, len(schema.Sections))
+
+	for _, section := range schema.Sections {
+		known[section.Key] = true
+
+		sectionValues, ok := values[section.Key]
+		if !ok {
+			if section.Required {
+				problems = append(problems, FieldError{
+					Field:   section.Key,
+					Code:    "required",
+					Message: fmt.Sprintf("Section %q is required", sectionName(section)),
+				})
+			}
+			continue
+		}
+
+		knownFields := make(map[string]bool, len(section.Fields))
+
+		for _, field := range section.Fields {
+			knownFields[field.Key] = true
+			path := section.Key + "." + field.Key
+
+			value, ok := sectionValues[field.Key]
+			if !ok || value == nil {
+				if field.Required {
+					problems = append(problems, FieldError{
+						Field:   path,
+						Code:    "required",
+						Message: fmt.Sprintf("%s is required", fieldName(field)),
+					})
+				}
+				continue
+			}
+
+			if problem := field.check(value); problem != nil {
+				problem.Field = path
+				problems = append(problems, *problem)
+			}
+		}
+
+		for _, key := range sortedKeys(sectionValues) {
+			if !knownFields[key] {
+				problems = append(problems, FieldError{
+					Field:   section.Key + "." + key,
+					Code:    "unknown",
+					Message: "Field is not part of the template",
+				})
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(values) {
+		if !known[key] {
+			problems = append(problems, FieldError{
+				Field:   key,
+				Code:    "unknown",
+				Message: "Section is not part of the template",
+			})
+		}
+	}
+
+	if len(problems) > 0 {
+		return &TemplateValidationError{Fields: problems}
+	}
+
+	return nil
+}
+
+func sectionName(section TemplateSection) string {
+	if len(section.Title) > 0 {
+		return section.Title
+	}
+	return section.Key
+}
+
+func fieldName(field TemplateField) string {
+	if len(field.Label) > 0 {
+		return field.Label
+	}
+	return field.Key
+}
+
+// check validates a single decoded value against the field definition
+func (field TemplateField) check(value interface{}) *FieldError {
+	invalidType := &FieldError{
+		Code:    "invalid-type",
+		Message: fmt.Sprintf("%s must be of type %s", fieldName(field), field.Type),
+	}
+
+	switch field.Type {
+	case FieldTypeString, FieldTypeText, FieldTypeDate, FieldTypeEnum:
+		s, ok := value.(string)
+		if !ok {
+			return invalidType
+		}
+		if field.Required && len(strings.TrimSpace(s)) == 0 {
+			return &FieldError{Code: "required", Message: fmt.Sprintf("%s is required", fieldName(field))}
+		}
+		if field.MinLength != nil && len(s) < *field.MinLength {
+			return &FieldError{Code: "too-short", Message: fmt.Sprintf("%s must be at least %d characters", fieldName(field), *field.MinLength)}
+		}
+		if field.MaxLength != nil && len(s) > *field.MaxLength {
+			return &FieldError{Code: "too-long", Message: fmt.Sprintf("%s must be at most %d characters", fieldName(field), *field.MaxLength)}
+		}
+		if field.Type == FieldTypeDate {
+			if _, err := time.Parse(DateLayout, s); err != nil {
+				return &FieldError{Code: "invalid-date", Message: fmt.Sprintf("%s must be a date formatted as YYYY-MM-DD", fieldName(field))}
+			}
+		}
+		if field.Type == FieldTypeEnum && !contains(field.Options, s) {
+			return &FieldError{Code: "invalid-option", Message: fmt.Sprintf("%s must be one of %s", fieldName(field), strings.Join(field.Options, ", "))}
+		}
+
+	case FieldTypeNumber, FieldTypeInteger:
+		n, ok := value.(json.Number)
+		if !ok {
+			return invalidType
+		}
+		if field.Type == FieldTypeInteger {
+			if _, err := n.Int64(); err != nil {
+				return invalidType
+			}
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return invalidType
+		}
+		if field.Min != nil && f < *field.Min {
+			return &FieldError{Code: "too-small", Message: fmt.Sprintf("%s must be at least %v", fieldName(field), *field.Min)}
+		}
+		if field.Max != nil && f > *field.Max {
+			return &FieldError{Code: "too-large", Message: fmt.Sprintf("%s must be at most %v", fieldName(field), *field.Max)}
+		}
+
+	case FieldTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return invalidType
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys keeps the order of reported unknown keys stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package reports
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const incidentSchema = `{
+	"sections": [
+		{
+			"key": "summary",
+			"title": "Summary",
+			"required": true,
+			"fields": [
+				{"key": "title", "label": "Title", "type": "string", "required": true, "min_length": 3, "max_length": 20},
+				{"key": "impact", "type": "enum", "required": true, "options": ["low", "high"]},
+				{"key": "started_at", "type": "date"},
+				{"key": "notes", "type": "text"}
+			]
+		},
+		{
+			"key": "metrics",
+			"fields": [
+				{"key": "affected", "type": "integer", "min": 0},
+				{"key": "ratio", "type": "number", "min": 0, "max": 1},
+				{"key": "resolved", "type": "boolean"}
+			]
+		}
+	]
+}`
+
+func TestParseTemplateSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		valid  bool
+	}{
+		{"well formed", incidentSchema, true},
+		{"not JSON", `{"sections": [`, false},
+		{"no sections", `{"sections": []}`, false},
+		{"missing sections", `{}`, false},
+		{"section without key", `{"sections": [{"fields": [{"key": "a", "type": "string"}]}]}`, false},
+		{"section without fields", `{"sections": [{"key": "s", "fields": []}]}`, false},
+		{"duplicate section", `{"sections": [{"key": "s", "fields": [{"key": "a", "type": "string"}]}, {"key": "s", "fields": [{"key": "b", "type": "string"}]}]}`, false},
+		{"field without key", `{"sections": [{"key": "s", "fields": [{"type": "string"}]}]}`, false},
+		{"duplicate field", `{"sections": [{"key": "s", "fields": [{"key": "a", "type": "string"}, {"key": "a", "type": "text"}]}]}`, false},
+		{"same field key in two sections", `{"sections": [{"key": "s", "fields": [{"key": "a", "type": "string"}]}, {"key": "t", "fields": [{"key": "a", "type": "string"}]}]}`, true},
+		{"unknown field type", `{"sections": [{"key": "s", "fields": [{"key": "a", "type": "uuid"}]}]}`, false},
+		{"enum without options", `{"sections": [{"key": "s", "fields": [{"key": "a", "type": "enum"}]}]}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplateSchema(json.RawMessage(tt.schema))
+			if tt.valid && err != nil {
+				t.Fatalf("expected a valid schema, got %v", err)
+			}
+			if !tt.valid && err != ErrInvalidSchema {
+				t.Fatalf("expected ErrInvalidSchema, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	schema, err := ParseTemplateSchema(json.RawMessage(incidentSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		// want lists the problems as "field code"
+		want []string
+	}{
+		{"minimal", `{"summary": {"title": "Outage", "impact": "low"}}`, nil},
+		{"complete", `{"summary": {"title": "Outage", "impact": "high", "started_at": "2024-01-31", "notes": "..."}, "metrics": {"affected": 3, "ratio": 0.5, "resolved": true}}`, nil},
+		{"null optional field", `{"summary": {"title": "Outage", "impact": "low", "notes": null}}`, nil},
+		{"not JSON", `not json`, []string{"body invalid-json"}},
+		{"section is not an object", `{"summary": "Outage"}`, []string{"body invalid-json"}},
+		{"missing required section", `{}`, []string{"summary required"}},
+		{"missing required fields", `{"summary": {}}`, []string{"summary.title required", "summary.impact required"}},
+		{"null required field", `{"summary": {"title": null, "impact": "low"}}`, []string{"summary.title required"}},
+		{"blank required string", `{"summary": {"title": "   ", "impact": "low"}}`, []string{"summary.title required"}},
+		{"too short", `{"summary": {"title": "Ab", "impact": "low"}}`, []string{"summary.title too-short"}},
+		{"too long", `{"summary": {"title": "An outage of everything", "impact": "low"}}`, []string{"summary.title too-long"}},
+		{"string of wrong type", `{"summary": {"title": 5, "impact": "low"}}`, []string{"summary.title invalid-type"}},
+		{"unknown option", `{"summary": {"title": "Outage", "impact": "medium"}}`, []string{"summary.impact invalid-option"}},
+		{"invalid date", `{"summary": {"title": "Outage", "impact": "low", "started_at": "31/01/2024"}}`, []string{"summary.started_at invalid-date"}},
+		{"fraction for integer", `{"summary": {"title": "Outage", "impact": "low"}, "metrics": {"affected": 1.5}}`, []string{"metrics.affected invalid-type"}},
+		{"number as string", `{"summary": {"title": "Outage", "impact": "low"}, "metrics": {"ratio": "0.5"}}`, []string{"metrics.ratio invalid-type"}},
+		{"below minimum", `{"summary": {"title": "Outage", "impact": "low"}, "metrics": {"affected": -1}}`, []string{"metrics.affected too-small"}},
+		{"above maximum", `{"summary": {"title": "Outage", "impact": "low"}, "metrics": {"ratio": 1.5}}`, []string{"metrics.ratio too-large"}},
+		{"boolean as string", `{"summary": {"title": "Outage", "impact": "low"}, "metrics": {"resolved": "yes"}}`, []string{"metrics.resolved invalid-type"}},
+		{"unknown field and sections, sorted", `{"summary": {"title": "Outage", "impact": "low", "owner": "me"}, "zeta": {}, "alpha": {}}`, []string{"summary.owner unknown", "alpha unknown", "zeta unknown"}},
+		{"every problem is reported", `{"summary": {"title": 1, "impact": "medium"}, "metrics": {"ratio": 2}}`, []string{"summary.title invalid-type", "summary.impact invalid-option", "metrics.ratio too-large"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.ValidateBody(tt.body)
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("expected a valid body, got %v", err)
+				}
+				return
+			}
+
+			verr, ok := err.(*TemplateValidationError)
+			if !ok {
+				t.Fatalf("expected a *TemplateValidationError, got %v", err)
+			}
+
+			got := make([]string, 0, len(verr.Fields))
+			for _, field := range verr.Fields {
+				got = append(got, field.Field+" "+field.Code)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got problems %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
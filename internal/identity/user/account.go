@@ -0,0 +1,85 @@
+package user
+
+import (
+	"amg/internal/api/errors"
+	"amg/pkg/util/validation"
+	"strings"
+)
+
+var (
+	ErrInvalidAccountToken  = errors.New("user.invalid-token", "Invalid or expired token")
+	ErrEmailNotVerified     = errors.New("user.email-not-verified", "Email address has not been verified")
+	ErrEmailAlreadyVerified = errors.New("user.email-already-verified", "Email address is already verified")
+
+	ErrInvalidCurrentPassword = errors.New("user.invalid-current-password", "Current password is incorrect")
+	ErrPasswordReused         = errors.New("user.password-reused", "Password has been used recently")
+)
+
+// PasswordPolicyError lists the rules of the password policy a new password breaks
+type PasswordPolicyError struct {
+	Violations []string `json:"violations"`
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "Password " + strings.Join(e.Violations, ", ")
+}
+
+// Purposes of single-use account tokens
+const (
+	TokenPurposeResetPassword = "reset_password"
+	TokenPurposeVerifyEmail   = "verify_email"
+)
+
+type ForgotPasswordCommand struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordCommand struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordCommand struct {
+	ID              int64  `json:"-"`
+	SessionID       string `json:"-"`
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type VerifyEmailCommand struct {
+	Token string `json:"token"`
+}
+
+func (cmd *ForgotPasswordCommand) Validate() error {
+	if len(cmd.Email) == 0 || !validation.IsValidEmail(cmd.Email) {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+func (cmd *ResetPasswordCommand) Validate() error {
+	if len(cmd.Token) == 0 || len(cmd.Token) > 255 {
+		return ErrInvalidAccountToken
+	}
+	if len(cmd.Password) == 0 {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+func (cmd *ChangePasswordCommand) Validate() error {
+	if cmd.ID <= 0 {
+		return ErrInvalidID
+	}
+	if len(cmd.CurrentPassword) == 0 || len(cmd.NewPassword) == 0 {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+func (cmd *VerifyEmailCommand) Validate() error {
+	if len(cmd.Token) == 0 || len(cmd.Token) > 255 {
+		return ErrInvalidAccountToken
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package user
+
+import (
+	"amg/internal/api/errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAPIKeyNotFound       = errors.New("user.api-key-not-found", "API key not found")
+	ErrInvalidAPIKey        = errors.New("user.invalid-api-key", "Invalid, expired or revoked API key")
+	ErrInvalidAPIKeyName    = errors.New("user.invalid-api-key-name", "Invalid API key name")
+	ErrInvalidAPIKeyScopes  = errors.New("user.invalid-api-key-scopes", "API key scopes must be permissions of the owner")
+	ErrInvalidAPIKeyExpiry  = errors.New("user.invalid-api-key-expiry", "API key expiry must be in the future")
+	ErrInvalidServiceName   = errors.New("user.invalid-service-name", "Service account names are 3 to 50 lowercase letters, digits or dashes")
+	ErrServiceAccountLogin  = errors.New("user.service-account-login", "Service accounts cannot log in")
+	ErrNotAServiceAccount   = errors.New("user.not-a-service-account", "User is not a service account")
+	ErrAPIKeyNotAllowedHere = errors.New("user.api-key-not-allowed", "This endpoint requires a user session")
+)
+
+// ServiceAccountDomain is the reserved domain of the placeholder email addresses
+// of service accounts
+const ServiceAccountDomain = "service-accounts.invalid"
+
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{2,49}$`)
+
+// APIKey lets a program act as its owner in one organization, limited to Scopes.
+// OwnerRole is the current role of the owner in that organization, filled in
+// when a key is authenticated.
+type APIKey struct {
+	ID             int64          `db:"id" json:"id"`
+	UserID         int64          `db:"user_id" json:"user_id"`
+	OrganizationID int64          `db:"organization_id" json:"organization_id"`
+	Name           string         `db:"name" json:"name"`
+	Prefix         string         `db:"prefix" json:"prefix"`
+	Scopes         pq.StringArray `db:"scopes" json:"scopes"`
+	CreatedBy      *int64         `db:"created_by" json:"created_by"`
+	ExpiresAt      *string        `db:"expires_at" json:"expires_at"`
+	LastUsedAt     *string        `db:"last_used_at" json:"last_used_at"`
+	RevokedAt      *string        `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt      string         `db:"created_at" json:"created_at"`
+
+	OwnerRole string `db:"owner_role" json:"-"`
+}
+
+// CreatedAPIKey is returned once, at creation; Key is not stored and cannot be
+// shown again
+type CreatedAPIKey struct {
+	*APIKey
+
+	Key string `json:"key"`
+}
+
+type CreateAPIKeyCommand struct {
+	UserID    int64      `json:"-"`
+	CreatedBy int64      `json:"-"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreateServiceAccountCommand struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+func (cmd *CreateAPIKeyCommand) Validate() error {
+	if cmd.UserID <= 0 {
+		return ErrInvalidID
+	}
+	if len(cmd.Name) == 0 || len(cmd.Name) > 100 {
+		return ErrInvalidAPIKeyName
+	}
+	if len(cmd.Scopes) == 0 {
+		return ErrInvalidAPIKeyScopes
+	}
+	if cmd.ExpiresAt != nil && !cmd.ExpiresAt.After(time.Now()) {
+		return ErrInvalidAPIKeyExpiry
+	}
+	return nil
+}
+
+func (cmd *CreateServiceAccountCommand) Validate() error {
+	if !serviceNamePattern.MatchString(cmd.Name) {
+		return ErrInvalidServiceName
+	}
+	if len(strings.TrimSpace(cmd.Role)) == 0 {
+		return ErrorInvalidRole
+	}
+	return nil
+}
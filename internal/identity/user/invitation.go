@@ -0,0 +1,84 @@
+package user
+
+import (
+	"amg/internal/api/errors"
+	"amg/pkg/util/validation"
+	"strings"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("user.invitation-not-found", "Invitation not found")
+	ErrInvalidInvitation  = errors.New("user.invalid-invitation", "Invalid, expired or revoked invitation")
+	ErrInvitationPending  = errors.New("user.invitation-pending", "An invitation for this email is already pending")
+)
+
+// Invitation offers Email a role in an organization. It is pending until it is
+// accepted or revoked; pending invitations past ExpiresAt can be resent.
+type Invitation struct {
+	ID             int64   `db:"id" json:"id"`
+	OrganizationID int64   `db:"organization_id" json:"organization_id"`
+	Email          string  `db:"email" json:"email"`
+	Role           string  `db:"role" json:"role"`
+	InvitedBy      *int64  `db:"invited_by" json:"invited_by"`
+	ExpiresAt      string  `db:"expires_at" json:"expires_at"`
+	Expired        bool    `db:"expired" json:"expired"`
+	SentAt         string  `db:"sent_at" json:"sent_at"`
+	AcceptedAt     *string `db:"accepted_at" json:"accepted_at,omitempty"`
+	RevokedAt      *string `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt      string  `db:"created_at" json:"created_at"`
+
+	OrganizationName string `db:"organization_name" json:"-"`
+}
+
+type CreateInvitationCommand struct {
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	InvitedBy int64  `json:"-"`
+}
+
+// AcceptInvitationCommand is an invitee answering their invitation. People who
+// have no account yet sign up with it and need the profile fields and a
+// password; those who have one only join the organization.
+type AcceptInvitationCommand struct {
+	Token       string `json:"token"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Password    string `json:"password"`
+	Address     string `json:"address"`
+	PhoneNumber string `json:"phone_number"`
+	DateOfBirth string `json:"date_of_birth"`
+}
+
+func (cmd *CreateInvitationCommand) Validate() error {
+	if len(cmd.Email) == 0 || len(cmd.Email) > 255 || !validation.IsValidEmail(cmd.Email) {
+		return ErrInvalidEmail
+	}
+	if len(strings.TrimSpace(cmd.Role)) == 0 {
+		return ErrorInvalidRole
+	}
+	return nil
+}
+
+func (cmd *AcceptInvitationCommand) Validate() error {
+	if len(cmd.Token) == 0 || len(cmd.Token) > 2048 {
+		return ErrInvalidInvitation
+	}
+	return nil
+}
+
+// ValidateSignUp checks the fields needed to create the account of the invitee
+func (cmd *AcceptInvitationCommand) ValidateSignUp() error {
+	if len(strings.TrimSpace(cmd.FirstName)) == 0 || len(cmd.FirstName) <= 2 {
+		return ErrInvalidFirstName
+	}
+	if len(strings.TrimSpace(cmd.LastName)) == 0 || len(cmd.LastName) <= 2 {
+		return ErrInvalidLastName
+	}
+	if len(cmd.Password) == 0 {
+		return ErrInvalidPassword
+	}
+	if len(cmd.PhoneNumber) > 0 && !validation.IsValidPhoneNumber(cmd.PhoneNumber) {
+		return ErrInvalidPhoneNumber
+	}
+	return nil
+}
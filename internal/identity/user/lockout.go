@@ -0,0 +1,26 @@
+package user
+
+import (
+	"amg/internal/api/errors"
+	"time"
+)
+
+var (
+	ErrAccountLocked        = errors.New("user.account-locked", "Account is temporarily locked after too many failed logins")
+	ErrTooManyLoginAttempts = errors.New("user.too-many-login-attempts", "Too many failed logins, try again later")
+)
+
+// LoginThrottledError refuses a login attempt until RetryAfter has passed. Err is
+// ErrAccountLocked or ErrTooManyLoginAttempts.
+type LoginThrottledError struct {
+	Err        errors.ErrorStatus
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,145 @@
+package user
+
+import (
+	"amg/internal/api/errors"
+	"strings"
+)
+
+var (
+	ErrMFAAlreadyEnabled     = errors.New("user.mfa-already-enabled", "Two-factor authentication is already enabled")
+	ErrMFANotEnabled         = errors.New("user.mfa-not-enabled", "Two-factor authentication is not enabled")
+	ErrMFANotEnrolled        = errors.New("user.mfa-not-enrolled", "Start the two-factor enrollment first")
+	ErrMFARequired           = errors.New("user.mfa-required", "Two-factor authentication is required for your role")
+	ErrInvalidMFACode        = errors.New("user.invalid-mfa-code", "Invalid two-factor code")
+	ErrInvalidMFAToken       = errors.New("user.invalid-mfa-token", "Invalid or expired MFA token")
+	ErrMFAEnrollmentRequired = errors.New("user.mfa-enrollment-required", "Two-factor authentication must be set up to log in")
+)
+
+// LoginResult is the outcome of a login step. Either the tokens are set, or
+// MFARequired is and the client has to complete the login at /users/login/mfa
+// with MFAToken and a code. MFAEnrollmentRequired means the role of the user
+// demands two-factor authentication they have not set up yet; the client first
+// enrolls at /users/login/mfa/enroll with the same token.
+type LoginResult struct {
+	*TokenPair
+
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token,omitempty"`
+	MFATokenExpiresIn     int64  `json:"mfa_token_expires_in,omitempty"`
+
+	// RecoveryCodes are returned once, when enrollment completes during login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// MFAChallenge is the pending login an MFA token stands for
+type MFAChallenge struct {
+	UserID         int64  `json:"user_id"`
+	OrganizationID int64  `json:"organization_id"`
+	Enroll         bool   `json:"enroll"`
+	UserAgent      string `json:"user_agent"`
+	IPAddress      string `json:"ip_address"`
+}
+
+// TOTP is the stored authenticator of a user
+type TOTP struct {
+	UserID       int64   `db:"user_id"`
+	Secret       string  `db:"secret"`
+	EnabledAt    *string `db:"enabled_at"`
+	LastUsedStep int64   `db:"last_used_step"`
+}
+
+// TOTPEnrollment carries the secret of a new authenticator. URI is the otpauth://
+// provisioning URI to render as a QR code.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type MFAStatus struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+type MFAPolicy struct {
+	RequiredRoles []string `json:"required_roles"`
+}
+
+type ConfirmTOTPCommand struct {
+	ID   int64  `json:"-"`
+	Code string `json:"code"`
+}
+
+type DisableTOTPCommand struct {
+	ID       int64  `json:"-"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type RegenerateRecoveryCodesCommand struct {
+	ID   int64  `json:"-"`
+	Code string `json:"code"`
+}
+
+type EnrollMFALoginCommand struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+// LoginMFACommand completes a login with a TOTP code or, when the authenticator
+// is lost, a recovery code
+type LoginMFACommand struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (cmd *ConfirmTOTPCommand) Validate() error {
+	if len(cmd.Code) == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (cmd *DisableTOTPCommand) Validate() error {
+	if len(cmd.Password) == 0 {
+		return ErrInvalidPassword
+	}
+	if len(cmd.Code) == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (cmd *RegenerateRecoveryCodesCommand) Validate() error {
+	if len(cmd.Code) == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (cmd *EnrollMFALoginCommand) Validate() error {
+	if len(cmd.MFAToken) == 0 || len(cmd.MFAToken) > 255 {
+		return ErrInvalidMFAToken
+	}
+	return nil
+}
+
+func (cmd *LoginMFACommand) Validate() error {
+	if len(cmd.MFAToken) == 0 || len(cmd.MFAToken) > 255 {
+		return ErrInvalidMFAToken
+	}
+	if len(cmd.Code) == 0 && len(cmd.RecoveryCode) == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (p *MFAPolicy) Validate() error {
+	for _, role := range p.RequiredRoles {
+		if len(strings.TrimSpace(role)) == 0 {
+			return ErrorInvalidRole
+		}
+	}
+	return nil
+}
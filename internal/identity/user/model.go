@@ -2,7 +2,6 @@ package user
 
 import (
 	"amg/internal/api/errors"
-	util "amg/pkg/util/password"
 	"amg/pkg/util/validation"
 	"strings"
 )
@@ -21,56 +20,56 @@ var (
 	ErrEmailAlreadyExists = errors.New("user.email-already-exists", "Email already exists")
 	ErrorInvalidRole      = errors.New("user.invalid-role", "Invalid role")
 	ErrInvalidStatus      = errors.New("user.invalid-status", "Invalid status")
+	ErrInvalidTeam        = errors.New("user.invalid-team", "Invalid team")
+	ErrSharedUser         = errors.New("user.shared", "User belongs to other organizations too; only their role and team in this organization can be changed")
 )
 
 const (
-	RoleUser  = "user"
-	RoleAdmin = "admin"
+	RoleUser     = "user"
+	RoleAdmin    = "admin"
+	RoleReviewer = "reviewer"
 )
 
 type User struct {
-	ID           int64  `db:"id" json:"id"`
-	FirstName    string `db:"first_name" json:"first_name"`
-	LastName     string `db:"last_name" json:"last_name"`
-	Email        string `db:"email" json:"email"`
-	PasswordHash string `db:"password_hash" json:"-"`
-	Address      string `db:"address" json:"address"`
-	PhoneNumber  string `db:"phone_number" json:"phone_number"`
-	DateOfBirth  string `db:"date_of_birth" json:"date_of_birth"`
-	Role         string `db:"role" json:"role"`
-	CreatedAt    string `db:"created_at" json:"created_at"`
-	UpdatedAt    string `db:"updated_at" json:"updated_at"`
+	ID           int64   `db:"id" json:"id"`
+	FirstName    string  `db:"first_name" json:"first_name"`
+	LastName     string  `db:"last_name" json:"last_name"`
+	Email        string  `db:"email" json:"email"`
+	PasswordHash string  `db:"password_hash" json:"-"`
+	Address      string  `db:"address" json:"address"`
+	PhoneNumber  string  `db:"phone_number" json:"phone_number"`
+	DateOfBirth  *string `db:"date_of_birth" json:"date_of_birth"`
+	Role         string  `db:"role" json:"role"`
+	Team         string  `db:"team" json:"team"`
+	CreatedAt    string  `db:"created_at" json:"created_at"`
+	UpdatedAt    string  `db:"updated_at" json:"updated_at"`
+
+	EmailVerifiedAt *string `db:"email_verified_at" json:"email_verified_at"`
+	ServiceAccount  bool    `db:"service_account" json:"service_account"`
 }
 
-var validRoles = map[string]bool{
-	RoleUser:  true,
-	RoleAdmin: true,
-}
-
-func IsValidRole(role string) bool {
-	return validRoles[role]
-}
-
-type CreateUserCommand struct {
+type UpdateUserCommand struct {
+	ID          int64  `json:"id"`
 	FirstName   string `json:"first_name"`
 	LastName    string `json:"last_name"`
 	Email       string `json:"email"`
-	Password    string `json:"password_hash"`
 	Address     string `json:"address"`
 	PhoneNumber string `json:"phone_number"`
 	DateOfBirth string `json:"date_of_birth"`
 	Role        string `json:"role"`
+	Team        string `json:"team"`
 }
 
-type UpdateUserCommand struct {
-	ID          int64  `json:"id"`
+// UpdateProfileCommand is a user editing their own profile. ID is taken from the
+// token, never from the request body.
+type UpdateProfileCommand struct {
+	ID          int64  `json:"-"`
 	FirstName   string `json:"first_name"`
 	LastName    string `json:"last_name"`
 	Email       string `json:"email"`
 	Address     string `json:"address"`
 	PhoneNumber string `json:"phone_number"`
 	DateOfBirth string `json:"date_of_birth"`
-	Role        string `json:"role"`
 }
 
 type SearchUserQuery struct {
@@ -81,8 +80,12 @@ type SearchUserQuery struct {
 	PhoneNumber string `query:"phone_number"`
 	DateOfBirth string `query:"date_of_birth"`
 	Role        string `query:"role"`
+	Team        string `query:"team"`
 	Page        int    `query:"page"`
 	PerPage     int    `query:"per_page"`
+
+	// ID limits the search to one user, for callers only allowed to see themselves
+	ID int64 `query:"-"`
 }
 
 type SearchUserResult struct {
@@ -105,42 +108,49 @@ type RegisterUserCommand struct {
 type LoginUserCommand struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+
+	// OrganizationID picks the organization to log in to; without it, the user
+	// logs in to the first organization they joined
+	OrganizationID int64 `json:"organization_id"`
+
+	// UserAgent and IPAddress describe the client for the session list
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
 }
 
 type LogoutUserCommand struct {
 	Token string `json:"token"`
 }
 
-func (cmd *CreateUserCommand) Validate() error {
-	if len(cmd.FirstName) == 0 || len(cmd.FirstName) <= 2 {
+func (cmd *UpdateUserCommand) Validate() error {
+	if cmd.ID == 0 {
+		return ErrUserNotFound
+	}
+	if len(strings.TrimSpace(cmd.FirstName)) == 0 || len(cmd.FirstName) <= 2 {
 		return ErrInvalidFirstName
 	}
-	if len(cmd.LastName) == 0 || len(cmd.LastName) <= 2 {
+	if len(strings.TrimSpace(cmd.LastName)) == 0 || len(cmd.LastName) <= 2 {
 		return ErrInvalidLastName
 	}
-	if len(cmd.Email) == 0 || !validation.IsValidEmail(cmd.Email) {
-		return ErrInvalidEmail
-	}
-	if len(cmd.Password) == 0 || !util.IsValidPassword(cmd.Password) {
-		return ErrInvalidPassword
-	}
-	if len(cmd.Address) == 0 {
+	if len(strings.TrimSpace(cmd.Address)) == 0 {
 		return ErrInvalidAddress
 	}
-	if len(cmd.PhoneNumber) == 0 {
+	if len(cmd.PhoneNumber) == 0 || !validation.IsValidPhoneNumber(cmd.PhoneNumber) {
 		return ErrInvalidPhoneNumber
 	}
-	if len(cmd.DateOfBirth) == 0 {
-		return ErrInvalidDateOfBirth
+	if len(cmd.Email) > 0 && !validation.IsValidEmail(cmd.Email) {
+		return ErrInvalidEmail
 	}
-	if !IsValidRole(cmd.Role) {
+	if len(strings.TrimSpace(cmd.Role)) == 0 {
 		return ErrorInvalidRole
 	}
-
+	if len(cmd.Team) > 100 {
+		return ErrInvalidTeam
+	}
 	return nil
 }
 
-func (cmd *UpdateUserCommand) Validate() error {
+func (cmd *UpdateProfileCommand) Validate() error {
 	if cmd.ID == 0 {
 		return ErrUserNotFound
 	}
@@ -150,17 +160,17 @@ func (cmd *UpdateUserCommand) Validate() error {
 	if len(strings.TrimSpace(cmd.LastName)) == 0 || len(cmd.LastName) <= 2 {
 		return ErrInvalidLastName
 	}
+	if len(cmd.Email) == 0 || !validation.IsValidEmail(cmd.Email) {
+		return ErrInvalidEmail
+	}
 	if len(strings.TrimSpace(cmd.Address)) == 0 {
 		return ErrInvalidAddress
 	}
 	if len(cmd.PhoneNumber) == 0 || !validation.IsValidPhoneNumber(cmd.PhoneNumber) {
 		return ErrInvalidPhoneNumber
 	}
-	if len(cmd.Email) > 0 && !validation.IsValidEmail(cmd.Email) {
-		return ErrInvalidEmail
-	}
-	if !IsValidRole(cmd.Role) {
-		return ErrorInvalidRole
+	if len(cmd.DateOfBirth) == 0 {
+		return ErrInvalidDateOfBirth
 	}
 	return nil
 }
@@ -175,7 +185,7 @@ func (cmd *RegisterUserCommand) Validate() error {
 	if len(cmd.Email) == 0 || !validation.IsValidEmail(cmd.Email) {
 		return ErrInvalidEmail
 	}
-	if len(cmd.Password) == 0 || !util.IsValidPassword(cmd.Password) {
+	if len(cmd.Password) == 0 {
 		return ErrInvalidPassword
 	}
 	if len(cmd.Address) == 0 {
@@ -195,7 +205,7 @@ func (cmd *LoginUserCommand) Validate() error {
 	if len(cmd.Email) == 0 || !validation.IsValidEmail(cmd.Email) {
 		return ErrInvalidEmail
 	}
-	if len(cmd.Password) == 0 || !util.IsValidPassword(cmd.Password) {
+	if len(cmd.Password) == 0 {
 		return ErrInvalidPassword
 	}
 	return nil
@@ -0,0 +1,56 @@
+package user
+
+import (
+	"amg/internal/api/errors"
+)
+
+var (
+	ErrOIDCDisabled          = errors.New("user.oidc-disabled", "Single sign-on is not configured")
+	ErrInvalidOIDCState      = errors.New("user.invalid-oidc-state", "Invalid or expired login state")
+	ErrInvalidOIDCCode       = errors.New("user.invalid-oidc-code", "Invalid authorization code")
+	ErrOIDCEmailNotVerified  = errors.New("user.oidc-email-not-verified", "The identity provider has not verified your email address")
+	ErrOIDCAccountNotAllowed = errors.New("user.oidc-account-not-allowed", "No account exists for this email address")
+)
+
+// OIDCAuthorization starts a single sign-on login: the client sends the browser to
+// AuthorizationURL and hands code and state from the callback to OIDCLogin
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+	ExpiresIn        int64  `json:"expires_in"`
+}
+
+// OIDCState is what the server remembers about a pending single sign-on login
+type OIDCState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// Identity links a user to an account at an OpenID Connect issuer
+type Identity struct {
+	ID          int64  `db:"id" json:"id"`
+	UserID      int64  `db:"user_id" json:"user_id"`
+	Issuer      string `db:"issuer" json:"issuer"`
+	Subject     string `db:"subject" json:"subject"`
+	Email       string `db:"email" json:"email"`
+	CreatedAt   string `db:"created_at" json:"created_at"`
+	LastLoginAt string `db:"last_login_at" json:"last_login_at"`
+}
+
+type OIDCLoginCommand struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
+}
+
+func (cmd *OIDCLoginCommand) Validate() error {
+	if len(cmd.State) == 0 || len(cmd.State) > 255 {
+		return ErrInvalidOIDCState
+	}
+	if len(cmd.Code) == 0 || len(cmd.Code) > 2048 {
+		return ErrInvalidOIDCCode
+	}
+	return nil
+}
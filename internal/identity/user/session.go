@@ -0,0 +1,47 @@
+package user
+
+import (
+	"amg/internal/api/errors"
+)
+
+var (
+	ErrSessionNotFound  = errors.New("user.session-not-found", "Session not found")
+	ErrSessionRevoked   = errors.New("user.session-revoked", "Session has been revoked")
+	ErrInvalidSessionID = errors.New("user.invalid-session-id", "Invalid session id")
+)
+
+// Session is one login of a user. All access and refresh tokens issued from that
+// login carry its ID, so revoking the session ends all of them at once. A session
+// acts in one organization of the user.
+type Session struct {
+	ID             string `db:"id" json:"id"`
+	UserID         int64  `db:"user_id" json:"user_id"`
+	OrganizationID int64  `db:"organization_id" json:"organization_id"`
+	UserAgent      string `db:"user_agent" json:"user_agent"`
+	IPAddress      string `db:"ip_address" json:"ip_address"`
+	CreatedAt      string `db:"created_at" json:"created_at"`
+	LastSeenAt     string `db:"last_seen_at" json:"last_seen_at"`
+	ExpiresAt      string `db:"expires_at" json:"expires_at"`
+	Current        bool   `db:"-" json:"current"`
+}
+
+// SwitchOrganizationCommand ends the current session of a user and starts one in
+// another of their organizations
+type SwitchOrganizationCommand struct {
+	UserID         int64  `json:"-"`
+	SessionID      string `json:"-"`
+	OrganizationID int64  `json:"organization_id"`
+
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
+}
+
+func (cmd *SwitchOrganizationCommand) Validate() error {
+	if cmd.OrganizationID <= 0 {
+		return ErrInvalidID
+	}
+	if len(cmd.SessionID) == 0 {
+		return ErrInvalidSessionID
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package user
+
+import (
+	"amg/internal/api/errors"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("user.invalid-refresh-token", "Invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("user.refresh-token-reused", "Refresh token has already been used")
+)
+
+const TokenTypeBearer = "Bearer"
+
+// TokenPair is returned by login and refresh. The access token is a short-lived
+// JWT; the refresh token is an opaque secret that can be exchanged exactly once.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken is the stored form of a refresh token; only its hash is kept.
+// Tokens issued from one login share a FamilyID, the id of its session, so that
+// a replayed token can revoke every token descended from the same login.
+// ReplacedBy is set once the token has been exchanged for its successor.
+type RefreshToken struct {
+	ID         int64   `db:"id"`
+	UserID     int64   `db:"user_id"`
+	FamilyID   string  `db:"family_id"`
+	TokenHash  string  `db:"token_hash"`
+	Expired    bool    `db:"expired"`
+	RevokedAt  *string `db:"revoked_at"`
+	ReplacedBy *int64  `db:"replaced_by"`
+	CreatedAt  string  `db:"created_at"`
+}
+
+type RefreshTokenCommand struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (cmd *RefreshTokenCommand) Validate() error {
+	if len(cmd.RefreshToken) == 0 || len(cmd.RefreshToken) > 255 {
+		return ErrInvalidRefreshToken
+	}
+
+	return nil
+}
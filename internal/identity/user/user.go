@@ -3,15 +3,54 @@ package user
 import "context"
 
 type Service interface {
-	CreateUser(ctx context.Context, cmd *CreateUserCommand) error
 	UpdateUser(ctx context.Context, cmd *UpdateUserCommand) error
+	UpdateProfile(ctx context.Context, cmd *UpdateProfileCommand) error
 	GetByUserID(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
 	DeleteUser(ctx context.Context, id int64) error
 	SearchUser(ctx context.Context, query *SearchUserQuery) (*SearchUserResult, error)
-	GetUserByEmail(ctx context.Context, cmd *LoginUserCommand) (string, error)
+	GetUserByEmail(ctx context.Context, cmd *LoginUserCommand) (*LoginResult, error)
+	RefreshToken(ctx context.Context, cmd *RefreshTokenCommand) (*TokenPair, error)
+	UnlockUser(ctx context.Context, id int64) error
 
 	RegisterDefaultUser(ctx context.Context, cmd *RegisterUserCommand) error
 
-	IsTokenBlacklisted(ctx context.Context, token string) (bool, error)
-	InvalidateToken(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, cmd *ForgotPasswordCommand) error
+	ResetPassword(ctx context.Context, cmd *ResetPasswordCommand) error
+	ChangePassword(ctx context.Context, cmd *ChangePasswordCommand) error
+	SendVerificationEmail(ctx context.Context, userID int64) error
+	VerifyEmail(ctx context.Context, cmd *VerifyEmailCommand) error
+
+	GetSessions(ctx context.Context, userID int64, currentID string) ([]*Session, error)
+	RevokeSession(ctx context.Context, userID int64, sessionID string) error
+	RevokeAllSessions(ctx context.Context, userID int64) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	TouchSession(ctx context.Context, sessionID string) error
+	SwitchOrganization(ctx context.Context, cmd *SwitchOrganizationCommand) (*TokenPair, error)
+
+	GetMFAStatus(ctx context.Context, userID int64) (*MFAStatus, error)
+	EnrollTOTP(ctx context.Context, userID int64) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, cmd *ConfirmTOTPCommand) ([]string, error)
+	DisableTOTP(ctx context.Context, cmd *DisableTOTPCommand) error
+	RegenerateRecoveryCodes(ctx context.Context, cmd *RegenerateRecoveryCodesCommand) ([]string, error)
+	EnrollMFALogin(ctx context.Context, cmd *EnrollMFALoginCommand) (*TOTPEnrollment, error)
+	LoginMFA(ctx context.Context, cmd *LoginMFACommand) (*LoginResult, error)
+	GetMFAPolicy(ctx context.Context) (*MFAPolicy, error)
+	UpdateMFAPolicy(ctx context.Context, policy *MFAPolicy) error
+
+	OIDCAuthorize(ctx context.Context) (*OIDCAuthorization, error)
+	OIDCLogin(ctx context.Context, cmd *OIDCLoginCommand) (*LoginResult, error)
+
+	CreateAPIKey(ctx context.Context, cmd *CreateAPIKeyCommand) (*CreatedAPIKey, error)
+	GetAPIKeys(ctx context.Context, userID int64) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id int64) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error)
+	CreateServiceAccount(ctx context.Context, cmd *CreateServiceAccountCommand) (*User, error)
+	GetServiceAccounts(ctx context.Context) ([]*User, error)
+
+	CreateInvitation(ctx context.Context, cmd *CreateInvitationCommand) (*Invitation, error)
+	GetInvitations(ctx context.Context) ([]*Invitation, error)
+	ResendInvitation(ctx context.Context, id int64) (*Invitation, error)
+	RevokeInvitation(ctx context.Context, id int64) error
+	AcceptInvitation(ctx context.Context, cmd *AcceptInvitationCommand) error
 }
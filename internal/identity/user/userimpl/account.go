@@ -0,0 +1,153 @@
+package userimpl
+
+import (
+	"amg/internal/identity/user"
+	"amg/internal/mailer"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// accountLink builds the link to the frontend page handling a token
+func (s *service) accountLink(path, token string) string {
+	return strings.TrimRight(s.cfg.Mail.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// ForgotPassword mails a password reset link. Unknown addresses are ignored
+// silently so the endpoint cannot be used to find out who has an account.
+func (s *service) ForgotPassword(ctx context.Context, cmd *user.ForgotPasswordCommand) error {
+	result, err := s.store.getUserByEmail(ctx, cmd.Email)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	token, err := newSecret()
+	if err != nil {
+		return err
+	}
+
+	err = s.store.createAccountToken(ctx, result.ID, user.TokenPurposeResetPassword, hashToken(token), s.cfg.Mail.ResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.cfg.Mailer.Send(ctx, &mailer.Message{
+		To:      result.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nsomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %s. If you did not ask for this, ignore this email.\n",
+			result.FirstName,
+			s.accountLink("/reset-password", token),
+			s.cfg.Mail.ResetTokenTTL,
+		),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and logs the user out
+// everywhere, since whoever knew the old password should not stay signed in
+func (s *service) ResetPassword(ctx context.Context, cmd *user.ResetPasswordCommand) error {
+	tokenHash := hashToken(cmd.Token)
+
+	// Look the token up first to check the password history of its user; it is
+	// only consumed once the new password is accepted
+	userID, err := s.store.peekAccountToken(ctx, user.TokenPurposeResetPassword, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	if userID == 0 {
+		return user.ErrInvalidAccountToken
+	}
+
+	result, err := s.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := s.hashNewPassword(cmd.Password)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkPasswordReuse(ctx, result, cmd.Password)
+	if err != nil {
+		return err
+	}
+
+	userID, err = s.store.resetPassword(ctx, tokenHash, passwordHash, s.cfg.PasswordPolicy.HistoryDepth)
+	if err != nil {
+		return err
+	}
+
+	if userID == 0 {
+		return user.ErrInvalidAccountToken
+	}
+
+	s.log.Info("password reset", zap.Int64("user_id", userID))
+
+	// In every organization: the reset comes without one
+	_, err = s.revokeSessions(ctx, userID, "", "")
+	return err
+}
+
+// SendVerificationEmail mails a verification link to the current address of the user
+func (s *service) SendVerificationEmail(ctx context.Context, userID int64) error {
+	result, err := s.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.EmailVerifiedAt != nil {
+		return user.ErrEmailAlreadyVerified
+	}
+
+	token, err := newSecret()
+	if err != nil {
+		return err
+	}
+
+	err = s.store.createAccountToken(ctx, result.ID, user.TokenPurposeVerifyEmail, hashToken(token), s.cfg.Mail.VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.cfg.Mailer.Send(ctx, &mailer.Message{
+		To:      result.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nplease confirm that this is your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			result.FirstName,
+			s.accountLink("/verify-email", token),
+			s.cfg.Mail.VerifyTokenTTL,
+		),
+	})
+}
+
+// sendVerificationEmail is SendVerificationEmail for flows that must not fail
+// because of the mail server, such as registration; the user can ask again later
+func (s *service) sendVerificationEmail(ctx context.Context, userID int64) {
+	err := s.SendVerificationEmail(ctx, userID)
+	if err != nil && err != user.ErrEmailAlreadyVerified {
+		s.log.Error("failed to send verification email", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+func (s *service) VerifyEmail(ctx context.Context, cmd *user.VerifyEmailCommand) error {
+	userID, err := s.store.verifyEmail(ctx, hashToken(cmd.Token))
+	if err != nil {
+		return err
+	}
+
+	if userID == 0 {
+		return user.ErrInvalidAccountToken
+	}
+
+	return nil
+}
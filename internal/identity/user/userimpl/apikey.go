@@ -0,0 +1,178 @@
+package userimpl
+
+import (
+	"amg/internal/identity/user"
+	util "amg/pkg/util/password"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// apiKeyPrefix marks amg keys so that secret scanners can recognize them
+	apiKeyPrefix = "amg_"
+
+	seenAPIKeyPrefix = "apikey:seen:"
+)
+
+// newAPIKey returns a key of the form amg_<id>_<secret>. The id part is kept in
+// clear to tell keys apart in listings.
+func newAPIKey() (string, string, error) {
+	buf := make([]byte, 4)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(buf)
+
+	return prefix + "_" + secret, prefix, nil
+}
+
+// CreateAPIKey issues a key acting as cmd.UserID. Scopes may only name
+// permissions the role of the owner grants.
+func (s *service) CreateAPIKey(ctx context.Context, cmd *user.CreateAPIKeyCommand) (*user.CreatedAPIKey, error) {
+	owner, err := s.GetByUserID(ctx, cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, scope := range cmd.Scopes {
+		allowed, err := s.access.HasPermission(ctx, owner.Role, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		if !allowed {
+			return nil, user.ErrInvalidAPIKeyScopes
+		}
+	}
+
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.store.createAPIKey(ctx, &user.APIKey{
+		UserID:    cmd.UserID,
+		Name:      cmd.Name,
+		Prefix:    prefix,
+		Scopes:    cmd.Scopes,
+		CreatedBy: &cmd.CreatedBy,
+	}, hashToken(key), cmd.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("api key created",
+		zap.Int64("user_id", cmd.UserID),
+		zap.Int64("created_by", cmd.CreatedBy),
+		zap.String("prefix", prefix),
+	)
+
+	return &user.CreatedAPIKey{APIKey: result, Key: key}, nil
+}
+
+func (s *service) GetAPIKeys(ctx context.Context, userID int64) ([]*user.APIKey, error) {
+	return s.store.getAPIKeys(ctx, userID)
+}
+
+func (s *service) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	revoked, err := s.store.revokeAPIKey(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return user.ErrAPIKeyNotFound
+	}
+
+	s.log.Info("api key revoked", zap.Int64("user_id", userID), zap.Int64("api_key_id", id))
+
+	return nil
+}
+
+// AuthenticateAPIKey returns the live key matching key, with the role of its owner
+func (s *service) AuthenticateAPIKey(ctx context.Context, key string) (*user.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(key) > 255 {
+		return nil, user.ErrInvalidAPIKey
+	}
+
+	result, err := s.store.getLiveAPIKey(ctx, hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, user.ErrInvalidAPIKey
+	}
+
+	// Last use is recorded at most once a minute, like the last seen time of sessions
+	first, err := s.redisClient.SetNX(ctx, seenAPIKeyPrefix+result.Prefix, 1, lastSeenResolution).Result()
+	if err == nil && first {
+		err = s.store.touchAPIKey(ctx, result.ID)
+	}
+	if err != nil {
+		s.log.Warn("failed to record api key use", zap.Int64("api_key_id", result.ID), zap.Error(err))
+	}
+
+	return result, nil
+}
+
+// CreateServiceAccount creates a user for programs. It has a placeholder email
+// address and a random password, so it can only act through API keys.
+func (s *service) CreateServiceAccount(ctx context.Context, cmd *user.CreateServiceAccountCommand) (*user.User, error) {
+	err := s.checkRoles(ctx, cmd.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	email := cmd.Name + "@" + user.ServiceAccountDomain
+
+	taken, err := s.store.getUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if taken != nil {
+		return nil, user.ErrUserAlreadyExists
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := util.HashPassword(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.store.createServiceAccount(ctx, &user.User{
+		FirstName:    cmd.Name,
+		LastName:     "service account",
+		Email:        email,
+		PasswordHash: passwordHash,
+		Role:         cmd.Role,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("service account created", zap.Int64("user_id", id), zap.String("role", cmd.Role))
+
+	return s.GetByUserID(ctx, id)
+}
+
+func (s *service) GetServiceAccounts(ctx context.Context) ([]*user.User, error) {
+	return s.store.getServiceAccounts(ctx)
+}
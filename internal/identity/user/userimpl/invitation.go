@@ -0,0 +1,197 @@
+package userimpl
+
+import (
+	"amg/internal/identity/organization"
+	"amg/internal/identity/user"
+	"amg/internal/mailer"
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CreateInvitation invites cmd.Email to the organization ctx acts in and mails
+// them the link to accept. The invitation is kept when mailing fails, so that it
+// can be resent.
+func (s *service) CreateInvitation(ctx context.Context, cmd *user.CreateInvitationCommand) (*user.Invitation, error) {
+	err := s.checkRoles(ctx, cmd.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.store.createInvitation(ctx, &user.Invitation{
+		Email:     cmd.Email,
+		Role:      cmd.Role,
+		InvitedBy: &cmd.InvitedBy,
+	}, hashToken(nonce), s.cfg.Mail.InvitationTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("invitation created",
+		zap.Int64("invitation_id", id),
+		zap.Int64("invited_by", cmd.InvitedBy),
+		zap.String("role", cmd.Role),
+	)
+
+	return s.sendInvitation(ctx, id, nonce)
+}
+
+func (s *service) GetInvitations(ctx context.Context) ([]*user.Invitation, error) {
+	return s.store.getInvitations(ctx)
+}
+
+// ResendInvitation mails a pending invitation again with a new link, expired
+// or not. The link sent before stops working.
+func (s *service) ResendInvitation(ctx context.Context, id int64) (*user.Invitation, error) {
+	nonce, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	renewed, err := s.store.renewInvitation(ctx, id, hashToken(nonce), s.cfg.Mail.InvitationTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !renewed {
+		return nil, user.ErrInvitationNotFound
+	}
+
+	return s.sendInvitation(ctx, id, nonce)
+}
+
+func (s *service) RevokeInvitation(ctx context.Context, id int64) error {
+	revoked, err := s.store.revokeInvitation(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return user.ErrInvitationNotFound
+	}
+
+	s.log.Info("invitation revoked", zap.Int64("invitation_id", id))
+
+	return nil
+}
+
+// sendInvitation mails the link of the invitation id, signed with nonce as its
+// jti, and returns the invitation
+func (s *service) sendInvitation(ctx context.Context, id int64, nonce string) (*user.Invitation, error) {
+	result, err := s.store.getInvitation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, user.ErrInvitationNotFound
+	}
+
+	token, err := s.cfg.Auth.Keys.GenerateInviteToken(id, nonce, time.Now().Add(s.cfg.Mail.InvitationTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.cfg.Mailer.Send(ctx, &mailer.Message{
+		To:      result.Email,
+		Subject: "You are invited to join " + result.OrganizationName,
+		Body: fmt.Sprintf(
+			"Hello,\n\nyou have been invited to join %s as %s. Open the link below to accept the invitation:\n\n%s\n\nThe link expires in %s. If you did not expect this invitation, ignore this email.\n",
+			result.OrganizationName,
+			result.Role,
+			s.accountLink("/accept-invitation", token),
+			s.cfg.Mail.InvitationTTL,
+		),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AcceptInvitation makes the invitee a member of the organization they were
+// invited to, with the invited role. Invitees without an account sign up with
+// the password they choose here; those with one keep their password.
+func (s *service) AcceptInvitation(ctx context.Context, cmd *user.AcceptInvitationCommand) error {
+	claims, err := s.cfg.Auth.Keys.ValidateInviteToken(cmd.Token)
+	if err != nil {
+		return user.ErrInvalidInvitation
+	}
+
+	tokenHash := hashToken(claims.ID)
+
+	inv, err := s.store.getLiveInvitation(ctx, claims.InvitationID, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	if inv == nil {
+		return user.ErrInvalidInvitation
+	}
+
+	existing, err := s.store.getUserByEmail(ctx, inv.Email)
+	if err != nil {
+		return err
+	}
+
+	var userID int64
+	var newUser *user.User
+
+	if existing != nil {
+		membership, err := s.store.getMembership(ctx, existing.ID, inv.OrganizationID)
+		if err != nil {
+			return err
+		}
+
+		if membership != nil {
+			return organization.ErrAlreadyMember
+		}
+
+		userID = existing.ID
+	} else {
+		err = cmd.ValidateSignUp()
+		if err != nil {
+			return err
+		}
+
+		passwordHash, err := s.hashNewPassword(cmd.Password)
+		if err != nil {
+			return err
+		}
+
+		newUser = &user.User{
+			FirstName:    cmd.FirstName,
+			LastName:     cmd.LastName,
+			PasswordHash: passwordHash,
+			Address:      cmd.Address,
+			PhoneNumber:  cmd.PhoneNumber,
+			DateOfBirth:  &cmd.DateOfBirth,
+		}
+	}
+
+	userID, err = s.store.acceptInvitation(ctx, inv, tokenHash, userID, newUser)
+	if err != nil {
+		return err
+	}
+
+	if userID == 0 {
+		return user.ErrInvalidInvitation
+	}
+
+	s.log.Info("invitation accepted",
+		zap.Int64("invitation_id", inv.ID),
+		zap.Int64("user_id", userID),
+		zap.Int64("organization_id", inv.OrganizationID),
+		zap.Bool("signed_up", newUser != nil),
+	)
+
+	return nil
+}
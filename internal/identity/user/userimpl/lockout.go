@@ -0,0 +1,136 @@
+package userimpl
+
+import (
+	"amg/internal/identity/user"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	loginFailuresPrefix = "login:failures:"
+	loginBackoffPrefix  = "login:backoff:"
+	loginLockPrefix     = "login:locked:"
+)
+
+// loginKeys are the throttling subjects of a login attempt: the account and the
+// client, so that neither guessing one password nor spraying many accounts from
+// one address goes unchecked
+func loginKeys(email, ip string) []string {
+	keys := []string{"email:" + strings.ToLower(strings.TrimSpace(email))}
+	if len(ip) > 0 {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+// checkLoginAllowed refuses the attempt while the account is locked or one of its
+// subjects is still backing off
+func (s *service) checkLoginAllowed(ctx context.Context, email, ip string) error {
+	keys := loginKeys(email, ip)
+
+	pipe := s.redisClient.Pipeline()
+	lock := pipe.PTTL(ctx, loginLockPrefix+keys[0])
+	backoff := make([]*redis.DurationCmd, 0, len(keys))
+	for _, key := range keys {
+		backoff = append(backoff, pipe.PTTL(ctx, loginBackoffPrefix+key))
+	}
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ttl := lock.Val(); ttl > 0 {
+		return &user.LoginThrottledError{Err: user.ErrAccountLocked, RetryAfter: ttl}
+	}
+
+	var wait time.Duration
+	for _, cmd := range backoff {
+		if ttl := cmd.Val(); ttl > wait {
+			wait = ttl
+		}
+	}
+
+	if wait > 0 {
+		return &user.LoginThrottledError{Err: user.ErrTooManyLoginAttempts, RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt against the email and the IP. Each
+// subject has to wait twice as long as before its next attempt, and the account
+// is locked once the email reaches MaxLoginAttempts.
+func (s *service) recordLoginFailure(ctx context.Context, email, ip string) error {
+	keys := loginKeys(email, ip)
+	auth := s.cfg.Auth
+
+	pipe := s.redisClient.Pipeline()
+	counts := make([]*redis.IntCmd, 0, len(keys))
+	for _, key := range keys {
+		counts = append(counts, pipe.Incr(ctx, loginFailuresPrefix+key))
+		pipe.Expire(ctx, loginFailuresPrefix+key, auth.LoginFailureWindow)
+	}
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	pipe = s.redisClient.Pipeline()
+	for i, key := range keys {
+		pipe.Set(ctx, loginBackoffPrefix+key, 1, backoff(auth.LoginBackoffBase, auth.LoginBackoffMax, counts[i].Val()))
+	}
+
+	if counts[0].Val() >= int64(auth.MaxLoginAttempts) {
+		// The counter starts over once the lock expires
+		pipe.Set(ctx, loginLockPrefix+keys[0], 1, auth.LockoutDuration)
+		pipe.Del(ctx, loginFailuresPrefix+keys[0], loginBackoffPrefix+keys[0])
+
+		s.log.Warn("account locked after failed logins", zap.String("email", email), zap.String("ip", ip))
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// backoff doubles base for every failure after the first, up to max
+func backoff(base, max time.Duration, failures int64) time.Duration {
+	delay := base
+	for i := int64(1); i < failures && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// clearLoginFailures forgets the failures and any lock of an account
+func (s *service) clearLoginFailures(ctx context.Context, email string) error {
+	key := loginKeys(email, "")[0]
+
+	return s.redisClient.Del(ctx, loginFailuresPrefix+key, loginBackoffPrefix+key, loginLockPrefix+key).Err()
+}
+
+// UnlockUser lifts a lockout ahead of time and resets the failed login count
+func (s *service) UnlockUser(ctx context.Context, id int64) error {
+	result, err := s.GetByUserID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.clearLoginFailures(ctx, result.Email)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("account unlocked", zap.Int64("user_id", id))
+
+	return nil
+}
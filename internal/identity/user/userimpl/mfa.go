@@ -0,0 +1,454 @@
+package userimpl
+
+import (
+	"amg/internal/identity/organization"
+	"amg/internal/identity/user"
+	util "amg/pkg/util/password"
+	"amg/pkg/util/totp"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	mfaChallengePrefix = "mfa:challenge:"
+	mfaAttemptsPrefix  = "mfa:attempts:"
+
+	// maxMFAAttempts is how many wrong codes one challenge tolerates before the
+	// user has to start over with their password
+	maxMFAAttempts = 5
+
+	recoveryCodeCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns fresh recovery codes formatted as xxxxx-xxxxx and the
+// hashes they are stored as
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 10)
+
+		_, err := rand.Read(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a code as typed by the user, ignoring case and separators
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	return hashToken(code)
+}
+
+// EnrollTOTP creates a new authenticator secret for the user. It stays pending
+// until ConfirmTOTP proves the user has added it to their app.
+func (s *service) EnrollTOTP(ctx context.Context, userID int64) (*user.TOTPEnrollment, error) {
+	result, err := s.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := s.store.saveTOTPSecret(ctx, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !saved {
+		return nil, user.ErrMFAAlreadyEnabled
+	}
+
+	return &user.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.cfg.Auth.MFAIssuer, result.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables a pending authenticator once the user enters a valid code
+// and returns the recovery codes, which are shown only this once
+func (s *service) ConfirmTOTP(ctx context.Context, cmd *user.ConfirmTOTPCommand) ([]string, error) {
+	result, err := s.store.getTOTP(ctx, cmd.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, user.ErrMFANotEnrolled
+	}
+	if result.EnabledAt != nil {
+		return nil, user.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(result.Secret, cmd.Code, time.Now())
+	if !ok {
+		return nil, user.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.store.enableTOTP(ctx, cmd.ID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !enabled {
+		return nil, user.ErrInvalidMFACode
+	}
+
+	s.log.Info("two-factor authentication enabled", zap.Int64("user_id", cmd.ID))
+
+	return codes, nil
+}
+
+// checkTOTP verifies a code of the enabled authenticator of a user
+func (s *service) checkTOTP(ctx context.Context, userID int64, code string) error {
+	result, err := s.store.getTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if result == nil || result.EnabledAt == nil {
+		return user.ErrMFANotEnabled
+	}
+
+	step, ok := totp.Validate(result.Secret, code, time.Now())
+	if !ok {
+		return user.ErrInvalidMFACode
+	}
+
+	used, err := s.store.useTOTPStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+
+	if !used {
+		return user.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// DisableTOTP removes the authenticator and recovery codes of a user. It needs
+// both the password and a current code, and is refused while the role of the
+// user requires two-factor authentication.
+func (s *service) DisableTOTP(ctx context.Context, cmd *user.DisableTOTPCommand) error {
+	result, err := s.GetByUserID(ctx, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	err = util.CheckPasswordHash(result.PasswordHash, cmd.Password)
+	if err != nil {
+		return user.ErrInvalidPassword
+	}
+
+	required, err := s.store.isMFARequired(ctx, result.Role)
+	if err != nil {
+		return err
+	}
+
+	if required {
+		return user.ErrMFARequired
+	}
+
+	err = s.checkTOTP(ctx, cmd.ID, cmd.Code)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.deleteTOTP(ctx, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("two-factor authentication disabled", zap.Int64("user_id", cmd.ID))
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of a user with new ones
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, cmd *user.RegenerateRecoveryCodesCommand) ([]string, error) {
+	err := s.checkTOTP(ctx, cmd.ID, cmd.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.setRecoveryCodes(ctx, cmd.ID, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *service) GetMFAStatus(ctx context.Context, userID int64) (*user.MFAStatus, error) {
+	result, err := s.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &user.MFAStatus{}
+
+	status.Required, err = s.store.isMFARequired(ctx, result.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.store.getTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled = stored != nil && stored.EnabledAt != nil
+	if status.Enabled {
+		status.RecoveryCodesRemaining, err = s.store.countRecoveryCodes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+func (s *service) GetMFAPolicy(ctx context.Context) (*user.MFAPolicy, error) {
+	roles, err := s.store.getMFARequiredRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user.MFAPolicy{RequiredRoles: roles}, nil
+}
+
+// UpdateMFAPolicy sets the roles that must use two-factor authentication. Members
+// without an authenticator are asked to set one up at their next login.
+func (s *service) UpdateMFAPolicy(ctx context.Context, policy *user.MFAPolicy) error {
+	err := s.checkRoles(ctx, policy.RequiredRoles...)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.setMFARequiredRoles(ctx, policy.RequiredRoles)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("two-factor policy updated", zap.Strings("required_roles", policy.RequiredRoles))
+
+	return nil
+}
+
+// completeLogin finishes the password step of a login: users without two-factor
+// authentication get their tokens, the others an MFA challenge. The login acts
+// in the organization the user picked, with their role in it.
+func (s *service) completeLogin(ctx context.Context, u *user.User, cmd *user.LoginUserCommand) (*user.LoginResult, error) {
+	membership, err := s.membership(ctx, u.ID, cmd.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.OrganizationID = membership.OrganizationID
+	u.Role = membership.Role
+
+	stored, err := s.store.getTOTP(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored != nil && stored.EnabledAt != nil {
+		return s.newMFAChallenge(ctx, u, false, cmd)
+	}
+
+	required, err := s.store.isMFARequired(ctx, u.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if required {
+		return s.newMFAChallenge(ctx, u, true, cmd)
+	}
+
+	pair, err := s.startSession(ctx, u, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user.LoginResult{TokenPair: pair}, nil
+}
+
+// newMFAChallenge parks the login in Redis behind a random token that the second
+// step presents together with the code
+func (s *service) newMFAChallenge(ctx context.Context, u *user.User, enroll bool, cmd *user.LoginUserCommand) (*user.LoginResult, error) {
+	token, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&user.MFAChallenge{
+		UserID:         u.ID,
+		OrganizationID: cmd.OrganizationID,
+		Enroll:         enroll,
+		UserAgent:      cmd.UserAgent,
+		IPAddress:      cmd.IPAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := s.cfg.Auth.MFAChallengeTTL
+
+	err = s.redisClient.Set(ctx, mfaChallengePrefix+hashToken(token), data, ttl).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return &user.LoginResult{
+		MFARequired:           true,
+		MFAEnrollmentRequired: enroll,
+		MFAToken:              token,
+		MFATokenExpiresIn:     int64(ttl.Seconds()),
+	}, nil
+}
+
+func (s *service) getMFAChallenge(ctx context.Context, token string) (*user.MFAChallenge, error) {
+	data, err := s.redisClient.Get(ctx, mfaChallengePrefix+hashToken(token)).Bytes()
+	if err == redis.Nil {
+		return nil, user.ErrInvalidMFAToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var challenge user.MFAChallenge
+
+	err = json.Unmarshal(data, &challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+// EnrollMFALogin starts the enrollment of a user whose role requires two-factor
+// authentication, during the login that told them so
+func (s *service) EnrollMFALogin(ctx context.Context, cmd *user.EnrollMFALoginCommand) (*user.TOTPEnrollment, error) {
+	challenge, err := s.getMFAChallenge(ctx, cmd.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !challenge.Enroll {
+		return nil, user.ErrMFAAlreadyEnabled
+	}
+
+	return s.EnrollTOTP(ctx, challenge.UserID)
+}
+
+// LoginMFA completes a login with a TOTP code or a recovery code. During an
+// enrollment the code confirms the new authenticator, and the recovery codes are
+// returned along with the tokens.
+func (s *service) LoginMFA(ctx context.Context, cmd *user.LoginMFACommand) (*user.LoginResult, error) {
+	challenge, err := s.getMFAChallenge(ctx, cmd.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	key := hashToken(cmd.MFAToken)
+
+	attempts, err := s.redisClient.Incr(ctx, mfaAttemptsPrefix+key).Result()
+	if err != nil {
+		return nil, err
+	}
+	s.redisClient.Expire(ctx, mfaAttemptsPrefix+key, s.cfg.Auth.MFAChallengeTTL)
+
+	if attempts > maxMFAAttempts {
+		s.redisClient.Del(ctx, mfaChallengePrefix+key)
+		return nil, user.ErrInvalidMFAToken
+	}
+
+	result := &user.LoginResult{}
+
+	switch {
+	case challenge.Enroll:
+		result.RecoveryCodes, err = s.ConfirmTOTP(ctx, &user.ConfirmTOTPCommand{ID: challenge.UserID, Code: cmd.Code})
+	case len(cmd.RecoveryCode) > 0:
+		err = s.useRecoveryCode(ctx, challenge.UserID, cmd.RecoveryCode)
+	default:
+		err = s.checkTOTP(ctx, challenge.UserID, cmd.Code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Whoever deletes the challenge owns the login, so a token cannot complete two
+	deleted, err := s.redisClient.Del(ctx, mfaChallengePrefix+key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if deleted == 0 {
+		return nil, user.ErrInvalidMFAToken
+	}
+	s.redisClient.Del(ctx, mfaAttemptsPrefix+key)
+
+	u, err := s.GetByUserID(organization.WithID(ctx, challenge.OrganizationID), challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	result.TokenPair, err = s.startSession(ctx, u, &user.LoginUserCommand{
+		OrganizationID: challenge.OrganizationID,
+		UserAgent:      challenge.UserAgent,
+		IPAddress:      challenge.IPAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *service) useRecoveryCode(ctx context.Context, userID int64, code string) error {
+	used, err := s.store.useRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+
+	if !used {
+		return user.ErrInvalidMFACode
+	}
+
+	s.log.Info("recovery code used", zap.Int64("user_id", userID))
+
+	return nil
+}
@@ -0,0 +1,307 @@
+package userimpl
+
+import (
+	"amg/internal/identity/user"
+	"amg/pkg/util/oidc"
+	util "amg/pkg/util/password"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const oidcStatePrefix = "oidc:state:"
+
+// OIDCAuthorize starts a single sign-on login. State, nonce and the PKCE verifier
+// stay in Redis until the callback comes back.
+func (s *service) OIDCAuthorize(ctx context.Context) (*user.OIDCAuthorization, error) {
+	provider := s.cfg.OIDC.Provider
+	if provider == nil {
+		return nil, user.ErrOIDCDisabled
+	}
+
+	state, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := user.OIDCState{}
+
+	pending.Nonce, err = oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	pending.Verifier, err = oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, pending.Nonce, oidc.Challenge(pending.Verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&pending)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.redisClient.Set(ctx, oidcStatePrefix+state, data, s.cfg.OIDC.StateTTL).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return &user.OIDCAuthorization{
+		AuthorizationURL: authURL,
+		State:            state,
+		ExpiresIn:        int64(s.cfg.OIDC.StateTTL.Seconds()),
+	}, nil
+}
+
+// OIDCLogin completes a single sign-on login with the code from the callback.
+// Like a password login, it may end in an MFA challenge.
+func (s *service) OIDCLogin(ctx context.Context, cmd *user.OIDCLoginCommand) (*user.LoginResult, error) {
+	provider := s.cfg.OIDC.Provider
+	if provider == nil {
+		return nil, user.ErrOIDCDisabled
+	}
+
+	// A state can only be redeemed once
+	data, err := s.redisClient.GetDel(ctx, oidcStatePrefix+cmd.State).Bytes()
+	if err == redis.Nil {
+		return nil, user.ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pending user.OIDCState
+
+	err = json.Unmarshal(data, &pending)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := provider.Exchange(ctx, cmd.Code, pending.Verifier)
+	if err != nil {
+		s.log.Warn("oidc code exchange failed", zap.Error(err))
+		return nil, user.ErrInvalidOIDCCode
+	}
+
+	idToken, err := provider.VerifyIDToken(ctx, token.IDToken, pending.Nonce)
+	if err != nil {
+		s.log.Warn("oidc id token rejected", zap.Error(err))
+		return nil, user.ErrInvalidOIDCCode
+	}
+
+	result, orgID, err := s.externalUser(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, result, &user.LoginUserCommand{
+		OrganizationID: orgID,
+		UserAgent:      cmd.UserAgent,
+		IPAddress:      cmd.IPAddress,
+	})
+}
+
+// externalUser finds the user an ID token belongs to and the organization the
+// login goes to. Known identities map to their user; otherwise the verified email
+// links an existing account or, when allowed, provisions a new one. The role in
+// the organization of the login follows the mapping on every login.
+func (s *service) externalUser(ctx context.Context, idToken *oidc.IDToken) (*user.User, int64, error) {
+	identity, err := s.store.getIdentity(ctx, idToken.Issuer, idToken.Subject)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var result *user.User
+
+	if identity != nil {
+		result, err = s.GetByUserID(ctx, identity.UserID)
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		if !idToken.EmailVerified || len(idToken.Email) == 0 {
+			return nil, 0, user.ErrOIDCEmailNotVerified
+		}
+
+		result, err = s.store.getUserByEmail(ctx, idToken.Email)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if result == nil {
+			result, err = s.provisionUser(ctx, idToken)
+			if err != nil {
+				return nil, 0, err
+			}
+		} else {
+			s.log.Info("linked external identity", zap.Int64("user_id", result.ID), zap.String("issuer", idToken.Issuer))
+		}
+	}
+
+	// The same organization completeLogin picks without one being asked for
+	membership, err := s.membership(ctx, result.ID, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	role, ok, err := s.mapOIDCRole(ctx, idToken.Claims)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if ok && role != membership.Role {
+		err = s.store.setRole(ctx, result.ID, membership.OrganizationID, role)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		s.log.Info("role updated from identity provider",
+			zap.Int64("user_id", result.ID),
+			zap.Int64("organization_id", membership.OrganizationID),
+			zap.String("role", role),
+		)
+	}
+
+	if idToken.EmailVerified && idToken.Email == result.Email && result.EmailVerifiedAt == nil {
+		err = s.store.markEmailVerified(ctx, result.ID)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	err = s.store.linkIdentity(ctx, &user.Identity{
+		UserID:  result.ID,
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   idToken.Email,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result, membership.OrganizationID, nil
+}
+
+// provisionUser creates the account of a first-time single sign-on user. It gets
+// a random password nobody knows; a local password can be set through the reset
+// flow if ever needed.
+func (s *service) provisionUser(ctx context.Context, idToken *oidc.IDToken) (*user.User, error) {
+	if !s.cfg.OIDC.AutoProvision {
+		return nil, user.ErrOIDCAccountNotAllowed
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := util.HashPassword(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := idToken.GivenName, idToken.FamilyName
+	if len(firstName) == 0 && len(lastName) == 0 {
+		firstName, lastName, _ = strings.Cut(idToken.Name, " ")
+	}
+	if len(firstName) == 0 {
+		firstName, _, _ = strings.Cut(idToken.Email, "@")
+	}
+
+	role, ok, err := s.mapOIDCRole(ctx, idToken.Claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		role = s.cfg.OIDC.DefaultRole
+	}
+
+	result := &user.User{
+		FirstName:    firstName,
+		LastName:     lastName,
+		Email:        idToken.Email,
+		PasswordHash: passwordHash,
+		Role:         role,
+	}
+
+	orgID, err := s.registrationOrganization(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result.ID, err = s.store.createExternalUser(ctx, result, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("provisioned user from identity provider", zap.Int64("user_id", result.ID), zap.String("role", role))
+
+	return s.GetByUserID(ctx, result.ID)
+}
+
+// mapOIDCRole returns the role of the first mapping whose value appears in the
+// role claim
+func (s *service) mapOIDCRole(ctx context.Context, claims map[string]interface{}) (string, bool, error) {
+	if len(s.cfg.OIDC.RoleClaim) == 0 {
+		return "", false, nil
+	}
+
+	values := claimValues(claims, s.cfg.OIDC.RoleClaim)
+
+	for _, mapping := range s.cfg.OIDC.RoleMappings {
+		if !values[mapping.Value] {
+			continue
+		}
+
+		exists, err := s.access.RoleExists(ctx, mapping.Role)
+		if err != nil {
+			return "", false, err
+		}
+
+		if !exists {
+			s.log.Warn("ignoring oidc role mapping to unknown role", zap.String("role", mapping.Role))
+			continue
+		}
+
+		return mapping.Role, true, nil
+	}
+
+	return "", false, nil
+}
+
+// claimValues collects the strings of a claim that is a string or a list of
+// strings. path may be dotted to reach into nested objects.
+func claimValues(claims map[string]interface{}, path string) map[string]bool {
+	values := make(map[string]bool)
+
+	var claim interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := claim.(map[string]interface{})
+		if !ok {
+			return values
+		}
+		claim = object[name]
+	}
+
+	switch v := claim.(type) {
+	case string:
+		values[v] = true
+	case []interface{}:
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values[str] = true
+			}
+		}
+	}
+
+	return values
+}
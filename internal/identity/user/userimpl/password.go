@@ -0,0 +1,79 @@
+package userimpl
+
+import (
+	"amg/internal/identity/user"
+	util "amg/pkg/util/password"
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// hashNewPassword checks a new password against the policy and hashes it
+func (s *service) hashNewPassword(password string) (string, error) {
+	violations := s.cfg.PasswordPolicy.Validate(password)
+	if violations != nil {
+		return "", &user.PasswordPolicyError{Violations: violations}
+	}
+
+	return util.HashPassword(password)
+}
+
+// checkPasswordReuse refuses a password matching the current one of the user or
+// any of the previous ones still covered by the history depth
+func (s *service) checkPasswordReuse(ctx context.Context, current *user.User, password string) error {
+	depth := s.cfg.PasswordPolicy.HistoryDepth
+	if depth <= 0 {
+		return nil
+	}
+
+	if util.CheckPasswordHash(current.PasswordHash, password) == nil {
+		return user.ErrPasswordReused
+	}
+
+	hashes, err := s.store.getPasswordHistory(ctx, current.ID, depth-1)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if util.CheckPasswordHash(hash, password) == nil {
+			return user.ErrPasswordReused
+		}
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of a user who knows the current one and
+// signs out every other session
+func (s *service) ChangePassword(ctx context.Context, cmd *user.ChangePasswordCommand) error {
+	result, err := s.GetByUserID(ctx, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	err = util.CheckPasswordHash(result.PasswordHash, cmd.CurrentPassword)
+	if err != nil {
+		return user.ErrInvalidCurrentPassword
+	}
+
+	passwordHash, err := s.hashNewPassword(cmd.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkPasswordReuse(ctx, result, cmd.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.changePassword(ctx, cmd.ID, passwordHash, s.cfg.PasswordPolicy.HistoryDepth)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("password changed", zap.Int64("user_id", cmd.ID))
+
+	_, err = s.revokeSessions(ctx, cmd.ID, "", cmd.SessionID)
+	return err
+}
@@ -0,0 +1,184 @@
+package userimpl
+
+import (
+	"amg/internal/identity/organization"
+	"amg/internal/identity/user"
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	revokedSessionPrefix = "session:revoked:"
+	seenSessionPrefix    = "session:seen:"
+
+	// lastSeenResolution limits last_seen_at writes to one per session per minute
+	lastSeenResolution = time.Minute
+
+	maxUserAgentLength = 512
+)
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// GetSessions lists the active sessions of a user, flagging currentID as current
+func (s *service) GetSessions(ctx context.Context, userID int64, currentID string) ([]*user.Session, error) {
+	result, err := s.store.getSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range result {
+		session.Current = session.ID == currentID
+	}
+
+	return result, nil
+}
+
+// RevokeSession ends one session of the user, e.g. on logout
+func (s *service) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	if len(sessionID) == 0 {
+		return user.ErrInvalidSessionID
+	}
+
+	ids, err := s.revokeSessions(ctx, userID, sessionID, "")
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return user.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeAllSessions logs the user out everywhere in the organization ctx acts in
+func (s *service) RevokeAllSessions(ctx context.Context, userID int64) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	ids, err := s.store.revokeSessions(ctx, userID, orgID, "", "")
+	if err != nil {
+		return err
+	}
+
+	err = s.markRevoked(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("all sessions revoked", zap.Int64("user_id", userID), zap.Int64("organization_id", orgID))
+
+	return nil
+}
+
+// revokeSessions revokes sessions of the user in all organizations, see
+// store.revokeSessions
+func (s *service) revokeSessions(ctx context.Context, userID int64, sessionID, exceptID string) ([]string, error) {
+	ids, err := s.store.revokeSessions(ctx, userID, 0, sessionID, exceptID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, s.markRevoked(ctx, ids)
+}
+
+// SwitchOrganization replaces the current session of the user with one in
+// another organization they are a member of
+func (s *service) SwitchOrganization(ctx context.Context, cmd *user.SwitchOrganizationCommand) (*user.TokenPair, error) {
+	membership, err := s.membership(ctx, cmd.UserID, cmd.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.GetByUserID(organization.WithID(ctx, membership.OrganizationID), cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := s.startSession(ctx, u, &user.LoginUserCommand{
+		OrganizationID: membership.OrganizationID,
+		UserAgent:      cmd.UserAgent,
+		IPAddress:      cmd.IPAddress,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.revokeSessions(ctx, cmd.UserID, cmd.SessionID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("switched organization",
+		zap.Int64("user_id", cmd.UserID),
+		zap.Int64("organization_id", membership.OrganizationID),
+	)
+
+	return pair, nil
+}
+
+// membership returns the membership of a user in orgID, or in their first
+// organization when orgID is zero
+func (s *service) membership(ctx context.Context, userID, orgID int64) (*organization.Membership, error) {
+	result, err := s.store.getMembership(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, organization.ErrNotMember
+	}
+
+	return result, nil
+}
+
+// markRevoked flags the sessions in Redis so that their access tokens are refused
+// on the next request. Their refresh tokens are already revoked in the database,
+// so the flag only has to outlive the access tokens.
+func (s *service) markRevoked(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	pipe := s.redisClient.Pipeline()
+	for _, id := range ids {
+		pipe.Set(ctx, revokedSessionPrefix+id, "revoked", s.cfg.Auth.AccessTokenTTL)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IsSessionRevoked checks the revocation flag of a session in Redis
+func (s *service) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	_, err := s.redisClient.Get(ctx, revokedSessionPrefix+sessionID).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// TouchSession records that the session was just used. Writes are throttled
+// through Redis so that busy clients do not update the row on every request.
+func (s *service) TouchSession(ctx context.Context, sessionID string) error {
+	first, err := s.redisClient.SetNX(ctx, seenSessionPrefix+sessionID, 1, lastSeenResolution).Result()
+	if err != nil || !first {
+		return err
+	}
+
+	return s.store.touchSession(ctx, sessionID)
+}
@@ -2,6 +2,7 @@ package userimpl
 
 import (
 	"amg/internal/db"
+	"amg/internal/identity/organization"
 	"amg/internal/identity/user"
 	"bytes"
 	"context"
@@ -9,7 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -25,45 +28,6 @@ func NewStore(db db.DB) *store {
 	}
 }
 
-func (s *store) create(ctx context.Context, cmd *user.CreateUserCommand) error {
-	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
-		rawSQL := `
-		INSERT INTO users (
-			first_name,
-			last_name,
-			email,
-			password_hash,
-			address,
-			phone_number,
-			date_of_birth,
-			role
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8 
-		) RETURNING id
-	`
-
-		var id int64
-
-		err := tx.QueryRow(
-			ctx,
-			rawSQL,
-			cmd.FirstName,
-			cmd.LastName,
-			cmd.Email,
-			cmd.Password,
-			&cmd.Address,
-			&cmd.PhoneNumber,
-			&cmd.DateOfBirth,
-			cmd.Role,
-		).Scan(&id)
-		if err != nil {
-			return err
-		}
-
-		return nil
-	})
-}
-
 func (s *store) userTaken(ctx context.Context, id int64, email string) ([]*user.User, error) {
 	var result []*user.User
 
@@ -93,41 +57,121 @@ func (s *store) userTaken(ctx context.Context, id int64, email string) ([]*user.
 	return result, nil
 }
 
+// getUserByID reads a user with their role and team in the organization ctx acts
+// in, and finds nobody outside of it. Without an organization, as while logging
+// in, the user is read with the role they were created with and no team.
 func (s *store) getUserByID(ctx context.Context, id int64) (*user.User, error) {
 	var result user.User
 
+	orgID, _ := organization.FromContext(ctx)
+
 	rawSQL := `
 	SELECT
-		id,
-		first_name,
-		last_name,
-		email,
-		password_hash,
-		address,
-		phone_number,
-		date_of_birth,
-		role,
-		created_at,
-		updated_at
+		u.id,
+		u.first_name,
+		u.last_name,
+		u.email,
+		u.password_hash,
+		u.address,
+		u.phone_number,
+		u.date_of_birth,
+		COALESCE(m.role, u.role) AS role,
+		COALESCE(m.team, '') AS team,
+		u.created_at,
+		u.updated_at,
+		u.email_verified_at,
+		u.service_account
 	FROM
-		users
+		users u
+		LEFT JOIN organization_members m ON m.user_id = u.id AND m.organization_id = $2
 	WHERE
-		id = $1
+		u.id = $1 AND
+		($2 = 0 OR m.user_id IS NOT NULL)
 	`
 
-	err := s.db.Get(ctx, &result, rawSQL, id)
+	err := s.db.Get(ctx, &result, rawSQL, id, orgID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		} else {
-			return nil, nil
+			return nil, err
 		}
 	}
 
 	return &result, nil
 }
 
+// update saves a member of the organization ctx acts in. The role and team are
+// theirs in that organization.
 func (s *store) update(ctx context.Context, cmd *user.UpdateUserCommand) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			organization_members
+		SET
+			role = $1,
+			team = $2
+		WHERE
+			organization_id = $3 AND
+			user_id = $4
+		`
+
+		res, err := tx.Exec(ctx, rawSQL, cmd.Role, cmd.Team, orgID, cmd.ID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return user.ErrUserNotFound
+		}
+
+		rawSQL = `
+		UPDATE
+			users
+		SET
+			first_name = $1,
+			last_name = $2,
+			email = $3,
+			address = $4,
+			phone_number = $5,
+			date_of_birth = $6,
+			email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE
+			id = $7
+		`
+
+		_, err = tx.Exec(
+			ctx,
+			rawSQL,
+			cmd.FirstName,
+			cmd.LastName,
+			cmd.Email,
+			cmd.Address,
+			cmd.PhoneNumber,
+			cmd.DateOfBirth,
+			cmd.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// updateProfile updates the fields users may change themselves; role is left alone
+func (s *store) updateProfile(ctx context.Context, cmd *user.UpdateProfileCommand) error {
 	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
 		rawSQL := `
 		UPDATE
@@ -139,9 +183,10 @@ func (s *store) update(ctx context.Context, cmd *user.UpdateUserCommand) error {
 			address = $4,
 			phone_number = $5,
 			date_of_birth = $6,
-			role = $7
+			email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
+			updated_at = CURRENT_TIMESTAMP
 		WHERE
-			id = $8
+			id = $7
 		`
 
 		_, err := tx.Exec(
@@ -153,7 +198,6 @@ func (s *store) update(ctx context.Context, cmd *user.UpdateUserCommand) error {
 			cmd.Address,
 			cmd.PhoneNumber,
 			cmd.DateOfBirth,
-			cmd.Role,
 			cmd.ID,
 		)
 		if err != nil {
@@ -164,75 +208,96 @@ func (s *store) update(ctx context.Context, cmd *user.UpdateUserCommand) error {
 	})
 }
 
+// search only finds members of the organization ctx acts in
 func (s *store) search(ctx context.Context, query *user.SearchUserQuery) (*user.SearchUserResult, error) {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
 		result = user.SearchUserResult{
 			Users: make([]*user.User, 0),
 		}
 		sql            bytes.Buffer
 		whereCondition = make([]string, 0)
-		whereParams    = make([]interface{}, 0)
-		paramIndex     = 1
+		whereParams    = []interface{}{orgID}
+		paramIndex     = 2
 	)
 
 	sql.WriteString(`
 	SELECT
-		id,
-		first_name,
-		last_name,
-		email,
-		address,
-		phone_number,
-		date_of_birth,
-		role,
-		created_at,
-		updated_at
+		u.id,
+		u.first_name,
+		u.last_name,
+		u.email,
+		u.address,
+		u.phone_number,
+		u.date_of_birth,
+		m.role,
+		m.team,
+		u.created_at,
+		u.updated_at,
+		u.email_verified_at
 	FROM
-		users
+		users u
+		JOIN organization_members m ON m.user_id = u.id AND m.organization_id = $1
 	
 	`)
 
 	if len(query.FirstName) > 0 {
-		whereCondition = append(whereCondition, fmt.Sprintf("first_name ILIKE $%d", paramIndex))
+		whereCondition = append(whereCondition, fmt.Sprintf("u.first_name ILIKE $%d", paramIndex))
 		whereParams = append(whereParams, "%"+query.FirstName+"%")
 		paramIndex++
 	}
 
 	if len(query.LastName) > 0 {
-		whereCondition = append(whereCondition, fmt.Sprintf("last_name ILIKE $%d", paramIndex))
+		whereCondition = append(whereCondition, fmt.Sprintf("u.last_name ILIKE $%d", paramIndex))
 		whereParams = append(whereParams, "%"+query.LastName+"%")
 		paramIndex++
 	}
 
 	if len(query.Email) > 0 {
-		whereCondition = append(whereCondition, fmt.Sprintf("email ILIKE $%d", paramIndex))
+		whereCondition = append(whereCondition, fmt.Sprintf("u.email ILIKE $%d", paramIndex))
 		whereParams = append(whereParams, "%"+query.Email+"%")
 		paramIndex++
 	}
 
 	if len(query.Address) > 0 {
-		whereCondition = append(whereCondition, fmt.Sprintf("address ILIKE $%d", paramIndex))
+		whereCondition = append(whereCondition, fmt.Sprintf("u.address ILIKE $%d", paramIndex))
 		whereParams = append(whereParams, "%"+query.Address+"%")
 		paramIndex++
 	}
 
 	if len(query.PhoneNumber) > 0 {
-		whereCondition = append(whereCondition, fmt.Sprintf("phone_number ILIKE $%d", paramIndex))
+		whereCondition = append(whereCondition, fmt.Sprintf("u.phone_number ILIKE $%d", paramIndex))
 		whereParams = append(whereParams, "%"+query.PhoneNumber+"%")
 		paramIndex++
 	}
 
 	if len(query.DateOfBirth) > 0 {
-		whereCondition = append(whereCondition, fmt.Sprintf("date_of_birth = $%d", paramIndex))
+		whereCondition = append(whereCondition, fmt.Sprintf("u.date_of_birth = $%d", paramIndex))
 		whereParams = append(whereParams, query.DateOfBirth)
 		paramIndex++
 	}
 
+	if len(query.Team) > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("m.team = $%d", paramIndex))
+		whereParams = append(whereParams, query.Team)
+		paramIndex++
+	}
+
+	if query.ID > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("u.id = $%d", paramIndex))
+		whereParams = append(whereParams, query.ID)
+		paramIndex++
+	}
+
 	if len(whereCondition) > 0 {
 		sql.WriteString(" WHERE " + strings.Join(whereCondition, " AND "))
 	}
 
-	sql.WriteString(" ORDER BY created_at DESC")
+	sql.WriteString(" ORDER BY u.created_at DESC")
 
 	if query.PerPage > 0 {
 		offset := query.PerPage * (query.Page - 1)
@@ -269,17 +334,39 @@ func (s *store) getCount(ctx context.Context, sql bytes.Buffer, whereParams []in
 	return count, nil
 }
 
+// delete removes the user from the organization ctx acts in. The account itself
+// is deleted once it is no member of any organization.
 func (s *store) delete(ctx context.Context, id int64) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
 	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
 		rawSQL := `
+			DELETE
+			FROM
+				organization_members
+			WHERE
+				organization_id = $1 AND
+				user_id = $2
+		`
+
+		_, err := tx.Exec(ctx, rawSQL, orgID, id)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
 			DELETE 
 			FROM
 				users
 			WHERE
-				id = $1		
+				id = $1 AND
+				NOT EXISTS (SELECT 1 FROM organization_members WHERE user_id = $1)
 		`
 
-		_, err := tx.Exec(ctx, rawSQL, id)
+		_, err = tx.Exec(ctx, rawSQL, id)
 		if err != nil {
 			return err
 		}
@@ -288,7 +375,7 @@ func (s *store) delete(ctx context.Context, id int64) error {
 	})
 }
 
-func (s *store) registerDefaultUser(ctx context.Context, cmd *user.RegisterUserCommand, role string) error {
+func (s *store) registerDefaultUser(ctx context.Context, cmd *user.RegisterUserCommand, role string, orgID int64) error {
 	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
 		rawSQL := `
 			INSERT INTO users (
@@ -322,7 +409,7 @@ func (s *store) registerDefaultUser(ctx context.Context, cmd *user.RegisterUserC
 			return err
 		}
 
-		return nil
+		return addMember(ctx, tx, orgID, id, role)
 	})
 }
 
@@ -339,7 +426,9 @@ func (s *store) getUserByEmail(ctx context.Context, email string) (*user.User, e
 			address,
 			phone_number,
 			date_of_birth,
-			role
+			role,
+			email_verified_at,
+			service_account
 		FROM
 			users
 		WHERE
@@ -357,3 +446,1694 @@ func (s *store) getUserByEmail(ctx context.Context, email string) (*user.User, e
 
 	return &user, nil
 }
+
+// createSession stores a new login together with its first refresh token
+func (s *store) createSession(ctx context.Context, session *user.Session, token *user.RefreshToken, ttl time.Duration) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		INSERT INTO sessions (
+			id,
+			user_id,
+			organization_id,
+			user_agent,
+			ip_address,
+			expires_at
+		) VALUES (
+			$1, $2, $3, $4, $5, CURRENT_TIMESTAMP + $6 * INTERVAL '1 second'
+		)
+		`
+
+		_, err := tx.Exec(
+			ctx,
+			rawSQL,
+			session.ID,
+			session.UserID,
+			session.OrganizationID,
+			session.UserAgent,
+			session.IPAddress,
+			int64(ttl.Seconds()),
+		)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		INSERT INTO refresh_tokens (
+			user_id,
+			family_id,
+			token_hash,
+			expires_at
+		) VALUES (
+			$1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second'
+		) RETURNING id
+		`
+
+		err = tx.QueryRow(
+			ctx,
+			rawSQL,
+			token.UserID,
+			token.FamilyID,
+			token.TokenHash,
+			int64(ttl.Seconds()),
+		).Scan(&token.ID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) getRefreshToken(ctx context.Context, tokenHash string) (*user.RefreshToken, error) {
+	var result user.RefreshToken
+
+	rawSQL := `
+	SELECT
+		id,
+		user_id,
+		family_id,
+		token_hash,
+		expires_at <= CURRENT_TIMESTAMP AS expired,
+		revoked_at,
+		replaced_by,
+		created_at
+	FROM
+		refresh_tokens
+	WHERE
+		token_hash = $1
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// rotateRefreshToken stores the successor of a refresh token and revokes the old
+// one. The revocation is guarded on the token still being live, so of two
+// concurrent exchanges of the same token only one succeeds; the other gets
+// ErrRefreshTokenReused.
+func (s *store) rotateRefreshToken(ctx context.Context, old, next *user.RefreshToken, ttl time.Duration) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		INSERT INTO refresh_tokens (
+			user_id,
+			family_id,
+			token_hash,
+			expires_at
+		) VALUES (
+			$1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second'
+		) RETURNING id
+		`
+
+		err := tx.QueryRow(
+			ctx,
+			rawSQL,
+			next.UserID,
+			next.FamilyID,
+			next.TokenHash,
+			int64(ttl.Seconds()),
+		).Scan(&next.ID)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		UPDATE
+			refresh_tokens
+		SET
+			revoked_at = CURRENT_TIMESTAMP,
+			replaced_by = $1
+		WHERE
+			id = $2 AND
+			revoked_at IS NULL
+		`
+
+		res, err := tx.Exec(ctx, rawSQL, next.ID, old.ID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return user.ErrRefreshTokenReused
+		}
+
+		// Refreshing keeps the session alive for another refresh token lifetime
+		rawSQL = `
+		UPDATE
+			sessions
+		SET
+			last_seen_at = CURRENT_TIMESTAMP,
+			expires_at = CURRENT_TIMESTAMP + $1 * INTERVAL '1 second'
+		WHERE
+			id = $2
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, int64(ttl.Seconds()), next.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// getSessions lists the active sessions of a user in the organization ctx acts in
+func (s *store) getSessions(ctx context.Context, userID int64) ([]*user.Session, error) {
+	result := make([]*user.Session, 0)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		user_id,
+		organization_id,
+		user_agent,
+		ip_address,
+		created_at,
+		last_seen_at,
+		expires_at
+	FROM
+		sessions
+	WHERE
+		user_id = $1 AND
+		organization_id = $2 AND
+		revoked_at IS NULL AND
+		expires_at > CURRENT_TIMESTAMP
+	ORDER BY last_seen_at DESC
+	`
+
+	err = s.db.Select(ctx, &result, rawSQL, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *store) touchSession(ctx context.Context, id string) error {
+	rawSQL := `
+	UPDATE
+		sessions
+	SET
+		last_seen_at = CURRENT_TIMESTAMP
+	WHERE
+		id = $1
+	`
+
+	_, err := s.db.Exec(ctx, rawSQL, id)
+	return err
+}
+
+// revokeSessions revokes the active sessions of a user, or only sessionID when it
+// is not empty, together with their refresh tokens. exceptID, when not empty, is
+// left alone, and orgID, when not zero, limits it to the sessions in that
+// organization. It returns the ids of the sessions it revoked.
+func (s *store) revokeSessions(ctx context.Context, userID, orgID int64, sessionID, exceptID string) ([]string, error) {
+	ids := make([]string, 0)
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			sessions
+		SET
+			revoked_at = CURRENT_TIMESTAMP
+		WHERE
+			user_id = $1 AND
+			($2 = '' OR id = $2) AND
+			($3 = '' OR id <> $3) AND
+			($4 = 0 OR organization_id = $4) AND
+			revoked_at IS NULL
+		RETURNING id
+		`
+
+		rows, err := tx.Query(ctx, rawSQL, userID, sessionID, exceptID, orgID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+
+			err = rows.Scan(&id)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		err = rows.Err()
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		UPDATE
+			refresh_tokens
+		SET
+			revoked_at = CURRENT_TIMESTAMP
+		WHERE
+			family_id = ANY($1) AND
+			revoked_at IS NULL
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// createAccountToken stores a single-use token, invalidating the unused tokens the
+// user still has for the same purpose
+func (s *store) createAccountToken(ctx context.Context, userID int64, purpose, tokenHash string, ttl time.Duration) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			user_tokens
+		SET
+			used_at = CURRENT_TIMESTAMP
+		WHERE
+			user_id = $1 AND
+			purpose = $2 AND
+			used_at IS NULL
+		`
+
+		_, err := tx.Exec(ctx, rawSQL, userID, purpose)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		INSERT INTO user_tokens (
+			user_id,
+			purpose,
+			token_hash,
+			expires_at
+		) VALUES (
+			$1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second'
+		)
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, userID, purpose, tokenHash, int64(ttl.Seconds()))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// consumeAccountToken marks a live token as used and returns its user id, or 0 when
+// the token is unknown, used or expired. Marking and checking happen in one
+// statement so a token cannot be used twice.
+func consumeAccountToken(ctx context.Context, tx db.Tx, purpose, tokenHash string) (int64, error) {
+	var userID int64
+
+	rawSQL := `
+	UPDATE
+		user_tokens
+	SET
+		used_at = CURRENT_TIMESTAMP
+	WHERE
+		token_hash = $1 AND
+		purpose = $2 AND
+		used_at IS NULL AND
+		expires_at > CURRENT_TIMESTAMP
+	RETURNING user_id
+	`
+
+	err := tx.QueryRow(ctx, rawSQL, tokenHash, purpose).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// peekAccountToken returns the user id of a live token without consuming it, or 0
+// when the token is unknown, used or expired
+func (s *store) peekAccountToken(ctx context.Context, purpose, tokenHash string) (int64, error) {
+	var userID int64
+
+	rawSQL := `
+	SELECT
+		user_id
+	FROM
+		user_tokens
+	WHERE
+		token_hash = $1 AND
+		purpose = $2 AND
+		used_at IS NULL AND
+		expires_at > CURRENT_TIMESTAMP
+	`
+
+	err := s.db.Get(ctx, &userID, rawSQL, tokenHash, purpose)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// resetPassword consumes a reset token and sets the new password hash. Proving
+// access to the mailbox also verifies the email address.
+func (s *store) resetPassword(ctx context.Context, tokenHash, passwordHash string, historyDepth int) (int64, error) {
+	var userID int64
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		var err error
+
+		userID, err = consumeAccountToken(ctx, tx, user.TokenPurposeResetPassword, tokenHash)
+		if err != nil || userID == 0 {
+			return err
+		}
+
+		err = setPassword(ctx, tx, userID, passwordHash, historyDepth)
+		if err != nil {
+			return err
+		}
+
+		rawSQL := `
+		UPDATE
+			users
+		SET
+			email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+		WHERE
+			id = $1
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (s *store) changePassword(ctx context.Context, userID int64, passwordHash string, historyDepth int) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		return setPassword(ctx, tx, userID, passwordHash, historyDepth)
+	})
+}
+
+// setPassword replaces the password hash of a user, moving the old hash into the
+// history and keeping only the historyDepth-1 most recent entries there; with the
+// current hash that makes historyDepth passwords that cannot be reused.
+func setPassword(ctx context.Context, tx db.Tx, userID int64, passwordHash string, historyDepth int) error {
+	rawSQL := `
+	INSERT INTO password_history (
+		user_id,
+		password_hash
+	)
+	SELECT
+		id,
+		password_hash
+	FROM
+		users
+	WHERE
+		id = $1
+	`
+
+	_, err := tx.Exec(ctx, rawSQL, userID)
+	if err != nil {
+		return err
+	}
+
+	rawSQL = `
+	UPDATE
+		users
+	SET
+		password_hash = $1,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE
+		id = $2
+	`
+
+	_, err = tx.Exec(ctx, rawSQL, passwordHash, userID)
+	if err != nil {
+		return err
+	}
+
+	rawSQL = `
+	DELETE FROM
+		password_history
+	WHERE
+		user_id = $1 AND
+		id NOT IN (
+			SELECT
+				id
+			FROM
+				password_history
+			WHERE
+				user_id = $1
+			ORDER BY
+				created_at DESC, id DESC
+			LIMIT $2
+		)
+	`
+
+	keep := historyDepth - 1
+	if keep < 0 {
+		keep = 0
+	}
+
+	_, err = tx.Exec(ctx, rawSQL, userID, keep)
+	return err
+}
+
+// getPasswordHistory returns up to limit previous password hashes of a user, newest first
+func (s *store) getPasswordHistory(ctx context.Context, userID int64, limit int) ([]string, error) {
+	hashes := make([]string, 0)
+
+	if limit <= 0 {
+		return hashes, nil
+	}
+
+	rawSQL := `
+	SELECT
+		password_hash
+	FROM
+		password_history
+	WHERE
+		user_id = $1
+	ORDER BY
+		created_at DESC, id DESC
+	LIMIT $2
+	`
+
+	err := s.db.Select(ctx, &hashes, rawSQL, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// verifyEmail consumes a verification token and marks the email address verified
+func (s *store) verifyEmail(ctx context.Context, tokenHash string) (int64, error) {
+	var userID int64
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		var err error
+
+		userID, err = consumeAccountToken(ctx, tx, user.TokenPurposeVerifyEmail, tokenHash)
+		if err != nil || userID == 0 {
+			return err
+		}
+
+		rawSQL := `
+		UPDATE
+			users
+		SET
+			email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+		WHERE
+			id = $1
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (s *store) getTOTP(ctx context.Context, userID int64) (*user.TOTP, error) {
+	var result user.TOTP
+
+	rawSQL := `
+	SELECT
+		user_id,
+		secret,
+		enabled_at,
+		last_used_step
+	FROM
+		user_totp
+	WHERE
+		user_id = $1
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// saveTOTPSecret stores the secret of a pending enrollment, replacing an earlier
+// pending one. An enabled authenticator is never overwritten.
+func (s *store) saveTOTPSecret(ctx context.Context, userID int64, secret string) (bool, error) {
+	rawSQL := `
+	INSERT INTO user_totp (
+		user_id,
+		secret
+	) VALUES (
+		$1, $2
+	)
+	ON CONFLICT (user_id) DO UPDATE SET
+		secret = EXCLUDED.secret,
+		last_used_step = 0,
+		created_at = CURRENT_TIMESTAMP
+	WHERE
+		user_totp.enabled_at IS NULL
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, userID, secret)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// enableTOTP turns a pending enrollment on and stores its first recovery codes.
+// step is the step of the code that confirmed it and may not be used again.
+func (s *store) enableTOTP(ctx context.Context, userID, step int64, codeHashes []string) (bool, error) {
+	var enabled bool
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			user_totp
+		SET
+			enabled_at = CURRENT_TIMESTAMP,
+			last_used_step = $2
+		WHERE
+			user_id = $1 AND
+			enabled_at IS NULL AND
+			last_used_step < $2
+		`
+
+		result, err := tx.Exec(ctx, rawSQL, userID, step)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		enabled = true
+
+		return replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return enabled, nil
+}
+
+// useTOTPStep records step as used. It fails when a code of the same or a later
+// step was already accepted, which stops a code from being replayed.
+func (s *store) useTOTPStep(ctx context.Context, userID, step int64) (bool, error) {
+	rawSQL := `
+	UPDATE
+		user_totp
+	SET
+		last_used_step = $2
+	WHERE
+		user_id = $1 AND
+		enabled_at IS NOT NULL AND
+		last_used_step < $2
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *store) deleteTOTP(ctx context.Context, userID int64) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		err := replaceRecoveryCodes(ctx, tx, userID, nil)
+		if err != nil {
+			return err
+		}
+
+		rawSQL := `
+		DELETE FROM
+			user_totp
+		WHERE
+			user_id = $1
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, userID)
+		return err
+	})
+}
+
+func (s *store) setRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		return replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+}
+
+// replaceRecoveryCodes swaps all recovery codes of a user, used or not, for codeHashes
+func replaceRecoveryCodes(ctx context.Context, tx db.Tx, userID int64, codeHashes []string) error {
+	rawSQL := `
+	DELETE FROM
+		mfa_recovery_codes
+	WHERE
+		user_id = $1
+	`
+
+	_, err := tx.Exec(ctx, rawSQL, userID)
+	if err != nil {
+		return err
+	}
+
+	if len(codeHashes) == 0 {
+		return nil
+	}
+
+	rawSQL = `
+	INSERT INTO mfa_recovery_codes (
+		user_id,
+		code_hash
+	)
+	SELECT
+		$1, UNNEST($2::text[])
+	`
+
+	_, err = tx.Exec(ctx, rawSQL, userID, pq.Array(codeHashes))
+	return err
+}
+
+// useRecoveryCode marks an unused recovery code as used
+func (s *store) useRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	rawSQL := `
+	UPDATE
+		mfa_recovery_codes
+	SET
+		used_at = CURRENT_TIMESTAMP
+	WHERE
+		user_id = $1 AND
+		code_hash = $2 AND
+		used_at IS NULL
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (s *store) countRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+
+	rawSQL := `
+	SELECT
+		COUNT(*)
+	FROM
+		mfa_recovery_codes
+	WHERE
+		user_id = $1 AND
+		used_at IS NULL
+	`
+
+	err := s.db.Get(ctx, &count, rawSQL, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (s *store) getMFARequiredRoles(ctx context.Context) ([]string, error) {
+	roles := make([]string, 0)
+
+	rawSQL := `
+	SELECT
+		role
+	FROM
+		mfa_required_roles
+	ORDER BY role
+	`
+
+	err := s.db.Select(ctx, &roles, rawSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (s *store) isMFARequired(ctx context.Context, role string) (bool, error) {
+	var required bool
+
+	rawSQL := `
+	SELECT EXISTS (
+		SELECT
+			1
+		FROM
+			mfa_required_roles
+		WHERE
+			role = $1
+	)
+	`
+
+	err := s.db.Get(ctx, &required, rawSQL, role)
+	if err != nil {
+		return false, err
+	}
+
+	return required, nil
+}
+
+func (s *store) setMFARequiredRoles(ctx context.Context, roles []string) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		DELETE FROM
+			mfa_required_roles
+		`
+
+		_, err := tx.Exec(ctx, rawSQL)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		INSERT INTO mfa_required_roles (
+			role
+		)
+		SELECT DISTINCT
+			UNNEST($1::text[])
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, pq.Array(roles))
+		return err
+	})
+}
+
+func (s *store) getIdentity(ctx context.Context, issuer, subject string) (*user.Identity, error) {
+	var result user.Identity
+
+	rawSQL := `
+	SELECT
+		id,
+		user_id,
+		issuer,
+		subject,
+		email,
+		created_at,
+		last_login_at
+	FROM
+		user_identities
+	WHERE
+		issuer = $1 AND
+		subject = $2
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, issuer, subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// linkIdentity records a login through an external account, linking it to the
+// user the first time
+func (s *store) linkIdentity(ctx context.Context, identity *user.Identity) error {
+	rawSQL := `
+	INSERT INTO user_identities (
+		user_id,
+		issuer,
+		subject,
+		email
+	) VALUES (
+		$1, $2, $3, $4
+	)
+	ON CONFLICT (issuer, subject) DO UPDATE SET
+		email = EXCLUDED.email,
+		last_login_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := s.db.Exec(ctx, rawSQL, identity.UserID, identity.Issuer, identity.Subject, identity.Email)
+	return err
+}
+
+// createExternalUser inserts a user provisioned from an identity provider, which
+// has already verified the email address
+func (s *store) createExternalUser(ctx context.Context, u *user.User, orgID int64) (int64, error) {
+	var id int64
+
+	rawSQL := `
+	INSERT INTO users (
+		first_name,
+		last_name,
+		email,
+		password_hash,
+		address,
+		phone_number,
+		role,
+		email_verified_at
+	) VALUES (
+		$1, $2, $3, $4, '', '', $5, CURRENT_TIMESTAMP
+	) RETURNING id
+	`
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		err := tx.QueryRow(ctx, rawSQL, u.FirstName, u.LastName, u.Email, u.PasswordHash, u.Role).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		return addMember(ctx, tx, orgID, id, u.Role)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// setRole changes the role of a user, and their role in orgID if they are a
+// member of it
+func (s *store) setRole(ctx context.Context, userID, orgID int64, role string) error {
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			users
+		SET
+			role = $1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE
+			id = $2
+		`
+
+		_, err := tx.Exec(ctx, rawSQL, role, userID)
+		if err != nil {
+			return err
+		}
+
+		rawSQL = `
+		UPDATE
+			organization_members
+		SET
+			role = $1
+		WHERE
+			organization_id = $2 AND
+			user_id = $3
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, role, orgID, userID)
+		return err
+	})
+}
+
+func (s *store) markEmailVerified(ctx context.Context, userID int64) error {
+	rawSQL := `
+	UPDATE
+		users
+	SET
+		email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+	WHERE
+		id = $1
+	`
+
+	_, err := s.db.Exec(ctx, rawSQL, userID)
+	return err
+}
+
+// createAPIKey stores a key acting in the organization ctx acts in
+func (s *store) createAPIKey(ctx context.Context, key *user.APIKey, keyHash string, expiresAt *time.Time) (*user.APIKey, error) {
+	var result user.APIKey
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	INSERT INTO api_keys (
+		user_id,
+		organization_id,
+		name,
+		prefix,
+		key_hash,
+		scopes,
+		created_by,
+		expires_at
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8
+	) RETURNING
+		id,
+		user_id,
+		organization_id,
+		name,
+		prefix,
+		scopes,
+		created_by,
+		expires_at,
+		last_used_at,
+		revoked_at,
+		created_at
+	`
+
+	err = s.db.Get(ctx, &result, rawSQL, key.UserID, orgID, key.Name, key.Prefix, keyHash, key.Scopes, key.CreatedBy, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// getAPIKeys lists the keys of a user in the organization ctx acts in that have
+// not been revoked, expired ones included
+func (s *store) getAPIKeys(ctx context.Context, userID int64) ([]*user.APIKey, error) {
+	result := make([]*user.APIKey, 0)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		user_id,
+		organization_id,
+		name,
+		prefix,
+		scopes,
+		created_by,
+		expires_at,
+		last_used_at,
+		revoked_at,
+		created_at
+	FROM
+		api_keys
+	WHERE
+		user_id = $1 AND
+		organization_id = $2 AND
+		revoked_at IS NULL
+	ORDER BY created_at DESC
+	`
+
+	err = s.db.Select(ctx, &result, rawSQL, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// getLiveAPIKey looks up a key that is neither revoked nor expired, together with
+// the current role of its owner in the organization of the key. Keys of owners
+// who left that organization are not live.
+func (s *store) getLiveAPIKey(ctx context.Context, keyHash string) (*user.APIKey, error) {
+	var result user.APIKey
+
+	rawSQL := `
+	SELECT
+		k.id,
+		k.user_id,
+		k.organization_id,
+		k.name,
+		k.prefix,
+		k.scopes,
+		k.created_by,
+		k.expires_at,
+		k.last_used_at,
+		k.revoked_at,
+		k.created_at,
+		m.role AS owner_role
+	FROM
+		api_keys k
+		JOIN organization_members m ON m.user_id = k.user_id AND m.organization_id = k.organization_id
+	WHERE
+		k.key_hash = $1 AND
+		k.revoked_at IS NULL AND
+		(k.expires_at IS NULL OR k.expires_at > CURRENT_TIMESTAMP)
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, keyHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) touchAPIKey(ctx context.Context, id int64) error {
+	rawSQL := `
+	UPDATE
+		api_keys
+	SET
+		last_used_at = CURRENT_TIMESTAMP
+	WHERE
+		id = $1
+	`
+
+	_, err := s.db.Exec(ctx, rawSQL, id)
+	return err
+}
+
+func (s *store) revokeAPIKey(ctx context.Context, userID, id int64) (bool, error) {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rawSQL := `
+	UPDATE
+		api_keys
+	SET
+		revoked_at = CURRENT_TIMESTAMP
+	WHERE
+		id = $1 AND
+		user_id = $2 AND
+		organization_id = $3 AND
+		revoked_at IS NULL
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, id, userID, orgID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// createServiceAccount inserts a service account in the organization ctx acts in
+func (s *store) createServiceAccount(ctx context.Context, u *user.User) (int64, error) {
+	var id int64
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rawSQL := `
+	INSERT INTO users (
+		first_name,
+		last_name,
+		email,
+		password_hash,
+		address,
+		phone_number,
+		role,
+		service_account
+	) VALUES (
+		$1, $2, $3, $4, '', '', $5, TRUE
+	) RETURNING id
+	`
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		err := tx.QueryRow(ctx, rawSQL, u.FirstName, u.LastName, u.Email, u.PasswordHash, u.Role).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		return addMember(ctx, tx, orgID, id, u.Role)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *store) getServiceAccounts(ctx context.Context) ([]*user.User, error) {
+	result := make([]*user.User, 0)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		u.id,
+		u.first_name,
+		u.last_name,
+		u.email,
+		m.role,
+		u.created_at,
+		u.updated_at,
+		u.service_account
+	FROM
+		users u
+		JOIN organization_members m ON m.user_id = u.id AND m.organization_id = $1
+	WHERE
+		u.service_account
+	ORDER BY u.first_name
+	`
+
+	err = s.db.Select(ctx, &result, rawSQL, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// addMember makes a new user a member of orgID
+func addMember(ctx context.Context, tx db.Tx, orgID, userID int64, role string) error {
+	rawSQL := `
+	INSERT INTO organization_members (
+		organization_id,
+		user_id,
+		role
+	) VALUES (
+		$1, $2, $3
+	)
+	`
+
+	_, err := tx.Exec(ctx, rawSQL, orgID, userID, role)
+	return err
+}
+
+// getMembership returns the membership of a user in orgID, or in the
+// organization they joined first when orgID is zero
+func (s *store) getMembership(ctx context.Context, userID, orgID int64) (*organization.Membership, error) {
+	var result organization.Membership
+
+	rawSQL := `
+	SELECT
+		o.id AS organization_id,
+		o.name,
+		o.slug,
+		m.role,
+		m.created_at AS joined_at
+	FROM
+		organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+	WHERE
+		m.user_id = $1 AND
+		($2 = 0 OR m.organization_id = $2)
+	ORDER BY m.created_at, o.id
+	LIMIT 1
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, userID, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// countOtherMemberships counts the organizations of a user besides the one ctx
+// acts in
+func (s *store) countOtherMemberships(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rawSQL := `
+	SELECT
+		COUNT(*)
+	FROM
+		organization_members
+	WHERE
+		user_id = $1 AND
+		organization_id <> $2
+	`
+
+	err = s.db.Get(ctx, &count, rawSQL, userID, orgID)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (s *store) getOrganizationIDBySlug(ctx context.Context, slug string) (int64, error) {
+	var id int64
+
+	rawSQL := `
+	SELECT
+		id
+	FROM
+		organizations
+	WHERE
+		slug = $1
+	`
+
+	err := s.db.Get(ctx, &id, rawSQL, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// getSessionOrganization returns the organization a session acts in
+func (s *store) getSessionOrganization(ctx context.Context, sessionID string) (int64, error) {
+	var id int64
+
+	rawSQL := `
+	SELECT
+		organization_id
+	FROM
+		sessions
+	WHERE
+		id = $1
+	`
+
+	err := s.db.Get(ctx, &id, rawSQL, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// createInvitation stores a pending invitation to the organization ctx acts in,
+// unless the email belongs to a member or already has a pending invitation there
+func (s *store) createInvitation(ctx context.Context, inv *user.Invitation, tokenHash string, ttl time.Duration) (int64, error) {
+	var id int64
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		var members, pending int64
+
+		rawSQL := `
+		SELECT
+			(SELECT COUNT(*) FROM organization_members m JOIN users u ON u.id = m.user_id
+				WHERE m.organization_id = $1 AND u.email = $2),
+			(SELECT COUNT(*) FROM invitations
+				WHERE organization_id = $1 AND email = $2 AND accepted_at IS NULL AND revoked_at IS NULL)
+		`
+
+		err := tx.QueryRow(ctx, rawSQL, orgID, inv.Email).Scan(&members, &pending)
+		if err != nil {
+			return err
+		}
+
+		if members > 0 {
+			return organization.ErrAlreadyMember
+		}
+		if pending > 0 {
+			return user.ErrInvitationPending
+		}
+
+		rawSQL = `
+		INSERT INTO invitations (
+			organization_id,
+			email,
+			role,
+			token_hash,
+			invited_by,
+			expires_at
+		) VALUES (
+			$1, $2, $3, $4, $5, CURRENT_TIMESTAMP + $6 * INTERVAL '1 second'
+		) RETURNING id
+		`
+
+		return tx.QueryRow(ctx, rawSQL, orgID, inv.Email, inv.Role, tokenHash, inv.InvitedBy, int64(ttl.Seconds())).Scan(&id)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// getInvitation reads a pending invitation of the organization ctx acts in, or
+// returns nil
+func (s *store) getInvitation(ctx context.Context, id int64) (*user.Invitation, error) {
+	var result user.Invitation
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		i.id,
+		i.organization_id,
+		i.email,
+		i.role,
+		i.invited_by,
+		i.expires_at,
+		i.expires_at <= CURRENT_TIMESTAMP AS expired,
+		i.sent_at,
+		i.accepted_at,
+		i.revoked_at,
+		i.created_at,
+		o.name AS organization_name
+	FROM
+		invitations i
+		JOIN organizations o ON o.id = i.organization_id
+	WHERE
+		i.id = $1 AND
+		i.organization_id = $2 AND
+		i.accepted_at IS NULL AND
+		i.revoked_at IS NULL
+	`
+
+	err = s.db.Get(ctx, &result, rawSQL, id, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// getInvitations lists the pending invitations of the organization ctx acts in,
+// expired ones included
+func (s *store) getInvitations(ctx context.Context) ([]*user.Invitation, error) {
+	result := make([]*user.Invitation, 0)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		i.id,
+		i.organization_id,
+		i.email,
+		i.role,
+		i.invited_by,
+		i.expires_at,
+		i.expires_at <= CURRENT_TIMESTAMP AS expired,
+		i.sent_at,
+		i.accepted_at,
+		i.revoked_at,
+		i.created_at,
+		o.name AS organization_name
+	FROM
+		invitations i
+		JOIN organizations o ON o.id = i.organization_id
+	WHERE
+		i.organization_id = $1 AND
+		i.accepted_at IS NULL AND
+		i.revoked_at IS NULL
+	ORDER BY i.created_at DESC
+	`
+
+	err = s.db.Select(ctx, &result, rawSQL, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// renewInvitation gives a pending invitation of the organization ctx acts in a
+// new token and expiry, which invalidates the link sent before
+func (s *store) renewInvitation(ctx context.Context, id int64, tokenHash string, ttl time.Duration) (bool, error) {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rawSQL := `
+	UPDATE
+		invitations
+	SET
+		token_hash = $1,
+		expires_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second',
+		sent_at = CURRENT_TIMESTAMP
+	WHERE
+		id = $3 AND
+		organization_id = $4 AND
+		accepted_at IS NULL AND
+		revoked_at IS NULL
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, tokenHash, int64(ttl.Seconds()), id, orgID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// revokeInvitation revokes a pending invitation of the organization ctx acts in
+func (s *store) revokeInvitation(ctx context.Context, id int64) (bool, error) {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rawSQL := `
+	UPDATE
+		invitations
+	SET
+		revoked_at = CURRENT_TIMESTAMP
+	WHERE
+		id = $1 AND
+		organization_id = $2 AND
+		accepted_at IS NULL AND
+		revoked_at IS NULL
+	`
+
+	result, err := s.db.Exec(ctx, rawSQL, id, orgID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// getLiveInvitation looks up an invitation that is neither accepted, revoked nor
+// expired and was last sent with the token hashed to tokenHash. Invitees have no
+// organization yet, so it is not scoped to one.
+func (s *store) getLiveInvitation(ctx context.Context, id int64, tokenHash string) (*user.Invitation, error) {
+	var result user.Invitation
+
+	rawSQL := `
+	SELECT
+		i.id,
+		i.organization_id,
+		i.email,
+		i.role,
+		i.invited_by,
+		i.expires_at,
+		FALSE AS expired,
+		i.sent_at,
+		i.accepted_at,
+		i.revoked_at,
+		i.created_at,
+		o.name AS organization_name
+	FROM
+		invitations i
+		JOIN organizations o ON o.id = i.organization_id
+	WHERE
+		i.id = $1 AND
+		i.token_hash = $2 AND
+		i.accepted_at IS NULL AND
+		i.revoked_at IS NULL AND
+		i.expires_at > CURRENT_TIMESTAMP
+	`
+
+	err := s.db.Get(ctx, &result, rawSQL, id, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// acceptInvitation marks a live invitation accepted and makes its invitee a
+// member of the organization with the invited role. The invitee is the user
+// userID, or newUser when they have no account yet. Either way their email is
+// verified, since the invitation was mailed to it. It returns the id of the
+// invitee, or 0 when the invitation is no longer live.
+func (s *store) acceptInvitation(ctx context.Context, inv *user.Invitation, tokenHash string, userID int64, newUser *user.User) (int64, error) {
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			invitations
+		SET
+			accepted_at = CURRENT_TIMESTAMP
+		WHERE
+			id = $1 AND
+			token_hash = $2 AND
+			accepted_at IS NULL AND
+			revoked_at IS NULL AND
+			expires_at > CURRENT_TIMESTAMP
+		`
+
+		result, err := tx.Exec(ctx, rawSQL, inv.ID, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			userID = 0
+			return nil
+		}
+
+		if newUser != nil {
+			rawSQL = `
+			INSERT INTO users (
+				first_name,
+				last_name,
+				email,
+				password_hash,
+				address,
+				phone_number,
+				date_of_birth,
+				role,
+				email_verified_at
+			) VALUES (
+				$1, $2, $3, $4, $5, $6, NULLIF($7, '')::DATE, $8, CURRENT_TIMESTAMP
+			) RETURNING id
+			`
+
+			err = tx.QueryRow(
+				ctx,
+				rawSQL,
+				newUser.FirstName,
+				newUser.LastName,
+				inv.Email,
+				newUser.PasswordHash,
+				newUser.Address,
+				newUser.PhoneNumber,
+				newUser.DateOfBirth,
+				inv.Role,
+			).Scan(&userID)
+			if err != nil {
+				return err
+			}
+		} else {
+			rawSQL = `
+			UPDATE
+				users
+			SET
+				email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+			WHERE
+				id = $1
+			`
+
+			_, err = tx.Exec(ctx, rawSQL, userID)
+			if err != nil {
+				return err
+			}
+		}
+
+		rawSQL = `
+		UPDATE
+			invitations
+		SET
+			accepted_by = $1
+		WHERE
+			id = $2
+		`
+
+		_, err = tx.Exec(ctx, rawSQL, userID, inv.ID)
+		if err != nil {
+			return err
+		}
+
+		return addMember(ctx, tx, inv.OrganizationID, userID, inv.Role)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,155 @@
+package userimpl
+
+import (
+	"amg/internal/identity/organization"
+	"amg/internal/identity/user"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+)
+
+// newSecret returns a random URL-safe string used for refresh tokens and family ids
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is the form refresh tokens are stored and looked up in
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession records a new login of the user in cmd.OrganizationID and issues
+// its first tokens
+func (s *service) startSession(ctx context.Context, u *user.User, cmd *user.LoginUserCommand) (*user.TokenPair, error) {
+	sessionID, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &user.Session{
+		ID:             sessionID,
+		UserID:         u.ID,
+		OrganizationID: cmd.OrganizationID,
+		UserAgent:      truncate(cmd.UserAgent, maxUserAgentLength),
+		IPAddress:      cmd.IPAddress,
+	}
+
+	err = s.store.createSession(ctx, session, &user.RefreshToken{
+		UserID:    u.ID,
+		FamilyID:  sessionID,
+		TokenHash: hashToken(refresh),
+	}, s.cfg.Auth.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(u, cmd.OrganizationID, sessionID, refresh)
+}
+
+// tokenPair issues an access token for u acting in orgID; u.Role must be their
+// role in that organization
+func (s *service) tokenPair(u *user.User, orgID int64, sessionID, refresh string) (*user.TokenPair, error) {
+	access, err := s.cfg.Auth.Keys.GenerateToken(u.ID, u.Role, orgID, sessionID, s.cfg.Auth.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user.TokenPair{
+		AccessToken:  access,
+		TokenType:    user.TokenTypeBearer,
+		ExpiresIn:    int64(s.cfg.Auth.AccessTokenTTL.Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh
+// token. Presenting a token that was already exchanged means it has leaked, so the
+// whole session is revoked and its holder has to log in again. The new access
+// token carries the current role of the user in the organization of the session,
+// and users who left it cannot refresh.
+func (s *service) RefreshToken(ctx context.Context, cmd *user.RefreshTokenCommand) (*user.TokenPair, error) {
+	stored, err := s.store.getRefreshToken(ctx, hashToken(cmd.RefreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	if stored == nil {
+		return nil, user.ErrInvalidRefreshToken
+	}
+
+	if stored.ReplacedBy != nil {
+		return nil, s.refreshTokenReused(ctx, stored)
+	}
+
+	if stored.RevokedAt != nil || stored.Expired {
+		return nil, user.ErrInvalidRefreshToken
+	}
+
+	orgID, err := s.store.getSessionOrganization(ctx, stored.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if orgID == 0 {
+		return nil, user.ErrInvalidRefreshToken
+	}
+
+	result, err := s.store.getUserByID(organization.WithID(ctx, orgID), stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, user.ErrInvalidRefreshToken
+	}
+
+	refresh, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.rotateRefreshToken(ctx, stored, &user.RefreshToken{
+		UserID:    stored.UserID,
+		FamilyID:  stored.FamilyID,
+		TokenHash: hashToken(refresh),
+	}, s.cfg.Auth.RefreshTokenTTL)
+	if err == user.ErrRefreshTokenReused {
+		return nil, s.refreshTokenReused(ctx, stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(result, orgID, stored.FamilyID, refresh)
+}
+
+func (s *service) refreshTokenReused(ctx context.Context, stored *user.RefreshToken) error {
+	s.log.Warn("refresh token reuse detected, revoking session",
+		zap.Int64("user_id", stored.UserID),
+		zap.Int64("token_id", stored.ID),
+	)
+
+	_, err := s.revokeSessions(ctx, stored.UserID, stored.FamilyID, "")
+	if err != nil {
+		return err
+	}
+
+	return user.ErrRefreshTokenReused
+}
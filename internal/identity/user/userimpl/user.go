@@ -3,11 +3,12 @@ package userimpl
 import (
 	"amg/config"
 	"amg/internal/db"
+	"amg/internal/identity/accesscontrol"
+	"amg/internal/identity/organization"
 	"amg/internal/identity/user"
-	"amg/pkg/util/jwt"
 	util "amg/pkg/util/password"
 	"context"
-	"time"
+	"strings"
 
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
@@ -19,47 +20,51 @@ type service struct {
 	log         *zap.Logger
 	db          db.DB
 	redisClient *redis.Client
+	access      accesscontrol.Service
 }
 
-func NewService(db db.DB, cfg *config.Config) *service {
+func NewService(db db.DB, cfg *config.Config, access accesscontrol.Service) *service {
 	return &service{
 		store:       NewStore(db),
 		cfg:         cfg,
 		db:          db,
 		redisClient: cfg.RedisClient,
+		access:      access,
 		log:         zap.L().Named("user.service"),
 	}
 }
 
-func (s *service) CreateUser(ctx context.Context, cmd *user.CreateUserCommand) error {
-	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
-		result, err := s.store.userTaken(ctx, 0, cmd.Email)
+// checkRoles fails with ErrorInvalidRole unless every one of roles exists
+func (s *service) checkRoles(ctx context.Context, roles ...string) error {
+	for _, role := range roles {
+		exists, err := s.access.RoleExists(ctx, role)
 		if err != nil {
 			return err
 		}
 
-		if len(result) > 0 {
-			return user.ErrUserAlreadyExists
+		if !exists {
+			return user.ErrorInvalidRole
 		}
+	}
 
-		passwordHash, err := util.HashPassword(cmd.Password)
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		cmd.Password = passwordHash
+func (s *service) GetByUserID(ctx context.Context, id int64) (*user.User, error) {
+	result, err := s.store.getUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 
-		err = s.store.create(ctx, cmd)
-		if err != nil {
-			return err
-		}
+	if result == nil {
+		return nil, user.ErrUserNotFound
+	}
 
-		return nil
-	})
+	return result, nil
 }
 
-func (s *service) GetByUserID(ctx context.Context, id int64) (*user.User, error) {
-	result, err := s.store.getUserByID(ctx, id)
+func (s *service) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	result, err := s.store.getUserByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
@@ -71,8 +76,41 @@ func (s *service) GetByUserID(ctx context.Context, id int64) (*user.User, error)
 	return result, nil
 }
 
+// UpdateUser saves a user on behalf of an admin. Like UpdateProfile, changing
+// the email address resets its verification and mails a link to the new address.
+// For users who also belong to other organizations only their role and team in
+// this one may change; the rest of their account is theirs to edit.
 func (s *service) UpdateUser(ctx context.Context, cmd *user.UpdateUserCommand) error {
-	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+	var emailChanged bool
+
+	err := s.checkRoles(ctx, cmd.Role)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.store.getUserByID(ctx, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return user.ErrUserNotFound
+	}
+
+	// The account itself is shared by every organization of the user, so only
+	// an organization holding it alone may change it
+	if len(cmd.Email) > 0 && cmd.Email != current.Email || !sameProfile(current, cmd) {
+		others, err := s.store.countOtherMemberships(ctx, cmd.ID)
+		if err != nil {
+			return err
+		}
+
+		if others > 0 {
+			return user.ErrSharedUser
+		}
+	}
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
 		result, err := s.store.userTaken(ctx, cmd.ID, cmd.Email)
 		if err != nil {
 			return err
@@ -85,6 +123,13 @@ func (s *service) UpdateUser(ctx context.Context, cmd *user.UpdateUserCommand) e
 			return user.ErrUserAlreadyExists
 		}
 
+		// The email is optional here; without one the user keeps theirs
+		if len(cmd.Email) == 0 {
+			cmd.Email = result[0].Email
+		}
+
+		emailChanged = result[0].Email != cmd.Email
+
 		err = s.store.update(ctx, cmd)
 		if err != nil {
 			return err
@@ -92,6 +137,68 @@ func (s *service) UpdateUser(ctx context.Context, cmd *user.UpdateUserCommand) e
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	if emailChanged {
+		s.sendVerificationEmail(ctx, cmd.ID)
+	}
+
+	return nil
+}
+
+// sameProfile reports whether cmd leaves the profile of u as it is
+func sameProfile(u *user.User, cmd *user.UpdateUserCommand) bool {
+	var dateOfBirth string
+	if u.DateOfBirth != nil {
+		// Dates are read back as timestamps
+		dateOfBirth, _, _ = strings.Cut(*u.DateOfBirth, "T")
+	}
+
+	return u.FirstName == cmd.FirstName &&
+		u.LastName == cmd.LastName &&
+		u.Address == cmd.Address &&
+		u.PhoneNumber == cmd.PhoneNumber &&
+		dateOfBirth == cmd.DateOfBirth
+}
+
+// UpdateProfile saves the profile of the user. Changing the email address resets
+// its verification and mails a link to the new address.
+func (s *service) UpdateProfile(ctx context.Context, cmd *user.UpdateProfileCommand) error {
+	var emailChanged bool
+
+	err := s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		result, err := s.store.userTaken(ctx, cmd.ID, cmd.Email)
+		if err != nil {
+			return err
+		}
+
+		if len(result) == 0 {
+			return user.ErrUserNotFound
+		}
+		if len(result) > 1 || (len(result) == 1 && result[0].ID != cmd.ID) {
+			return user.ErrEmailAlreadyExists
+		}
+
+		emailChanged = result[0].Email != cmd.Email
+
+		err = s.store.updateProfile(ctx, cmd)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if emailChanged {
+		s.sendVerificationEmail(ctx, cmd.ID)
+	}
+
+	return nil
 }
 
 func (s *service) SearchUser(ctx context.Context, query *user.SearchUserQuery) (*user.SearchUserResult, error) {
@@ -129,13 +236,20 @@ func (s *service) DeleteUser(ctx context.Context, id int64) error {
 		return err
 	}
 
-	return nil
+	return s.RevokeAllSessions(ctx, id)
 }
 
+// RegisterDefaultUser signs a user up as a member of the registration
+// organization
 func (s *service) RegisterDefaultUser(ctx context.Context, cmd *user.RegisterUserCommand) error {
 	role := "user"
 
-	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+	orgID, err := s.registrationOrganization(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
 		result, err := s.store.userTaken(ctx, 0, cmd.Email)
 		if err != nil {
 			return err
@@ -145,66 +259,97 @@ func (s *service) RegisterDefaultUser(ctx context.Context, cmd *user.RegisterUse
 			return user.ErrUserAlreadyExists
 		}
 
-		passwordHash, err := util.HashPassword(cmd.Password)
+		passwordHash, err := s.hashNewPassword(cmd.Password)
 		if err != nil {
 			return err
 		}
 
 		cmd.Password = passwordHash
 
-		err = s.store.registerDefaultUser(ctx, cmd, role)
+		err = s.store.registerDefaultUser(ctx, cmd, role, orgID)
 		if err != nil {
 			return err
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	result, err := s.store.getUserByEmail(ctx, cmd.Email)
+	if err != nil {
+		return err
+	}
+
+	if result != nil {
+		s.sendVerificationEmail(ctx, result.ID)
+	}
+
+	return nil
+}
+
+// registrationOrganization is the organization users join when they sign up
+// themselves, see config.AuthConfig.RegistrationOrganization
+func (s *service) registrationOrganization(ctx context.Context) (int64, error) {
+	id, err := s.store.getOrganizationIDBySlug(ctx, s.cfg.Auth.RegistrationOrganization)
+	if err != nil {
+		return 0, err
+	}
+
+	if id == 0 {
+		return 0, organization.ErrOrganizationNotFound
+	}
+
+	return id, nil
 }
 
-func (s *service) GetUserByEmail(ctx context.Context, cmd *user.LoginUserCommand) (string, error) {
+// GetUserByEmail logs a user in. Failed attempts are throttled per email and per
+// client IP, see checkLoginAllowed. Users with two-factor authentication get an
+// MFA challenge instead of tokens, see completeLogin.
+func (s *service) GetUserByEmail(ctx context.Context, cmd *user.LoginUserCommand) (*user.LoginResult, error) {
+	err := s.checkLoginAllowed(ctx, cmd.Email, cmd.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
 	result, err := s.store.getUserByEmail(ctx, cmd.Email)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if result == nil {
-		return "", user.ErrUserNotFound
+		return nil, s.loginFailed(ctx, cmd, user.ErrUserNotFound)
+	}
+
+	if result.ServiceAccount {
+		return nil, s.loginFailed(ctx, cmd, user.ErrServiceAccountLogin)
 	}
 
 	err = util.CheckPasswordHash(result.PasswordHash, cmd.Password)
 	if err != nil {
-		return "", user.ErrInvalidPassword
+		return nil, s.loginFailed(ctx, cmd, user.ErrInvalidPassword)
 	}
 
-	token, err := jwt.GenerateToken(result.Email, result.Role)
+	err = s.clearLoginFailures(ctx, cmd.Email)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return token, nil
-}
-
-// InvalidateToken adds the token to a blacklist using Redis
-func (s *service) InvalidateToken(ctx context.Context, token string) error {
-	expiration := 24 * time.Hour
-	err := s.redisClient.Set(ctx, token, "blacklisted", expiration).Err()
-	if err != nil {
-		return err
+	if s.cfg.Mail.RequireVerifiedEmail && result.EmailVerifiedAt == nil {
+		return nil, user.ErrEmailNotVerified
 	}
 
-	return nil
+	return s.completeLogin(ctx, result, cmd)
 }
 
-// IsTokenBlacklisted checks if the token is blacklisted in Redis
-func (s *service) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
-	_, err := s.redisClient.Get(ctx, token).Result()
-	if err == redis.Nil {
-		// Token is no found in blacklist
-		return false, nil
-	}
+// loginFailed records a failed login and returns reason, or the error that kept
+// the failure from being recorded
+func (s *service) loginFailed(ctx context.Context, cmd *user.LoginUserCommand, reason error) error {
+	err := s.recordLoginFailure(ctx, cmd.Email, cmd.IPAddress)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	return true, nil
+	return reason
 }
@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type logSender struct {
+	log *zap.Logger
+}
+
+// NewLog returns a Sender that only logs messages, for local development
+func NewLog() Sender {
+	return &logSender{
+		log: zap.L().Named("mailer"),
+	}
+}
+
+func (s *logSender) Send(ctx context.Context, msg *Message) error {
+	err := msg.validate()
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("mail",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInvalidMessage = errors.New("invalid mail message")
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+func (msg *Message) validate() error {
+	if len(msg.To) == 0 || len(msg.Subject) == 0 {
+		return ErrInvalidMessage
+	}
+
+	return nil
+}
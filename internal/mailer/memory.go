@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a Sender that keeps every message, for tests
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(ctx context.Context, msg *Message) error {
+	err := msg.validate()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, *msg)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
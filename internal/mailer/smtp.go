@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type smtpSender struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTP returns a Sender delivering through an SMTP server. Authentication is
+// skipped when username is empty; net/smtp upgrades to TLS when the server offers
+// STARTTLS and refuses to send credentials over an unencrypted connection.
+func NewSMTP(host, port, username, password, from string) Sender {
+	var auth smtp.Auth
+	if len(username) > 0 {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &smtpSender{
+		addr: net.JoinHostPort(host, port),
+		host: host,
+		auth: auth,
+		from: from,
+	}
+}
+
+func (s *smtpSender) Send(ctx context.Context, msg *Message) error {
+	err := msg.validate()
+	if err != nil {
+		return err
+	}
+
+	// Header injection guard: addresses and subject end up in raw headers
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+
+	// net/smtp has no context support, so only honour cancellation before sending
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, s.format(msg))
+}
+
+func (s *smtpSender) format(msg *Message) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return buf.Bytes()
+}
@@ -2,17 +2,24 @@ package middleware
 
 import (
 	"amg/internal/identity/accesscontrol"
+	"amg/internal/identity/organization"
 	"amg/internal/identity/user"
 	"amg/pkg/util/jwt"
-	"context"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// Middleware to check if the user has a valid JWT
-func JWTProtected(secret string, service user.Service) fiber.Handler {
+// HeaderAPIKey carries an API key in place of a bearer token
+const HeaderAPIKey = "X-API-Key"
+
+// Middleware to check if the user has a valid JWT or API key
+func JWTProtected(keys *jwt.KeySet, service user.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if apiKey := c.Get(HeaderAPIKey); apiKey != "" {
+			return apiKeyProtected(c, apiKey, service)
+		}
+
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -28,34 +35,128 @@ func JWTProtected(secret string, service user.Service) fiber.Handler {
 
 		tokenStr := authHeader[len("Bearer "):]
 
-		claims, err := jwt.ValidateToken(tokenStr)
+		claims, err := keys.ValidateToken(tokenStr)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Invalid or expired JWT",
 			})
 		}
 
-		// Check if the token is blacklisted
-		isBlacklisted, err := service.IsTokenBlacklisted(context.Background(), tokenStr)
+		// Tokens issued before organizations existed do not say which one they act in
+		if claims.SessionID == "" || claims.OrganizationID <= 0 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or expired JWT",
+			})
+		}
+
+		// Check if the session has been logged out or revoked
+		isRevoked, err := service.IsSessionRevoked(c.Context(), claims.SessionID)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Internal server error while checking token",
 			})
 		}
 
-		if isBlacklisted {
+		if isRevoked {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Token is blacklisted",
+				"error": "Session has been revoked",
 			})
 		}
 
+		// Last seen is informational only, so a failed update must not fail the request
+		_ = service.TouchSession(c.Context(), claims.SessionID)
+
 		c.Locals("userID", claims.UserID)
 		c.Locals("role", claims.Role)
+		c.Locals("tokenID", claims.ID)
+		c.Locals("sessionID", claims.SessionID)
+		c.Locals("organizationID", claims.OrganizationID)
+		c.Locals(organization.ContextKey, claims.OrganizationID)
+
+		return c.Next()
+	}
+}
+
+// apiKeyProtected authenticates a request by API key. The key acts as its owner
+// with the same locals as a JWT, plus apiKeyID and the scopes it is limited to.
+// A key acts in the organization it was created in.
+func apiKeyProtected(c *fiber.Ctx, apiKey string, service user.Service) error {
+	key, err := service.AuthenticateAPIKey(c.Context(), apiKey)
+	if err != nil {
+		if err == user.ErrInvalidAPIKey {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid, expired or revoked API key",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Internal server error while checking API key",
+		})
+	}
+
+	c.Locals("userID", key.UserID)
+	c.Locals("role", key.OwnerRole)
+	c.Locals("apiKeyID", key.ID)
+	c.Locals("scopes", []string(key.Scopes))
+	c.Locals("organizationID", key.OrganizationID)
+	c.Locals(organization.ContextKey, key.OrganizationID)
+
+	return c.Next()
+}
+
+// RequireSession refuses API keys on routes meant for people, such as managing
+// the account itself or its keys
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Locals("apiKeyID") != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "This endpoint requires a user session",
+			})
+		}
 
 		return c.Next()
 	}
 }
 
+// RequireSystemOrganization limits a route to callers acting in the organization
+// with slug. Roles, permissions and the like are shared by all organizations, so
+// only the admins of that one may change them, whatever their permissions.
+func RequireSystemOrganization(organizations organization.Service, slug string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		system, err := organizations.GetBySlug(c.Context(), slug)
+		if err != nil && err != organization.ErrOrganizationNotFound {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Internal server error while checking organization",
+			})
+		}
+
+		orgID, _ := c.Locals("organizationID").(int64)
+
+		if system == nil || system.ID != orgID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Only the system organization can change this resource",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// hasScope checks the scopes of an API key; other credentials are not scoped
+func hasScope(c *fiber.Ctx, permission string) bool {
+	scopes, ok := c.Locals("scopes").([]string)
+	if !ok {
+		return true
+	}
+
+	for _, scope := range scopes {
+		if scope == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Middleware to check if the user has the required role
 func RequireRole(requiredRoles ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -81,11 +182,18 @@ func RequireRole(requiredRoles ...string) fiber.Handler {
 }
 
 // RequirePermission checks if the user has the required permission
-func RequirePermission(permission string) fiber.Handler {
+func RequirePermission(access accesscontrol.Service, permission string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		role := c.Locals("role").(string)
 
-		if !accesscontrol.HasPermission(role, permission) {
+		allowed, err := access.HasPermission(c.Context(), role, permission)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Internal server error while checking permissions",
+			})
+		}
+
+		if !allowed || !hasScope(c, permission) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "You do not have permission to access this resource",
 			})
@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is a parsed cron expression with the standard five fields:
+// minute, hour, day of month, month and day of week. Times are evaluated in UTC.
+type Spec struct {
+	minute, hour, dom, month, dow uint64
+
+	// domStar and dowStar record an unrestricted field, which changes how the
+	// two day fields combine (see dayMatches)
+	domStar, dowStar bool
+}
+
+type bounds struct {
+	min, max int
+}
+
+var (
+	minuteBounds = bounds{0, 59}
+	hourBounds   = bounds{0, 23}
+	domBounds    = bounds{1, 31}
+	monthBounds  = bounds{1, 12}
+	dowBounds    = bounds{0, 7}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSpec parses a five-field cron expression such as "0 8 * * 1" or one of the
+// @hourly, @daily, @weekly, @monthly and @yearly descriptors. Each field accepts
+// "*", single values, ranges ("1-5"), steps ("*/15", "0-30/5") and comma lists.
+func ParseSpec(expr string) (*Spec, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := descriptors[expr]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var (
+		spec Spec
+		err  error
+	)
+
+	if spec.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+	if spec.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+	if spec.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, err
+	}
+	if spec.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+	if spec.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, err
+	}
+
+	// Sunday may be written as 0 or 7
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+
+	spec.domStar = fields[2] == "*" || fields[2] == "?"
+	spec.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return &spec, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		lo, hi := b.min, b.max
+
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			ends := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(ends[0]); err != nil {
+				return 0, fmt.Errorf("invalid range in %q", part)
+			}
+			if hi, err = strconv.Atoi(ends[1]); err != nil {
+				return 0, fmt.Errorf("invalid range in %q", part)
+			}
+		default:
+			var err error
+			if lo, err = strconv.Atoi(rangePart); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			// "5/10" means starting at 5 every 10
+			if step > 1 {
+				hi = b.max
+			} else {
+				hi = lo
+			}
+		}
+
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("value out of range in %q", part)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// dayMatches follows cron semantics: when both day fields are restricted a day
+// matches if either of them does, otherwise both must match
+func (s *Spec) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// Next returns the first time strictly after t that matches the spec, or the zero
+// time if there is none within the next five years (e.g. "0 0 30 2 *").
+func (s *Spec) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSpecRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@reboot",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"0-60 * * * *",
+		"1,,2 * * * *",
+	}
+
+	for _, expr := range tests {
+		_, err := ParseSpec(expr)
+		if err == nil {
+			t.Errorf("ParseSpec(%q) should fail", expr)
+		}
+	}
+}
+
+func TestSpecNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2024, time.May, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", from, time.Date(2024, time.May, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", from, time.Date(2024, time.May, 15, 10, 15, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.May, 15, 10, 15, 0, 0, time.UTC), time.Date(2024, time.May, 15, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", from, time.Date(2024, time.May, 15, 10, 25, 0, 0, time.UTC)},
+		{"0-30/10 9-17 * * 1-5", from, time.Date(2024, time.May, 15, 10, 10, 0, 0, time.UTC)},
+		{"0,45 10 * * *", from, time.Date(2024, time.May, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 8 * * 1", from, time.Date(2024, time.May, 20, 8, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", from, time.Date(2024, time.May, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", from, time.Date(2024, time.May, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 ? * 7", from, time.Date(2024, time.May, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", from, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", from, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", from, time.Date(2024, time.May, 31, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", from, time.Date(2024, time.May, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", from, time.Date(2024, time.May, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", from, time.Date(2024, time.May, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", from, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+
+		// Both day fields restricted: either may match
+		{"0 12 1 * 1", from, time.Date(2024, time.May, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", from, time.Date(2024, time.May, 17, 0, 0, 0, 0, time.UTC)},
+
+		// Times are evaluated in UTC whatever the location of from
+		{"0 12 * * *", time.Date(2024, time.May, 15, 13, 30, 0, 0, time.FixedZone("CEST", 2*60*60)), time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC)},
+
+		// Never within five years
+		{"0 0 30 2 *", from, time.Time{}},
+		{"0 0 31 6 *", from, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.expr)
+		if err != nil {
+			t.Errorf("ParseSpec(%q): %v", tt.expr, err)
+			continue
+		}
+
+		got := spec.Next(tt.from)
+		if !got.Equal(tt.want) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"amg/internal/api/errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule.not-found", "Schedule not found")
+	ErrRunNotFound      = errors.New("schedule.run-not-found", "Schedule run not found")
+	ErrInvalidID        = errors.New("schedule.invalid-id", "Invalid id")
+	ErrInvalidName      = errors.New("schedule.invalid-name", "Invalid name")
+	ErrInvalidKind      = errors.New("schedule.invalid-kind", "Invalid kind")
+	ErrInvalidSpec      = errors.New("schedule.invalid-spec", "Invalid schedule spec")
+	ErrUnknownJob       = errors.New("schedule.unknown-job", "No job registered for schedule kind")
+)
+
+const (
+	// KindWeeklySummary summarises new users and submitted reports since the previous run
+	KindWeeklySummary = "weekly_summary"
+)
+
+var validKinds = map[string]bool{
+	KindWeeklySummary: true,
+}
+
+func IsValidKind(kind string) bool {
+	return validKinds[kind]
+}
+
+const (
+	RunStatusRunning   = "running"
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+)
+
+// Schedule runs a job on the data of its organization
+type Schedule struct {
+	ID             int64      `db:"id" json:"id"`
+	OrganizationID int64      `db:"organization_id" json:"organization_id"`
+	Name           string     `db:"name" json:"name"`
+	Kind           string     `db:"kind" json:"kind"`
+	Spec           string     `db:"spec" json:"spec"`
+	Enabled        bool       `db:"enabled" json:"enabled"`
+	LastRunAt      *time.Time `db:"last_run_at" json:"last_run_at"`
+	NextRunAt      *time.Time `db:"next_run_at" json:"next_run_at"`
+	CreatedAt      string     `db:"created_at" json:"created_at"`
+	UpdatedAt      string     `db:"updated_at" json:"updated_at"`
+}
+
+type Run struct {
+	ID             int64      `db:"id" json:"id"`
+	ScheduleID     int64      `db:"schedule_id" json:"schedule_id"`
+	OrganizationID int64      `db:"organization_id" json:"organization_id"`
+	Status         string     `db:"status" json:"status"`
+	Output         *string    `db:"output" json:"output"`
+	Error          string     `db:"error" json:"error,omitempty"`
+	StartedAt      time.Time  `db:"started_at" json:"started_at"`
+	FinishedAt     *time.Time `db:"finished_at" json:"finished_at"`
+}
+
+type CreateScheduleCommand struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Spec    string `json:"spec"`
+	Enabled bool   `json:"enabled"`
+}
+
+type UpdateScheduleCommand struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Spec    string `json:"spec"`
+	Enabled bool   `json:"enabled"`
+}
+
+type SearchScheduleQuery struct {
+	Name    string `query:"name"`
+	Kind    string `query:"kind"`
+	Page    int    `query:"page"`
+	PerPage int    `query:"per_page"`
+}
+
+type SearchScheduleResult struct {
+	TotalCount int64       `json:"total_count"`
+	Schedules  []*Schedule `json:"schedules"`
+	Page       int         `json:"page"`
+	PerPage    int         `json:"per_page"`
+}
+
+type SearchRunQuery struct {
+	ScheduleID int64  `query:"-"`
+	Status     string `query:"status"`
+	Page       int    `query:"page"`
+	PerPage    int    `query:"per_page"`
+}
+
+type SearchRunResult struct {
+	TotalCount int64  `json:"total_count"`
+	Runs       []*Run `json:"runs"`
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+}
+
+func (cmd *CreateScheduleCommand) Validate() error {
+	if len(strings.TrimSpace(cmd.Name)) == 0 || len(cmd.Name) > 255 {
+		return ErrInvalidName
+	}
+	if !IsValidKind(cmd.Kind) {
+		return ErrInvalidKind
+	}
+	if _, err := ParseSpec(cmd.Spec); err != nil {
+		return ErrInvalidSpec
+	}
+
+	return nil
+}
+
+func (cmd *UpdateScheduleCommand) Validate() error {
+	if cmd.ID <= 0 {
+		return ErrInvalidID
+	}
+	if len(strings.TrimSpace(cmd.Name)) == 0 || len(cmd.Name) > 255 {
+		return ErrInvalidName
+	}
+	if _, err := ParseSpec(cmd.Spec); err != nil {
+		return ErrInvalidSpec
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+type Service interface {
+	CreateSchedule(ctx context.Context, cmd *CreateScheduleCommand) error
+	UpdateSchedule(ctx context.Context, cmd *UpdateScheduleCommand) error
+	GetByScheduleID(ctx context.Context, id int64) (*Schedule, error)
+	DeleteSchedule(ctx context.Context, id int64) error
+	SearchSchedule(ctx context.Context, query *SearchScheduleQuery) (*SearchScheduleResult, error)
+
+	SearchRun(ctx context.Context, query *SearchRunQuery) (*SearchRunResult, error)
+	GetByRunID(ctx context.Context, scheduleID, runID int64) (*Run, error)
+}
+
+// Job generates the output of one run of a schedule. Since is the time of the
+// previous run (or one week ago on the first run) and until is the run time.
+// The returned value is stored as JSON with the run.
+type Job interface {
+	Run(ctx context.Context, since, until time.Time) (interface{}, error)
+}
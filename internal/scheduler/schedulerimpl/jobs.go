@@ -0,0 +1,95 @@
+package schedulerimpl
+
+import (
+	"amg/internal/db"
+	"amg/internal/identity/organization"
+	"amg/internal/identity/reports"
+	"context"
+	"time"
+)
+
+type weeklySummary struct {
+	From             time.Time              `json:"from"`
+	To               time.Time              `json:"to"`
+	NewUsers         int64                  `json:"new_users"`
+	SubmittedReports int64                  `json:"submitted_reports"`
+	ReportsByStatus  []*reports.StatusCount `json:"reports_by_status"`
+}
+
+// weeklySummaryJob counts the users that joined the organization and the
+// reports that were submitted in it between two runs, along with the current
+// status of reports created in that window
+type weeklySummaryJob struct {
+	db db.DB
+}
+
+func (j *weeklySummaryJob) Run(ctx context.Context, since, until time.Time) (interface{}, error) {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := weeklySummary{
+		From:            since,
+		To:              until,
+		ReportsByStatus: make([]*reports.StatusCount, 0),
+	}
+
+	rawSQL := `
+	SELECT
+		COUNT(*)
+	FROM
+		organization_members
+	WHERE
+		organization_id = $3 AND
+		created_at >= $1 AND
+		created_at < $2
+	`
+
+	err = j.db.Get(ctx, &result.NewUsers, rawSQL, since, until, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL = `
+	SELECT
+		COUNT(*)
+	FROM
+		report_status_history h
+	JOIN
+		reports r ON r.id = h.report_id
+	WHERE
+		r.organization_id = $4 AND
+		h.to_status = $3 AND
+		h.created_at >= $1 AND
+		h.created_at < $2
+	`
+
+	err = j.db.Get(ctx, &result.SubmittedReports, rawSQL, since, until, reports.StatusSubmitted, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL = `
+	SELECT
+		status,
+		COUNT(*) AS count
+	FROM
+		reports
+	WHERE
+		organization_id = $3 AND
+		created_at >= $1 AND
+		created_at < $2
+	GROUP BY
+		status
+	ORDER BY
+		status
+	`
+
+	err = j.db.Select(ctx, &result.ReportsByStatus, rawSQL, since, until, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
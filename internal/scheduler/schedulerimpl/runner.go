@@ -0,0 +1,201 @@
+package schedulerimpl
+
+import (
+	"amg/config"
+	"amg/internal/db"
+	"amg/internal/identity/organization"
+	"amg/internal/scheduler"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultSince is how far back the first run of a schedule looks
+const defaultSince = 7 * 24 * time.Hour
+
+// Runner polls the schedules table and runs due jobs in their own goroutines
+type Runner struct {
+	store    *store
+	jobs     map[string]scheduler.Job
+	interval time.Duration
+	log      *zap.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRunner(db db.DB, cfg *config.Config) *Runner {
+	return &Runner{
+		store: NewStore(db),
+		jobs: map[string]scheduler.Job{
+			scheduler.KindWeeklySummary: &weeklySummaryJob{db: db},
+		},
+		interval: cfg.Scheduler.PollInterval,
+		log:      zap.L().Named("scheduler.runner"),
+	}
+}
+
+// Start begins polling in the background. It returns immediately.
+func (r *Runner) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go r.loop(ctx)
+}
+
+// Stop cancels the polling loop and any running jobs, then waits for them to
+// record their result or for ctx to expire, whichever comes first
+func (r *Runner) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) loop(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.tick(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.tick(ctx)
+		}
+	}
+}
+
+func (r *Runner) tick(ctx context.Context) {
+	now := time.Now().UTC()
+
+	due, err := r.store.dueSchedules(ctx, now)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.log.Error("failed to load due schedules", zap.Error(err))
+		}
+		return
+	}
+
+	for _, schedule := range due {
+		next, err := nextRunAt(schedule.Spec, now)
+		if err != nil {
+			r.log.Error("invalid schedule spec", zap.Int64("schedule_id", schedule.ID), zap.String("spec", schedule.Spec))
+			continue
+		}
+
+		claimed, err := r.store.claim(ctx, schedule, now, next)
+		if err != nil {
+			r.log.Error("failed to claim schedule", zap.Int64("schedule_id", schedule.ID), zap.Error(err))
+			continue
+		}
+
+		// Another instance got there first
+		if !claimed {
+			continue
+		}
+
+		r.wg.Add(1)
+		go r.run(ctx, schedule, now)
+	}
+}
+
+func (r *Runner) run(ctx context.Context, schedule *scheduler.Schedule, now time.Time) {
+	defer r.wg.Done()
+
+	// The job only sees the data of the organization the schedule belongs to
+	ctx = organization.WithID(ctx, schedule.OrganizationID)
+
+	// Bookkeeping must outlive shutdown so that no run is left in the running state
+	bookkeeping := context.WithoutCancel(ctx)
+
+	run := &scheduler.Run{
+		ScheduleID:     schedule.ID,
+		OrganizationID: schedule.OrganizationID,
+		Status:         scheduler.RunStatusRunning,
+		StartedAt:      now,
+	}
+
+	err := r.store.createRun(bookkeeping, run)
+	if err != nil {
+		r.log.Error("failed to record schedule run", zap.Int64("schedule_id", schedule.ID), zap.Error(err))
+		return
+	}
+
+	since := now.Add(-defaultSince)
+	if schedule.LastRunAt != nil {
+		since = *schedule.LastRunAt
+	}
+
+	output, err := r.execute(ctx, schedule.Kind, since, now)
+
+	finishedAt := time.Now().UTC()
+	run.FinishedAt = &finishedAt
+
+	if err == nil {
+		var data []byte
+		data, err = json.Marshal(output)
+		if err == nil {
+			result := string(data)
+			run.Output = &result
+		}
+	}
+
+	if err != nil {
+		run.Status = scheduler.RunStatusFailed
+		run.Error = err.Error()
+	} else {
+		run.Status = scheduler.RunStatusSucceeded
+	}
+
+	err = r.store.finishRun(bookkeeping, run)
+	if err != nil {
+		r.log.Error("failed to record schedule run result", zap.Int64("run_id", run.ID), zap.Error(err))
+		return
+	}
+
+	r.log.Info("schedule run finished",
+		zap.Int64("schedule_id", schedule.ID),
+		zap.Int64("run_id", run.ID),
+		zap.String("status", run.Status),
+		zap.Duration("duration", finishedAt.Sub(now)),
+	)
+}
+
+// execute runs the job for kind, turning a panic into a failed run
+func (r *Runner) execute(ctx context.Context, kind string, since, until time.Time) (output interface{}, err error) {
+	job, ok := r.jobs[kind]
+	if !ok {
+		return nil, scheduler.ErrUnknownJob
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+
+	return job.Run(ctx, since, until)
+}
@@ -0,0 +1,168 @@
+package schedulerimpl
+
+import (
+	"amg/config"
+	"amg/internal/db"
+	"amg/internal/scheduler"
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type service struct {
+	store *store
+	cfg   *config.Config
+	log   *zap.Logger
+	db    db.DB
+}
+
+func NewService(db db.DB, cfg *config.Config) *service {
+	return &service{
+		store: NewStore(db),
+		cfg:   cfg,
+		db:    db,
+		log:   zap.L().Named("scheduler.service"),
+	}
+}
+
+// nextRunAt computes the next occurrence of a validated spec after now
+func nextRunAt(spec string, now time.Time) (time.Time, error) {
+	parsed, err := scheduler.ParseSpec(spec)
+	if err != nil {
+		return time.Time{}, scheduler.ErrInvalidSpec
+	}
+
+	next := parsed.Next(now)
+	if next.IsZero() {
+		return time.Time{}, scheduler.ErrInvalidSpec
+	}
+
+	return next, nil
+}
+
+func (s *service) CreateSchedule(ctx context.Context, cmd *scheduler.CreateScheduleCommand) error {
+	next, err := nextRunAt(cmd.Spec, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = s.store.create(ctx, cmd, next)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) GetByScheduleID(ctx context.Context, id int64) (*scheduler.Schedule, error) {
+	result, err := s.store.getScheduleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, scheduler.ErrScheduleNotFound
+	}
+
+	return result, nil
+}
+
+func (s *service) UpdateSchedule(ctx context.Context, cmd *scheduler.UpdateScheduleCommand) error {
+	result, err := s.store.getScheduleByID(ctx, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return scheduler.ErrScheduleNotFound
+	}
+
+	next, err := nextRunAt(cmd.Spec, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = s.store.update(ctx, cmd, next)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) SearchSchedule(ctx context.Context, query *scheduler.SearchScheduleQuery) (*scheduler.SearchScheduleResult, error) {
+	if query.Page <= 0 {
+		query.Page = s.cfg.Pagination.Page
+	}
+
+	if query.PerPage <= 0 {
+		query.PerPage = s.cfg.Pagination.PageLimit
+	}
+
+	result, err := s.store.search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PerPage = query.PerPage
+	result.Page = query.Page
+
+	return result, nil
+}
+
+func (s *service) DeleteSchedule(ctx context.Context, id int64) error {
+	result, err := s.store.getScheduleByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return scheduler.ErrScheduleNotFound
+	}
+
+	err = s.store.delete(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) SearchRun(ctx context.Context, query *scheduler.SearchRunQuery) (*scheduler.SearchRunResult, error) {
+	_, err := s.GetByScheduleID(ctx, query.ScheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Page <= 0 {
+		query.Page = s.cfg.Pagination.Page
+	}
+
+	if query.PerPage <= 0 {
+		query.PerPage = s.cfg.Pagination.PageLimit
+	}
+
+	result, err := s.store.searchRuns(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PerPage = query.PerPage
+	result.Page = query.Page
+
+	return result, nil
+}
+
+func (s *service) GetByRunID(ctx context.Context, scheduleID, runID int64) (*scheduler.Run, error) {
+	result, err := s.store.getRunByID(ctx, scheduleID, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return nil, scheduler.ErrRunNotFound
+	}
+
+	return result, nil
+}
@@ -0,0 +1,455 @@
+package schedulerimpl
+
+import (
+	"amg/internal/db"
+	"amg/internal/identity/organization"
+	"amg/internal/scheduler"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type store struct {
+	db     db.DB
+	logger *zap.Logger
+}
+
+func NewStore(db db.DB) *store {
+	return &store{
+		db:     db,
+		logger: zap.L().Named("scheduler.store"),
+	}
+}
+
+// create adds a schedule to the organization ctx acts in
+func (s *store) create(ctx context.Context, cmd *scheduler.CreateScheduleCommand, nextRunAt time.Time) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		INSERT INTO schedules (
+			organization_id,
+			name,
+			kind,
+			spec,
+			enabled,
+			next_run_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		) RETURNING id
+	`
+
+		err := tx.QueryRow(
+			ctx,
+			rawSQL,
+			orgID,
+			cmd.Name,
+			cmd.Kind,
+			cmd.Spec,
+			cmd.Enabled,
+			nextRunAt,
+		).Scan(&cmd.ID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) getScheduleByID(ctx context.Context, id int64) (*scheduler.Schedule, error) {
+	var result scheduler.Schedule
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		organization_id,
+		name,
+		kind,
+		spec,
+		enabled,
+		last_run_at,
+		next_run_at,
+		created_at,
+		updated_at
+	FROM
+		schedules
+	WHERE
+		id = $1 AND
+		organization_id = $2
+	`
+
+	err = s.db.Get(ctx, &result, rawSQL, id, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *store) update(ctx context.Context, cmd *scheduler.UpdateScheduleCommand, nextRunAt time.Time) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+		UPDATE
+			schedules
+		SET
+			name = $1,
+			spec = $2,
+			enabled = $3,
+			next_run_at = $4,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE
+			id = $5 AND
+			organization_id = $6
+		`
+
+		_, err := tx.Exec(
+			ctx,
+			rawSQL,
+			cmd.Name,
+			cmd.Spec,
+			cmd.Enabled,
+			nextRunAt,
+			cmd.ID,
+			orgID,
+		)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *store) search(ctx context.Context, query *scheduler.SearchScheduleQuery) (*scheduler.SearchScheduleResult, error) {
+	var (
+		result = scheduler.SearchScheduleResult{
+			Schedules: make([]*scheduler.Schedule, 0),
+		}
+		sql            bytes.Buffer
+		whereCondition = []string{"organization_id = $1"}
+		paramIndex     = 2
+	)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	whereParams := []interface{}{orgID}
+
+	sql.WriteString(`
+	SELECT
+		id,
+		organization_id,
+		name,
+		kind,
+		spec,
+		enabled,
+		last_run_at,
+		next_run_at,
+		created_at,
+		updated_at
+	FROM
+		schedules
+	`)
+
+	if len(query.Name) > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("name ILIKE $%d", paramIndex))
+		whereParams = append(whereParams, "%"+query.Name+"%")
+		paramIndex++
+	}
+
+	if len(query.Kind) > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("kind = $%d", paramIndex))
+		whereParams = append(whereParams, query.Kind)
+		paramIndex++
+	}
+
+	sql.WriteString(" WHERE " + strings.Join(whereCondition, " AND "))
+	sql.WriteString(" ORDER BY created_at DESC")
+
+	count, err := s.getCount(ctx, sql, whereParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.PerPage > 0 {
+		offset := query.PerPage * (query.Page - 1)
+		sql.WriteString(fmt.Sprintf(" LIMIT $%d OFFSET $%d", paramIndex, paramIndex+1))
+		whereParams = append(whereParams, query.PerPage, offset)
+	}
+
+	err = s.db.Select(ctx, &result.Schedules, sql.String(), whereParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	result.TotalCount = count
+
+	return &result, nil
+}
+
+func (s *store) getCount(ctx context.Context, sql bytes.Buffer, whereParams []interface{}) (int64, error) {
+	var count int64
+
+	rawSQL := "SELECT COUNT(*) FROM (" + sql.String() + ") as t1"
+
+	err := s.db.Get(ctx, &count, rawSQL, whereParams...)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (s *store) delete(ctx context.Context, id int64) error {
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithTransaction(ctx, func(ctx context.Context, tx db.Tx) error {
+		rawSQL := `
+			DELETE
+			FROM
+				schedules
+			WHERE
+				id = $1 AND
+				organization_id = $2
+		`
+
+		_, err := tx.Exec(ctx, rawSQL, id, orgID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// dueSchedules returns the enabled schedules of every organization whose next
+// run time has passed
+func (s *store) dueSchedules(ctx context.Context, now time.Time) ([]*scheduler.Schedule, error) {
+	result := make([]*scheduler.Schedule, 0)
+
+	rawSQL := `
+	SELECT
+		id,
+		organization_id,
+		name,
+		kind,
+		spec,
+		enabled,
+		last_run_at,
+		next_run_at,
+		created_at,
+		updated_at
+	FROM
+		schedules
+	WHERE
+		enabled = TRUE AND
+		next_run_at <= $1
+	ORDER BY next_run_at ASC
+	`
+
+	err := s.db.Select(ctx, &result, rawSQL, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// claim advances a due schedule to its next run time. It only succeeds if the
+// schedule still has the next_run_at the caller saw, so when several instances
+// poll the same table exactly one of them runs each occurrence.
+func (s *store) claim(ctx context.Context, schedule *scheduler.Schedule, now, nextRunAt time.Time) (bool, error) {
+	rawSQL := `
+	UPDATE
+		schedules
+	SET
+		last_run_at = $1,
+		next_run_at = $2
+	WHERE
+		id = $3 AND
+		next_run_at = $4
+	`
+
+	res, err := s.db.Exec(ctx, rawSQL, now, nextRunAt, schedule.ID, schedule.NextRunAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (s *store) createRun(ctx context.Context, run *scheduler.Run) error {
+	rawSQL := `
+	INSERT INTO schedule_runs (
+		schedule_id,
+		organization_id,
+		status,
+		started_at
+	) VALUES (
+		$1, $2, $3, $4
+	) RETURNING id
+	`
+
+	var id int64
+
+	err := s.db.Get(ctx, &id, rawSQL, run.ScheduleID, run.OrganizationID, run.Status, run.StartedAt)
+	if err != nil {
+		return err
+	}
+
+	run.ID = id
+
+	return nil
+}
+
+func (s *store) finishRun(ctx context.Context, run *scheduler.Run) error {
+	rawSQL := `
+	UPDATE
+		schedule_runs
+	SET
+		status = $1,
+		output = $2,
+		error = $3,
+		finished_at = $4
+	WHERE
+		id = $5
+	`
+
+	_, err := s.db.Exec(ctx, rawSQL, run.Status, run.Output, run.Error, run.FinishedAt, run.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *store) searchRuns(ctx context.Context, query *scheduler.SearchRunQuery) (*scheduler.SearchRunResult, error) {
+	var (
+		result = scheduler.SearchRunResult{
+			Runs: make([]*scheduler.Run, 0),
+		}
+		sql            bytes.Buffer
+		whereCondition = []string{"schedule_id = $1", "organization_id = $2"}
+		paramIndex     = 3
+	)
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	whereParams := []interface{}{query.ScheduleID, orgID}
+
+	sql.WriteString(`
+	SELECT
+		id,
+		schedule_id,
+		organization_id,
+		status,
+		output,
+		error,
+		started_at,
+		finished_at
+	FROM
+		schedule_runs
+	`)
+
+	if len(query.Status) > 0 {
+		whereCondition = append(whereCondition, fmt.Sprintf("status = $%d", paramIndex))
+		whereParams = append(whereParams, query.Status)
+		paramIndex++
+	}
+
+	sql.WriteString(" WHERE " + strings.Join(whereCondition, " AND "))
+	sql.WriteString(" ORDER BY started_at DESC")
+
+	count, err := s.getCount(ctx, sql, whereParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.PerPage > 0 {
+		offset := query.PerPage * (query.Page - 1)
+		sql.WriteString(fmt.Sprintf(" LIMIT $%d OFFSET $%d", paramIndex, paramIndex+1))
+		whereParams = append(whereParams, query.PerPage, offset)
+	}
+
+	err = s.db.Select(ctx, &result.Runs, sql.String(), whereParams...)
+	if err != nil {
+		return nil, err
+	}
+
+	result.TotalCount = count
+
+	return &result, nil
+}
+
+func (s *store) getRunByID(ctx context.Context, scheduleID, runID int64) (*scheduler.Run, error) {
+	var result scheduler.Run
+
+	orgID, err := organization.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSQL := `
+	SELECT
+		id,
+		schedule_id,
+		organization_id,
+		status,
+		output,
+		error,
+		started_at,
+		finished_at
+	FROM
+		schedule_runs
+	WHERE
+		id = $1 AND
+		schedule_id = $2 AND
+		organization_id = $3
+	`
+
+	err = s.db.Get(ctx, &result, rawSQL, runID, scheduleID, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -5,23 +5,33 @@ import (
 	errors "amg/internal/api/errors"
 	"amg/internal/db"
 	"amg/internal/logger"
+	"amg/pkg/util/jwt"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
 type Server struct {
-	app       *fiber.App
-	port      string
-	db        db.DB
-	cfg       *config.Config
-	log       *logger.Logger
-	jwtSecret string
+	app     *fiber.App
+	port    string
+	db      db.DB
+	cfg     *config.Config
+	log     *logger.Logger
+	jwtKeys *jwt.KeySet
 }
 
+// bodyLimitOverhead leaves room for multipart framing around the largest attachment
+const bodyLimitOverhead = 1 << 20
+
 func NewServer(cfg *config.Config) *Server {
+	bodyLimit := fiber.DefaultBodyLimit
+	if limit := int(cfg.Attachments.MaxSize) + bodyLimitOverhead; limit > bodyLimit {
+		bodyLimit = limit
+	}
+
 	app := fiber.New(fiber.Config{
 		ErrorHandler: errors.DefaultErrorHandler,
+		BodyLimit:    bodyLimit,
 	})
 
 	app.Use(cors.New())
@@ -33,12 +43,12 @@ func NewServer(cfg *config.Config) *Server {
 	}
 
 	return &Server{
-		app:       app,
-		port:      port,
-		db:        sqlxDB,
-		cfg:       cfg,
-		log:       cfg.Logger,
-		jwtSecret: cfg.JwtSecret,
+		app:     app,
+		port:    port,
+		db:      sqlxDB,
+		cfg:     cfg,
+		log:     cfg.Logger,
+		jwtKeys: cfg.Auth.Keys,
 	}
 }
 
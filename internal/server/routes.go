@@ -3,24 +3,19 @@ package server
 import (
 	"amg/internal/api/response"
 	"amg/internal/db"
+	"amg/internal/identity/accesscontrol"
+	"amg/internal/identity/accesscontrol/accesscontrolimpl"
+	"amg/internal/identity/organization/organizationimpl"
 	"amg/internal/identity/protocol/rest"
+	"amg/internal/identity/reports/reportsimpl"
 	"amg/internal/identity/user/userimpl"
 	"amg/internal/middleware"
+	"amg/internal/scheduler/schedulerimpl"
 	"errors"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-var (
-	requireCreateUser = middleware.RequirePermission("create")
-	requireReadUser   = middleware.RequirePermission("read")
-	requireUpdateUser = middleware.RequirePermission("update")
-	requireDeleteUser = middleware.RequirePermission("delete")
-
-	reqOnlyByAdmin      = middleware.RequireRole("admin")
-	reqBothUserAndAdmin = middleware.RequireRole("user", "admin")
-)
-
 func healthCheck(db db.DB) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		var result int64
@@ -36,25 +31,174 @@ func healthCheck(db db.DB) fiber.Handler {
 }
 
 func (s *Server) SetupRoutes() {
+	// Public keys for services verifying our tokens themselves
+	s.app.Get("/.well-known/jwks.json", rest.NewJWKSHandler(s.jwtKeys).GetJWKS)
+
 	api := s.app.Group("/api")
 	api.Get("/health", healthCheck(s.db))
 
+	access := accesscontrolimpl.NewService(s.db, s.cfg)
+	require := func(permission string) fiber.Handler {
+		return middleware.RequirePermission(access, permission)
+	}
+	// API keys only reach routes that check a permission against their scopes;
+	// every other route behind the login is sessionOnly
+	sessionOnly := middleware.RequireSession()
+
 	// User Routes
 
-	user := userimpl.NewService(s.db, s.cfg)
-	userHttp := rest.NewUserHandler(user)
+	user := userimpl.NewService(s.db, s.cfg, access)
+	userHttp := rest.NewUserHandler(user, access)
+
+	// Roles, permissions, report templates and the MFA policy are shared by all
+	// organizations; only the system organization changes them
+	organizations := organizationimpl.NewService(s.db, s.cfg, access, user)
+	systemOnly := middleware.RequireSystemOrganization(organizations, s.cfg.Auth.SystemOrganization)
 
 	api.Post("/users/register", userHttp.RegisterDefaultUser)
 	api.Post("/users/login", userHttp.LoginUser)
+	api.Post("/users/login/mfa", userHttp.LoginMFA)
+	api.Post("/users/login/mfa/enroll", userHttp.EnrollMFALogin)
+	api.Get("/users/oidc/authorize", userHttp.OIDCAuthorize)
+	api.Post("/users/oidc/callback", userHttp.OIDCCallback)
+	api.Post("/users/token/refresh", userHttp.RefreshToken)
+	api.Post("/users/password/forgot", userHttp.ForgotPassword)
+	api.Post("/users/password/reset", userHttp.ResetPassword)
+	api.Post("/users/verify-email", userHttp.VerifyEmail)
+	api.Post("/users/invitations/accept", userHttp.AcceptInvitation)
 
-	api.Use(middleware.JWTProtected(s.jwtSecret, user))
-	api.Post("/users", reqOnlyByAdmin, requireCreateUser, userHttp.CreateUser)
-	api.Get("/users", reqBothUserAndAdmin, requireReadUser, userHttp.SearchUser)
-	api.Get("/users/:id", reqBothUserAndAdmin, requireReadUser, userHttp.GetByUserID)
-	api.Put("/users/:id", reqOnlyByAdmin, requireUpdateUser, userHttp.UpdateUser)
-	api.Delete("/users/:id", reqOnlyByAdmin, requireDeleteUser, userHttp.DeleteUser)
+	api.Use(middleware.JWTProtected(s.jwtKeys, user))
+	api.Get("/users", require(accesscontrol.PermissionReadUser), userHttp.SearchUser)
+	api.Get("/users/me", sessionOnly, userHttp.GetMe)
+	api.Put("/users/me", sessionOnly, userHttp.UpdateMe)
+	api.Post("/users/me/verify-email", sessionOnly, userHttp.ResendVerificationEmail)
+	api.Post("/users/me/password", sessionOnly, userHttp.ChangePassword)
+	api.Post("/users/me/organization", sessionOnly, userHttp.SwitchOrganization)
+	api.Get("/users/me/sessions", sessionOnly, userHttp.GetMySessions)
+	api.Delete("/users/me/sessions/:sessionID", sessionOnly, userHttp.RevokeMySession)
+	api.Get("/users/me/mfa", sessionOnly, userHttp.GetMyMFA)
+	api.Post("/users/me/mfa/totp", sessionOnly, userHttp.EnrollTOTP)
+	api.Post("/users/me/mfa/totp/confirm", sessionOnly, userHttp.ConfirmTOTP)
+	api.Delete("/users/me/mfa/totp", sessionOnly, userHttp.DisableTOTP)
+	api.Post("/users/me/mfa/recovery-codes", sessionOnly, userHttp.RegenerateRecoveryCodes)
+	api.Get("/users/me/api-keys", sessionOnly, userHttp.GetMyAPIKeys)
+	api.Post("/users/me/api-keys", sessionOnly, userHttp.CreateMyAPIKey)
+	api.Delete("/users/me/api-keys/:keyID", sessionOnly, userHttp.RevokeMyAPIKey)
+	api.Get("/users/mfa/policy", require(accesscontrol.PermissionManageMFA), userHttp.GetMFAPolicy)
+	api.Put("/users/mfa/policy", require(accesscontrol.PermissionManageMFA), systemOnly, userHttp.UpdateMFAPolicy)
+	api.Get("/users/:id", require(accesscontrol.PermissionReadUser), userHttp.GetByUserID)
+	api.Put("/users/:id", require(accesscontrol.PermissionUpdateUser), userHttp.UpdateUser)
+	api.Delete("/users/:id", require(accesscontrol.PermissionDeleteUser), userHttp.DeleteUser)
+	api.Get("/users/:id/sessions", require(accesscontrol.PermissionManageSessions), userHttp.GetUserSessions)
+	api.Delete("/users/:id/sessions", require(accesscontrol.PermissionManageSessions), userHttp.RevokeUserSessions)
+	api.Post("/users/:id/unlock", require(accesscontrol.PermissionUnlockUser), userHttp.UnlockUser)
+	api.Get("/users/:id/api-keys", sessionOnly, require(accesscontrol.PermissionManageAPIKeys), userHttp.GetUserAPIKeys)
+	api.Post("/users/:id/api-keys", sessionOnly, require(accesscontrol.PermissionManageAPIKeys), userHttp.CreateUserAPIKey)
+	api.Delete("/users/:id/api-keys/:keyID", sessionOnly, require(accesscontrol.PermissionManageAPIKeys), userHttp.RevokeUserAPIKey)
+
+	// Service Accounts
+	api.Get("/service-accounts", require(accesscontrol.PermissionManageServiceAccounts), userHttp.GetServiceAccounts)
+	api.Post("/service-accounts", sessionOnly, require(accesscontrol.PermissionManageServiceAccounts), userHttp.CreateServiceAccount)
+
+	// Invitations
+	requireManageInvitations := require(accesscontrol.PermissionManageInvitations)
+
+	api.Get("/invitations", requireManageInvitations, userHttp.GetInvitations)
+	api.Post("/invitations", requireManageInvitations, userHttp.CreateInvitation)
+	api.Post("/invitations/:id/resend", requireManageInvitations, userHttp.ResendInvitation)
+	api.Delete("/invitations/:id", requireManageInvitations, userHttp.RevokeInvitation)
 
 	// Logout
-	api.Post("/users/logout", userHttp.LogoutUser)
+	api.Post("/users/logout", sessionOnly, userHttp.LogoutUser)
+
+	// Organizations
+
+	organizationsHttp := rest.NewOrganizationHandler(organizations)
+	requireManageOrganization := require(accesscontrol.PermissionManageOrganization)
+
+	api.Get("/users/me/organizations", sessionOnly, organizationsHttp.GetMyOrganizations)
+	api.Post("/organizations", sessionOnly, require(accesscontrol.PermissionCreateOrganization), organizationsHttp.CreateOrganization)
+	api.Get("/organization", sessionOnly, organizationsHttp.GetOrganization)
+	api.Put("/organization", requireManageOrganization, organizationsHttp.UpdateOrganization)
+	api.Get("/organization/members", requireManageOrganization, organizationsHttp.GetMembers)
+	api.Put("/organization/members/:userID", requireManageOrganization, organizationsHttp.UpdateMember)
+	api.Delete("/organization/members/:userID", requireManageOrganization, organizationsHttp.RemoveMember)
+
+	// Roles and Permissions
+
+	accessHttp := rest.NewAccessControlHandler(access)
+	requireManageRoles := require(accesscontrol.PermissionManageRoles)
+
+	api.Get("/roles", requireManageRoles, accessHttp.GetRoles)
+	api.Post("/roles", requireManageRoles, systemOnly, accessHttp.CreateRole)
+	api.Get("/roles/:name", requireManageRoles, accessHttp.GetRole)
+	api.Put("/roles/:name", requireManageRoles, systemOnly, accessHttp.UpdateRole)
+	api.Delete("/roles/:name", requireManageRoles, systemOnly, accessHttp.DeleteRole)
+	api.Put("/roles/:name/permissions", requireManageRoles, systemOnly, accessHttp.SetRolePermissions)
+	api.Post("/roles/:name/permissions/:permission", requireManageRoles, systemOnly, accessHttp.GrantPermission)
+	api.Delete("/roles/:name/permissions/:permission", requireManageRoles, systemOnly, accessHttp.RevokePermission)
+	api.Get("/permissions", requireManageRoles, accessHttp.GetPermissions)
+	api.Post("/permissions", requireManageRoles, systemOnly, accessHttp.CreatePermission)
+	api.Delete("/permissions/:permission", requireManageRoles, systemOnly, accessHttp.DeletePermission)
+
+	// Reports Routes
+
+	reports := reportsimpl.NewService(s.db, s.cfg)
+	reportsHttp := rest.NewReportsHandler(reports, user, access)
+
+	api.Post("/reports", require(accesscontrol.PermissionCreateReport), reportsHttp.CreateReport)
+	api.Get("/reports", require(accesscontrol.PermissionReadReport), reportsHttp.SearchReport)
+	api.Get("/reports/export", require(accesscontrol.PermissionReadReport), reportsHttp.ExportReports)
+	api.Get("/reports/stats", require(accesscontrol.PermissionReportStats), reportsHttp.GetReportStats)
+	api.Get("/reports/:id", require(accesscontrol.PermissionReadReport), reportsHttp.GetByReportID)
+	api.Put("/reports/:id", require(accesscontrol.PermissionUpdateReport), reportsHttp.UpdateReport)
+	api.Delete("/reports/:id", require(accesscontrol.PermissionDeleteReport), reportsHttp.DeleteReport)
+
+	// Report Lifecycle
+	api.Post("/reports/:id/submit", require(accesscontrol.PermissionSubmitReport), reportsHttp.SubmitReport)
+	api.Post("/reports/:id/review", require(accesscontrol.PermissionReviewReport), reportsHttp.ReviewReport)
+	api.Post("/reports/:id/approve", require(accesscontrol.PermissionApproveReport), reportsHttp.ApproveReport)
+	api.Post("/reports/:id/reject", require(accesscontrol.PermissionRejectReport), reportsHttp.RejectReport)
+	api.Get("/reports/:id/history", require(accesscontrol.PermissionReadReport), reportsHttp.GetReportHistory)
+	api.Get("/reports/:id/export", require(accesscontrol.PermissionReadReport), reportsHttp.ExportReport)
+
+	// Report Revisions
+	api.Get("/reports/:id/revisions", require(accesscontrol.PermissionReadReport), reportsHttp.GetRevisions)
+	api.Get("/reports/:id/revisions/:rev/diff", require(accesscontrol.PermissionReadReport), reportsHttp.DiffRevisions)
+	api.Post("/reports/:id/revisions/:rev/restore", require(accesscontrol.PermissionUpdateReport), reportsHttp.RestoreRevision)
+
+	// Report Attachments
+	api.Post("/reports/:id/attachments", require(accesscontrol.PermissionUpdateReport), reportsHttp.UploadAttachment)
+	api.Get("/reports/:id/attachments", require(accesscontrol.PermissionReadReport), reportsHttp.GetAttachments)
+	api.Get("/reports/:id/attachments/:attachmentID", require(accesscontrol.PermissionReadReport), reportsHttp.DownloadAttachment)
+	api.Delete("/reports/:id/attachments/:attachmentID", require(accesscontrol.PermissionUpdateReport), reportsHttp.DeleteAttachment)
+
+	// Report Comments
+	api.Get("/reports/:id/comments", require(accesscontrol.PermissionReadReport), reportsHttp.SearchComment)
+	api.Post("/reports/:id/comments", require(accesscontrol.PermissionCommentReport), reportsHttp.CreateComment)
+	api.Put("/reports/:id/comments/:commentID", require(accesscontrol.PermissionCommentReport), reportsHttp.UpdateComment)
+	api.Delete("/reports/:id/comments/:commentID", require(accesscontrol.PermissionCommentReport), reportsHttp.DeleteComment)
+
+	// Report Template Routes
+
+	templatesHttp := rest.NewReportTemplatesHandler(reports)
+
+	api.Post("/report-templates", require(accesscontrol.PermissionManageTemplates), systemOnly, templatesHttp.CreateTemplate)
+	api.Get("/report-templates", require(accesscontrol.PermissionReadReport), templatesHttp.SearchTemplate)
+	api.Get("/report-templates/:id", require(accesscontrol.PermissionReadReport), templatesHttp.GetByTemplateID)
+	api.Put("/report-templates/:id", require(accesscontrol.PermissionManageTemplates), systemOnly, templatesHttp.UpdateTemplate)
+	api.Delete("/report-templates/:id", require(accesscontrol.PermissionManageTemplates), systemOnly, templatesHttp.DeleteTemplate)
+
+	// Schedule Routes
+
+	schedules := schedulerimpl.NewService(s.db, s.cfg)
+	schedulesHttp := rest.NewSchedulesHandler(schedules)
 
+	api.Post("/schedules", require(accesscontrol.PermissionManageSchedules), schedulesHttp.CreateSchedule)
+	api.Get("/schedules", require(accesscontrol.PermissionManageSchedules), schedulesHttp.SearchSchedule)
+	api.Get("/schedules/:id", require(accesscontrol.PermissionManageSchedules), schedulesHttp.GetByScheduleID)
+	api.Put("/schedules/:id", require(accesscontrol.PermissionManageSchedules), schedulesHttp.UpdateSchedule)
+	api.Delete("/schedules/:id", require(accesscontrol.PermissionManageSchedules), schedulesHttp.DeleteSchedule)
+	api.Get("/schedules/:id/runs", require(accesscontrol.PermissionManageSchedules), schedulesHttp.SearchRun)
+	api.Get("/schedules/:id/runs/:runID", require(accesscontrol.PermissionManageSchedules), schedulesHttp.GetByRunID)
 }
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Blob stores opaque objects under slash separated keys such as "reports/1/abc"
+type Blob interface {
+	// Put stores the content of r under key, replacing any existing object,
+	// and returns the number of bytes written
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+
+	// Get opens the object stored under key. The caller must close it.
+	// It returns ErrNotFound when there is no such object.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores blobs as files below a root directory
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) (*Local, error) {
+	err := os.MkdirAll(root, 0o750)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Local{
+		root: root,
+	}, nil
+}
+
+// path maps a key to a file below root, rejecting keys that would escape it
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(l.root, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o750)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, &contextReader{ctx: ctx, r: r})
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.Rename(tmp.Name(), name)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// contextReader stops a copy once the context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// Memory keeps blobs in a map. It is meant for tests and local experiments.
+type Memory struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		blobs: make(map[string][]byte),
+	}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	if key == "" {
+		return 0, ErrInvalidKey
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	m.blobs[key] = data
+	m.mu.Unlock()
+
+	return int64(len(data)), nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	data, ok := m.blobs[key]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.blobs, key)
+	m.mu.Unlock()
+
+	return nil
+}
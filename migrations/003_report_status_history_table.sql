@@ -0,0 +1,11 @@
+CREATE TABLE report_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    from_status VARCHAR(50) NOT NULL,
+    to_status VARCHAR(50) NOT NULL,
+    actor_id BIGINT NOT NULL REFERENCES users(id),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_report_status_history_report_id ON report_status_history(report_id);
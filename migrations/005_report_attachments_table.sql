@@ -0,0 +1,12 @@
+CREATE TABLE report_attachments (
+    id BIGSERIAL PRIMARY KEY,
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) UNIQUE NOT NULL,
+    uploaded_by BIGINT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_report_attachments_report_id ON report_attachments(report_id);
@@ -0,0 +1,12 @@
+CREATE TABLE report_comments (
+    id BIGSERIAL PRIMARY KEY,
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    parent_id BIGINT REFERENCES report_comments(id) ON DELETE CASCADE,
+    author_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_report_comments_report_id ON report_comments(report_id, created_at);
+CREATE INDEX idx_report_comments_parent_id ON report_comments(parent_id);
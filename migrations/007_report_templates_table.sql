@@ -0,0 +1,13 @@
+CREATE TABLE report_templates (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    schema JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE reports
+    ADD COLUMN template_id BIGINT REFERENCES report_templates(id) ON DELETE RESTRICT;
+
+CREATE INDEX idx_reports_template_id ON reports(template_id);
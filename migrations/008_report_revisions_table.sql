@@ -0,0 +1,14 @@
+CREATE TABLE report_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    editor_id BIGINT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (report_id, revision)
+);
+
+-- Existing reports start their history with their current content
+INSERT INTO report_revisions (report_id, revision, title, body, editor_id, created_at)
+SELECT id, 1, title, body, author_id, updated_at FROM reports;
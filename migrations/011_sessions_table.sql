@@ -0,0 +1,29 @@
+CREATE TABLE sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+
+-- Every existing refresh token family is a login and becomes a session
+INSERT INTO sessions (id, user_id, created_at, last_seen_at, expires_at, revoked_at)
+SELECT
+    family_id,
+    user_id,
+    MIN(created_at),
+    MAX(created_at),
+    MAX(expires_at),
+    CASE WHEN bool_and(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM
+    refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT fk_refresh_tokens_session
+    FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
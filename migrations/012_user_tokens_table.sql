@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Accounts created before verification existed are trusted as they are
+UPDATE users SET email_verified_at = created_at;
+
+CREATE TABLE user_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_tokens_user_id ON user_tokens(user_id, purpose);
@@ -0,0 +1,14 @@
+-- Accounts at external OpenID Connect issuers linked to users. The subject is the
+-- stable id of the account at its issuer; the email is kept for reference only.
+CREATE TABLE user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
@@ -0,0 +1,20 @@
+-- Service accounts are users that cannot log in and only act through API keys
+ALTER TABLE users ADD COLUMN service_account BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- API keys act as their owner, limited to the permissions in scopes. Only the
+-- hash of a key is stored; the prefix identifies it in listings and logs.
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
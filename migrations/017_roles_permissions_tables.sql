@@ -0,0 +1,82 @@
+-- Roles and permissions used to be hardcoded. Built-in rows are the ones the
+-- code refers to by name and cannot be deleted.
+CREATE TABLE roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    builtin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Permissions are resource:action strings such as reports:approve
+CREATE TABLE permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    builtin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description, builtin) VALUES
+    ('admin', 'Full access', TRUE),
+    ('user', 'Writes and submits reports', TRUE),
+    ('reviewer', 'Reviews submitted reports', TRUE);
+
+INSERT INTO permissions (name, description, builtin) VALUES
+    ('users:create', 'Create users', TRUE),
+    ('users:read', 'Search and view users', TRUE),
+    ('users:update', 'Edit users', TRUE),
+    ('users:delete', 'Delete users', TRUE),
+    ('users:unlock', 'Lift login lockouts', TRUE),
+    ('sessions:manage', 'List and revoke the sessions of other users', TRUE),
+    ('api_keys:manage', 'Manage the API keys of other users', TRUE),
+    ('service_accounts:manage', 'Create and list service accounts', TRUE),
+    ('mfa:manage', 'Set which roles require two-factor authentication', TRUE),
+    ('roles:manage', 'Manage roles and permissions', TRUE),
+    ('reports:create', 'Create reports', TRUE),
+    ('reports:read', 'View reports', TRUE),
+    ('reports:update', 'Edit reports', TRUE),
+    ('reports:delete', 'Delete reports', TRUE),
+    ('reports:submit', 'Submit reports for review', TRUE),
+    ('reports:review', 'Start reviewing reports', TRUE),
+    ('reports:approve', 'Approve reports', TRUE),
+    ('reports:reject', 'Reject reports', TRUE),
+    ('reports:comment', 'Comment on reports', TRUE),
+    ('reports:stats', 'View report statistics', TRUE),
+    ('report_templates:manage', 'Manage report templates', TRUE),
+    ('schedules:manage', 'Manage report schedules', TRUE);
+
+-- The previous hardcoded assignments
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', name FROM permissions;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user', 'users:read'),
+    ('user', 'reports:create'),
+    ('user', 'reports:read'),
+    ('user', 'reports:update'),
+    ('user', 'reports:submit'),
+    ('user', 'reports:comment'),
+    ('reviewer', 'reports:read'),
+    ('reviewer', 'reports:review'),
+    ('reviewer', 'reports:approve'),
+    ('reviewer', 'reports:reject'),
+    ('reviewer', 'reports:comment');
+
+ALTER TABLE users
+    ADD CONSTRAINT fk_users_role FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
+
+ALTER TABLE mfa_required_roles
+    ADD CONSTRAINT fk_mfa_required_roles_role FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- API keys scoped to the old global verbs get the users permissions they stood for
+UPDATE api_keys SET scopes = array_replace(scopes, 'create', 'users:create');
+UPDATE api_keys SET scopes = array_replace(scopes, 'read', 'users:read');
+UPDATE api_keys SET scopes = array_replace(scopes, 'update', 'users:update');
+UPDATE api_keys SET scopes = array_replace(scopes, 'delete', 'users:delete');
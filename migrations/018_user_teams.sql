@@ -0,0 +1,5 @@
+-- Teams group users for attribute-based authorization, e.g. reviewers only see
+-- the reports of their own team. Users without a team share the empty one.
+ALTER TABLE users ADD COLUMN team VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_users_team ON users(team);
@@ -0,0 +1,50 @@
+-- Organizations are tenants: users and reports of one are invisible to the
+-- others. Users may belong to several organizations with a role in each; a
+-- session acts in one of them. Roles, permissions and report templates stay
+-- shared by all organizations.
+CREATE TABLE organizations (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organization_members (
+    organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON UPDATE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+-- Everything that exists so far belongs to one organization, with the roles
+-- users had before
+INSERT INTO organizations (name, slug) VALUES ('Default', 'default');
+
+INSERT INTO organization_members (organization_id, user_id, role)
+SELECT (SELECT id FROM organizations WHERE slug = 'default'), id, role FROM users;
+
+ALTER TABLE reports ADD COLUMN organization_id BIGINT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE reports SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+ALTER TABLE reports ALTER COLUMN organization_id SET NOT NULL;
+
+CREATE INDEX idx_reports_organization_id ON reports(organization_id);
+
+ALTER TABLE sessions ADD COLUMN organization_id BIGINT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE sessions SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+ALTER TABLE sessions ALTER COLUMN organization_id SET NOT NULL;
+
+ALTER TABLE api_keys ADD COLUMN organization_id BIGINT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE api_keys SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+ALTER TABLE api_keys ALTER COLUMN organization_id SET NOT NULL;
+
+INSERT INTO permissions (name, description, builtin) VALUES
+    ('organizations:create', 'Create organizations', TRUE),
+    ('organizations:manage', 'Rename the organization and manage its members', TRUE);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'organizations:create'),
+    ('admin', 'organizations:manage');
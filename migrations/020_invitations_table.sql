@@ -0,0 +1,27 @@
+-- Invitations let admins add people to an organization without choosing a
+-- password for them. The link mailed to the invitee is a signed token naming
+-- the invitation; token_hash holds the hash of its jti so resending an
+-- invitation invalidates the previous link. A role in use by a pending
+-- invitation cannot be deleted; answered invitations lose theirs.
+CREATE TABLE invitations (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(50) REFERENCES roles(name) ON UPDATE CASCADE ON DELETE SET NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    invited_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    accepted_at TIMESTAMP,
+    accepted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_invitations_organization_id ON invitations(organization_id, email);
+
+INSERT INTO permissions (name, description, builtin) VALUES
+    ('invitations:manage', 'Invite users to the organization and manage pending invitations', TRUE);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'invitations:manage');
@@ -0,0 +1,11 @@
+-- Schedules belong to an organization and summarise only its data, like
+-- reports. Existing schedules and their runs move to the default organization.
+ALTER TABLE schedules ADD COLUMN organization_id BIGINT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE schedules SET organization_id = (SELECT id FROM organizations WHERE slug = 'default');
+ALTER TABLE schedules ALTER COLUMN organization_id SET NOT NULL;
+
+CREATE INDEX idx_schedules_organization_id ON schedules(organization_id);
+
+ALTER TABLE schedule_runs ADD COLUMN organization_id BIGINT REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE schedule_runs r SET organization_id = s.organization_id FROM schedules s WHERE s.id = r.schedule_id;
+ALTER TABLE schedule_runs ALTER COLUMN organization_id SET NOT NULL;
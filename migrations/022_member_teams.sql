@@ -0,0 +1,10 @@
+-- A team belongs to a membership, like the role: admins of one organization
+-- must not move a user between the teams of another. Members keep the team
+-- their account had in every organization they belong to.
+ALTER TABLE organization_members ADD COLUMN team VARCHAR(100) NOT NULL DEFAULT '';
+UPDATE organization_members m SET team = u.team FROM users u WHERE u.id = m.user_id;
+
+CREATE INDEX idx_organization_members_team ON organization_members(organization_id, team);
+
+DROP INDEX idx_users_team;
+ALTER TABLE users DROP COLUMN team;
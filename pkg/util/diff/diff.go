@@ -0,0 +1,145 @@
+package diff
+
+import "strings"
+
+// MaxEdits bounds the edit distance the diff searches for; beyond it the texts
+// are reported as entirely replaced
+const MaxEdits = 4000
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Line is one line of a line-level diff. OldLine and NewLine are 1-based line
+// numbers in the old and new text, zero when the line does not appear there.
+type Line struct {
+	Op      string `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+}
+
+// splitLines splits text into lines without their terminators. An empty text has no lines.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Lines computes a minimal line diff turning oldText into newText using Myers'
+// O((N+M)D) algorithm
+func Lines(oldText, newText string) []Line {
+	a, b := splitLines(oldText), splitLines(newText)
+	n, m := len(a), len(b)
+	max := n + m
+
+	if max == 0 {
+		return []Line{}
+	}
+
+	// Very different texts are reported as a full replacement rather than
+	// spending quadratic time and memory on a diff nobody can read
+	if max > MaxEdits {
+		max = MaxEdits
+	}
+
+	// v[k+offset] is the furthest x reached on diagonal k. trace keeps, per d,
+	// the diagonals -d-1..d+1 that backtrack reads, indexed by k+d+1.
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	trace := make([][]int, 0)
+
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d)
+			}
+		}
+	}
+
+	return replace(a, b)
+}
+
+// replace is the trivial diff deleting every old line and inserting every new one
+func replace(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+
+	for i, text := range a {
+		lines = append(lines, Line{Op: OpDelete, Text: text, OldLine: i + 1})
+	}
+	for i, text := range b {
+		lines = append(lines, Line{Op: OpInsert, Text: text, NewLine: i + 1})
+	}
+
+	return lines
+}
+
+// backtrack walks the saved frontiers from the end back to the start to recover
+// the edit script
+func backtrack(a, b []string, trace [][]int, depth int) []Line {
+	x, y := len(a), len(b)
+	reversed := make([]Line, 0, x+y)
+
+	for d := depth; d >= 0; d-- {
+		v, offset := trace[d], d+1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Line{Op: OpEqual, Text: a[x-1], OldLine: x, NewLine: y})
+			x--
+			y--
+		}
+
+		if d == 0 {
+			break
+		}
+
+		if x == prevX {
+			reversed = append(reversed, Line{Op: OpInsert, Text: b[y-1], NewLine: y})
+		} else {
+			reversed = append(reversed, Line{Op: OpDelete, Text: a[x-1], OldLine: x})
+		}
+
+		x, y = prevX, prevY
+	}
+
+	lines := make([]Line, len(reversed))
+	for i, line := range reversed {
+		lines[len(reversed)-1-i] = line
+	}
+
+	return lines
+}
@@ -0,0 +1,157 @@
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// script renders a diff compactly, one "<op><text>" entry per line with
+// " " for equal, "-" for delete and "+" for insert
+func script(lines []Line) []string {
+	ops := map[string]string{OpEqual: " ", OpDelete: "-", OpInsert: "+"}
+
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, ops[line.Op]+line.Text)
+	}
+
+	return result
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{"both empty", "", "", []string{}},
+		{"equal", "a\nb\n", "a\nb\n", []string{" a", " b"}},
+		{"from empty", "", "a\nb", []string{"+a", "+b"}},
+		{"to empty", "a\nb", "", []string{"-a", "-b"}},
+		{"changed line", "a\nb\nc", "a\nx\nc", []string{" a", "-b", "+x", " c"}},
+		{"inserted line", "a\nc", "a\nb\nc", []string{" a", "+b", " c"}},
+		{"deleted line", "a\nb\nc", "a\nc", []string{" a", "-b", " c"}},
+		{"appended line", "a", "a\nb", []string{" a", "+b"}},
+		{"trailing newline is not a line", "a\n", "a", []string{" a"}},
+		{"CRLF equals LF", "a\r\nb\r\n", "a\nb\n", []string{" a", " b"}},
+		{"blank lines count", "a\n\nb", "a\nb", []string{" a", "-", " b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := script(Lines(tt.old, tt.new))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Lines(%q, %q) = %q, want %q", tt.old, tt.new, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesNumbers(t *testing.T) {
+	got := Lines("a\nb\nc", "a\nx\nc\nd")
+	want := []Line{
+		{Op: OpEqual, Text: "a", OldLine: 1, NewLine: 1},
+		{Op: OpDelete, Text: "b", OldLine: 2},
+		{Op: OpInsert, Text: "x", NewLine: 2},
+		{Op: OpEqual, Text: "c", OldLine: 3, NewLine: 3},
+		{Op: OpInsert, Text: "d", NewLine: 4},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// Random texts over a small alphabet must diff into a script that rebuilds both
+// sides with as few edits as their longest common subsequence allows
+func TestLinesIsMinimal(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	text := func() []string {
+		lines := make([]string, random.Intn(30))
+		for i := range lines {
+			lines[i] = strconv.Itoa(random.Intn(4))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := text(), text()
+		lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+		var oldSide, newSide []string
+		edits := 0
+
+		for _, line := range lines {
+			if line.Op != OpInsert {
+				oldSide = append(oldSide, line.Text)
+				if line.OldLine != len(oldSide) {
+					t.Fatalf("%q -> %q: line %+v has the wrong old line number", a, b, line)
+				}
+			}
+			if line.Op != OpDelete {
+				newSide = append(newSide, line.Text)
+				if line.NewLine != len(newSide) {
+					t.Fatalf("%q -> %q: line %+v has the wrong new line number", a, b, line)
+				}
+			}
+			if line.Op != OpEqual {
+				edits++
+			}
+		}
+
+		if !reflect.DeepEqual(oldSide, a) && len(a)+len(oldSide) > 0 {
+			t.Fatalf("%q -> %q: old side rebuilt as %q", a, b, oldSide)
+		}
+		if !reflect.DeepEqual(newSide, b) && len(b)+len(newSide) > 0 {
+			t.Fatalf("%q -> %q: new side rebuilt as %q", a, b, newSide)
+		}
+
+		if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+			t.Fatalf("%q -> %q: %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+func TestLinesReplacesBeyondMaxEdits(t *testing.T) {
+	a := make([]string, MaxEdits)
+	b := make([]string, MaxEdits)
+	for i := range a {
+		a[i] = "old " + strconv.Itoa(i)
+		b[i] = "new " + strconv.Itoa(i)
+	}
+
+	lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	if len(lines) != 2*MaxEdits {
+		t.Fatalf("expected %d lines, got %d", 2*MaxEdits, len(lines))
+	}
+
+	for i, line := range lines {
+		if (i < MaxEdits && line.Op != OpDelete) || (i >= MaxEdits && line.Op != OpInsert) {
+			t.Fatalf("line %d is %+v, expected every old line deleted then every new line inserted", i, line)
+		}
+	}
+}
+
+// lcs is the length of the longest common subsequence of a and b
+func lcs(a, b []string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	return table[0][0]
+}
@@ -1,44 +1,91 @@
 package jwt
 
 import (
-	"os"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var ErrInvalidSubject = errors.New("jwt: subject is not a user id")
+
 type Claims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    int64  `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	// OrganizationID is the organization the token acts in
+	OrganizationID int64 `json:"org_id"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID string, role string) (string, error) {
+// GenerateToken issues an access token for the user that expires after ttl,
+// signed with the current signing key. The subject is the user id, sid names the
+// login session, org_id the organization it acts in and every token gets a
+// unique jti.
+func (ks *KeySet) GenerateToken(userID int64, role string, organizationID int64, sessionID string, ttl time.Duration) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:         userID,
+		Role:           role,
+		SessionID:      sessionID,
+		OrganizationID: organizationID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires after 24 hours
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   strconv.FormatInt(userID, 10),
+			Issuer:    ks.Issuer,
+			Audience:  jwt.ClaimStrings{ks.Audience},
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+
+	return token.SignedString(ks.signing.Private)
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// ValidateToken verifies the signature, expiry, issuer and audience of a token and
+// checks that its subject matches the user id claim
+func (ks *KeySet) ValidateToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, ks.keyFunc,
+		jwt.WithIssuer(ks.Issuer),
+		jwt.WithAudience(ks.Audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
-	} else {
-		return nil, err
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	if claims.UserID <= 0 || claims.Subject != strconv.FormatInt(claims.UserID, 10) {
+		return nil, ErrInvalidSubject
+	}
+
+	return claims, nil
+}
+
+func newTokenID() (string, error) {
+	buf := make([]byte, 16)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
 	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
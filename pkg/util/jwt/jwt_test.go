@@ -0,0 +1,284 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "amg"
+	testAudience = "amg-api"
+)
+
+func newRSAKey(t *testing.T) (*Key, []byte) {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParseKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)}), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+}
+
+func newEd25519Key(t *testing.T, id string) *Key {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParseKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newTestKeySet(t *testing.T, signing *Key, verify ...*Key) *KeySet {
+	t.Helper()
+
+	ks, err := NewKeySet(signing, verify...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Issuer = testIssuer
+	ks.Audience = testAudience
+
+	return ks
+}
+
+// publicOnly drops the private half, as for a key kept only to verify
+func publicOnly(key *Key) *Key {
+	return &Key{ID: key.ID, Method: key.Method, Public: key.Public}
+}
+
+// validClaims are the claims GenerateToken would issue for user 1
+func validClaims() *Claims {
+	now := time.Now()
+
+	return &Claims{
+		UserID:         1,
+		Role:           "admin",
+		SessionID:      "session",
+		OrganizationID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "1",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			ID:        "jti",
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestGenerateAndValidateToken(t *testing.T) {
+	rsaKey, _ := newRSAKey(t)
+
+	for _, ks := range []*KeySet{
+		newTestKeySet(t, rsaKey),
+		newTestKeySet(t, newEd25519Key(t, "")),
+		func() *KeySet {
+			ks := NewHMACKeySet("secret")
+			ks.Issuer, ks.Audience = testIssuer, testAudience
+			return ks
+		}(),
+	} {
+		token, err := ks.GenerateToken(42, "user", 7, "session", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := ks.ValidateToken(token)
+		if err != nil {
+			t.Fatalf("%s: %v", ks.signing.Method.Alg(), err)
+		}
+
+		if claims.UserID != 42 || claims.Role != "user" || claims.OrganizationID != 7 || claims.SessionID != "session" || claims.ID == "" {
+			t.Fatalf("%s: unexpected claims %+v", ks.signing.Method.Alg(), claims)
+		}
+	}
+}
+
+func TestValidateTokenPicksKeyByKid(t *testing.T) {
+	previous := newEd25519Key(t, "previous")
+	current := newEd25519Key(t, "current")
+	stranger := newEd25519Key(t, "stranger")
+
+	ks := newTestKeySet(t, current, publicOnly(previous))
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"current key", sign(t, jwt.SigningMethodEdDSA, "current", current.Private, validClaims()), nil},
+		{"previous key during a rotation", sign(t, jwt.SigningMethodEdDSA, "previous", previous.Private, validClaims()), nil},
+		{"no kid falls back to the signing key", sign(t, jwt.SigningMethodEdDSA, "", current.Private, validClaims()), nil},
+		{"unknown kid", sign(t, jwt.SigningMethodEdDSA, "stranger", stranger.Private, validClaims()), ErrUnknownKey},
+		{"known kid, other key", sign(t, jwt.SigningMethodEdDSA, "current", stranger.Private, validClaims()), jwt.ErrTokenSignatureInvalid},
+		{"no kid, other key", sign(t, jwt.SigningMethodEdDSA, "", stranger.Private, validClaims()), jwt.ErrTokenSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ks.ValidateToken(tt.token)
+			if tt.want == nil && err != nil {
+				t.Fatalf("expected a valid token, got %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidateTokenRejectsAlgConfusion(t *testing.T) {
+	rsaKey, publicPEM := newRSAKey(t)
+	ks := newTestKeySet(t, rsaKey)
+
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256 signed with the public key PEM", sign(t, jwt.SigningMethodHS256, rsaKey.ID, publicPEM, validClaims())},
+		{"HS256 signed with the public key DER", sign(t, jwt.SigningMethodHS256, rsaKey.ID, publicDER, validClaims())},
+		{"HS256 without kid", sign(t, jwt.SigningMethodHS256, "", publicPEM, validClaims())},
+		{"alg none", unsigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ks.ValidateToken(tt.token)
+			if !errors.Is(err, jwt.ErrTokenUnverifiable) {
+				t.Fatalf("expected the token to be refused before verification, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateTokenChecksClaims(t *testing.T) {
+	key := newEd25519Key(t, "key")
+	ks := newTestKeySet(t, key)
+
+	tests := []struct {
+		name   string
+		change func(c *Claims)
+		want   error
+	}{
+		{"expired", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }, jwt.ErrTokenExpired},
+		{"without expiry", func(c *Claims) { c.ExpiresAt = nil }, jwt.ErrTokenRequiredClaimMissing},
+		{"wrong audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"another-api"} }, jwt.ErrTokenInvalidAudience},
+		{"invitation audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{testAudience + inviteAudienceSuffix} }, jwt.ErrTokenInvalidAudience},
+		{"wrong issuer", func(c *Claims) { c.Issuer = "someone-else" }, jwt.ErrTokenInvalidIssuer},
+		{"subject of another user", func(c *Claims) { c.Subject = "2" }, ErrInvalidSubject},
+		{"no user", func(c *Claims) { c.UserID, c.Subject = 0, "0" }, ErrInvalidSubject},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.change(claims)
+
+			_, err := ks.ValidateToken(sign(t, key.Method, key.ID, key.Private, claims))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestInviteTokensAreNotAccessTokens(t *testing.T) {
+	ks := newTestKeySet(t, newEd25519Key(t, "key"))
+
+	invite, err := ks.GenerateInviteToken(5, "nonce", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ks.ValidateInviteToken(invite)
+	if err != nil || claims.InvitationID != 5 || claims.ID != "nonce" {
+		t.Fatalf("expected invitation 5 with its nonce, got %+v, %v", claims, err)
+	}
+
+	_, err = ks.ValidateToken(invite)
+	if !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Fatalf("invitation accepted as access token: %v", err)
+	}
+
+	access, err := ks.GenerateToken(5, "user", 1, "session", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ks.ValidateInviteToken(access)
+	if !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Fatalf("access token accepted as invitation: %v", err)
+	}
+}
+
+func TestNewKeySetRefusesDuplicateKids(t *testing.T) {
+	key := newEd25519Key(t, "same")
+
+	_, err := NewKeySet(key, publicOnly(newEd25519Key(t, "same")))
+	if err == nil {
+		t.Fatal("expected duplicate key ids to be refused")
+	}
+
+	_, err = NewKeySet(publicOnly(key))
+	if err == nil {
+		t.Fatal("expected a signing key without private key to be refused")
+	}
+}
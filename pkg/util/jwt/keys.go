@@ -0,0 +1,271 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey     = errors.New("jwt: unknown signing key")
+	ErrUnsupportedKey = errors.New("jwt: unsupported key type, expected RSA or Ed25519")
+)
+
+// Key is a signing or verification key. Private is nil for keys that may only
+// verify tokens, e.g. the previous key during a rotation.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeySet signs tokens with one key and verifies them with any key it holds,
+// picked by the kid header, so keys can be rotated without invalidating the
+// tokens already issued
+type KeySet struct {
+	// Issuer and Audience are set as iss and aud on issued tokens and are
+	// required on validated ones
+	Issuer   string
+	Audience string
+
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet returns a key set signing with signing and additionally accepting
+// tokens signed by any of verify
+func NewKeySet(signing *Key, verify ...*Key) (*KeySet, error) {
+	if signing == nil || signing.Private == nil {
+		return nil, errors.New("jwt: signing key has no private key")
+	}
+
+	ks := &KeySet{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+
+	for _, key := range verify {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("jwt: duplicate key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	return ks, nil
+}
+
+// NewHMACKeySet returns a key set signing and verifying with a shared HS256 secret.
+// Such keys are never published in the JWKS.
+func NewHMACKeySet(secret string) *KeySet {
+	key := &Key{
+		ID:      "hs256",
+		Method:  jwt.SigningMethodHS256,
+		Private: []byte(secret),
+		Public:  []byte(secret),
+	}
+
+	return &KeySet{
+		signing: key,
+		keys:    map[string]*Key{key.ID: key},
+	}
+}
+
+// LoadKeyFile reads an RSA or Ed25519 key from a PEM file. Private keys can sign
+// and verify; public keys only verify. The key id is the RFC 7638 thumbprint of
+// the public key unless id is given.
+func LoadKeyFile(path, id string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKey(data, id)
+}
+
+// ParseKey parses a PEM encoded key, see LoadKeyFile
+func ParseKey(data []byte, id string) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM data found")
+	}
+
+	var (
+		parsed interface{}
+		err    error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("jwt: unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	if len(key.ID) == 0 {
+		key.ID, err = thumbprint(key.JWK())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// JWK is the public half of a key in JSON Web Key form
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public key as a JWK, or nil for symmetric keys
+func (k *Key) JWK() *JWK {
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return &JWK{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	}
+
+	return nil
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint: the hash of the required
+// members only, in lexicographic order
+func thumbprint(jwk *JWK) (string, error) {
+	if jwk == nil {
+		return "", ErrUnsupportedKey
+	}
+
+	var members interface{}
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// JWKS returns the public keys of the set for publishing
+func (ks *KeySet) JWKS() JWKS {
+	result := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+
+	// The signing key comes first, the remaining keys are only kept for verification
+	if jwk := ks.signing.JWK(); jwk != nil {
+		result.Keys = append(result.Keys, *jwk)
+	}
+
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		if id != ks.signing.ID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if jwk := ks.keys[id].JWK(); jwk != nil {
+			result.Keys = append(result.Keys, *jwk)
+		}
+	}
+
+	return result
+}
+
+// keyFunc picks the verification key named by the kid header and refuses tokens
+// whose algorithm does not match that key
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := ks.signing
+
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, ok = ks.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("jwt: unexpected signing method %s", token.Method.Alg())
+	}
+
+	return key.Public, nil
+}
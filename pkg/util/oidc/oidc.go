@@ -0,0 +1,383 @@
+// Package oidc is a small OpenID Connect relying party: discovery, the
+// authorization code flow with PKCE and ID token verification against the
+// JWKS of the issuer.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey    = errors.New("oidc: ID token signed with an unknown key")
+	ErrInvalidNonce  = errors.New("oidc: ID token nonce does not match")
+	ErrNoIDToken     = errors.New("oidc: token response has no id_token")
+	ErrIssuerMissing = errors.New("oidc: issuer is not configured")
+)
+
+// Config identifies this application to the issuer
+type Config struct {
+	// IssuerURL is the issuer identifier; its discovery document is read from
+	// IssuerURL/.well-known/openid-configuration
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// HTTPClient defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// Metadata is the part of the discovery document the flow needs
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse is the answer of the token endpoint
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+}
+
+// IDToken holds the verified claims of an ID token. Claims has all of them, for
+// mappings on provider specific claims such as groups.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+	Claims        map[string]interface{}
+}
+
+// Provider talks to one issuer. Discovery happens on first use, so the
+// application can start while the issuer is unreachable.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     map[string]interface{}
+	fetched  time.Time
+}
+
+// keyRefreshInterval limits how often an unknown kid triggers a JWKS download
+const keyRefreshInterval = time.Minute
+
+func NewProvider(cfg Config) (*Provider, error) {
+	if cfg.IssuerURL == "" {
+		return nil, ErrIssuerMissing
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc: client id and redirect url are required")
+	}
+
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Provider{cfg: cfg, client: client}, nil
+}
+
+// Issuer returns the configured issuer identifier. ID tokens are checked against
+// the issuer of the discovery document, which may differ in a trailing slash.
+func (p *Provider) Issuer() string {
+	return p.cfg.IssuerURL
+}
+
+// Metadata discovers the endpoints of the issuer once and caches them
+func (p *Provider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var metadata Metadata
+
+	issuer := strings.TrimRight(p.cfg.IssuerURL, "/")
+
+	err := p.getJSON(ctx, issuer+"/.well-known/openid-configuration", &metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenID Connect Discovery 1.0 section 4.3. The configured issuer may leave
+	// out a trailing slash; ID tokens must then carry the issuer exactly as
+	// discovered.
+	if strings.TrimRight(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", metadata.Issuer, p.cfg.IssuerURL)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// AuthCodeURL returns the URL to send the browser to. state and nonce must be
+// unguessable and remembered until the callback; challenge is the S256 PKCE
+// challenge of the verifier passed to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", challenge)
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (*TokenResponse, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.cfg.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	// Public clients rely on PKCE alone
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var token TokenResponse
+
+	err = p.do(req, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.IDToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	return &token, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an
+// ID token and returns its claims
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*IDToken, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err = jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	token := &IDToken{Claims: claims}
+	token.Issuer, _ = claims["iss"].(string)
+	token.Subject, _ = claims["sub"].(string)
+	token.Email, _ = claims["email"].(string)
+	token.GivenName, _ = claims["given_name"].(string)
+	token.FamilyName, _ = claims["family_name"].(string)
+	token.Name, _ = claims["name"].(string)
+
+	// Some issuers send the flag as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		token.EmailVerified = verified
+	case string:
+		token.EmailVerified = verified == "true"
+	}
+
+	if token.Subject == "" {
+		return nil, errors.New("oidc: ID token has no subject")
+	}
+
+	return token, nil
+}
+
+// key returns the verification key with the given id, downloading the JWKS
+// again when the issuer has rotated to a key not seen before
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+
+	if time.Since(p.fetched) < keyRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+
+	err = p.getJSON(ctx, metadata.JWKSURI, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys = make(map[string]interface{}, len(set.Keys))
+	p.fetched = time.Now()
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// Skip key types we cannot use rather than failing the whole set
+			continue
+		}
+		p.keys[jwk.Kid] = key
+	}
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// lookup finds a key by id; a token without kid is accepted only when the set
+// holds a single key
+func (p *Provider) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	return p.do(req, v)
+}
+
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return fmt.Errorf("oidc: %s: %s", req.URL.Path, strings.TrimSpace(e.Error+" "+e.Description))
+		}
+		return fmt.Errorf("oidc: %s: unexpected status %d", req.URL.Path, resp.StatusCode)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// NewVerifier returns a random PKCE code verifier (RFC 7636 section 4.1). It is
+// also suitable for state and nonce values.
+func NewVerifier() (string, error) {
+	buf := make([]byte, 32)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Challenge derives the S256 code challenge of a verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,310 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID    = "amg-test"
+	testRedirectURL = "http://localhost/callback"
+)
+
+// mockIssuer is a minimal OpenID provider: discovery, JWKS, an authorization
+// endpoint that logs everyone in and a token endpoint that checks PKCE
+type mockIssuer struct {
+	*httptest.Server
+
+	// issuer is the iss of the discovery document and of issued ID tokens
+	issuer string
+	key    ed25519.PrivateKey
+
+	// tamper may change the claims of an ID token before it is signed
+	tamper func(claims jwt.MapClaims)
+
+	mu    sync.Mutex
+	codes map[string]pendingCode
+}
+
+type pendingCode struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIssuer(t *testing.T, trailingSlash bool) *mockIssuer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{key: key, codes: make(map[string]pendingCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	m.issuer = m.URL
+	if trailingSlash {
+		m.issuer += "/"
+	}
+
+	return m
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 m.issuer,
+		"authorization_endpoint": m.URL + "/authorize",
+		"token_endpoint":         m.URL + "/token",
+		"jwks_uri":               m.URL + "/jwks",
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	public := m.key.Public().(ed25519.PublicKey)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []JWK{{
+			Kty: "OKP",
+			Kid: "test",
+			Use: "sig",
+			Alg: "EdDSA",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(public),
+		}},
+	})
+}
+
+// authorize redirects back with a code remembering the challenge and nonce
+func (m *mockIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	code, _ := NewVerifier()
+
+	m.mu.Lock()
+	m.codes[code] = pendingCode{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	m.mu.Unlock()
+
+	redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	pending, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || r.PostForm.Get("redirect_uri") != testRedirectURL {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	if Challenge(r.PostForm.Get("code_verifier")) != pending.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            m.issuer,
+		"aud":            testClientID,
+		"sub":            "user-1",
+		"nonce":          pending.nonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+	}
+	if m.tamper != nil {
+		m.tamper(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = "test"
+
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// login runs the browser part of the flow and returns the callback parameters
+func login(t *testing.T, p *Provider, state, nonce, verifier string) url.Values {
+	t.Helper()
+
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, Challenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize answered %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	if !strings.HasPrefix(location.String(), testRedirectURL) {
+		t.Fatalf("redirected to %q", location)
+	}
+
+	return location.Query()
+}
+
+func newTestProvider(t *testing.T, m *mockIssuer) *Provider {
+	t.Helper()
+
+	p, err := NewProvider(Config{
+		IssuerURL:   m.issuer,
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+		HTTPClient:  m.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	for _, trailingSlash := range []bool{false, true} {
+		m := newMockIssuer(t, trailingSlash)
+		p := newTestProvider(t, m)
+
+		callback := login(t, p, "state-1", "nonce-1", "verifier-with-enough-entropy-0123456789")
+		if callback.Get("state") != "state-1" {
+			t.Fatalf("state %q came back", callback.Get("state"))
+		}
+
+		token, err := p.Exchange(context.Background(), callback.Get("code"), "verifier-with-enough-entropy-0123456789")
+		if err != nil {
+			t.Fatalf("trailing slash %v: exchange: %v", trailingSlash, err)
+		}
+
+		idToken, err := p.VerifyIDToken(context.Background(), token.IDToken, "nonce-1")
+		if err != nil {
+			t.Fatalf("trailing slash %v: verify: %v", trailingSlash, err)
+		}
+
+		if idToken.Issuer != m.issuer || idToken.Subject != "user-1" || idToken.Email != "jane@example.com" || !idToken.EmailVerified {
+			t.Fatalf("unexpected claims %+v", idToken)
+		}
+	}
+}
+
+func TestExchangeRejectsPKCEMismatch(t *testing.T) {
+	m := newMockIssuer(t, false)
+	p := newTestProvider(t, m)
+
+	callback := login(t, p, "state", "nonce", "the-verifier-sent-with-the-challenge")
+
+	_, err := p.Exchange(context.Background(), callback.Get("code"), "another-verifier")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("expected invalid_grant, got %v", err)
+	}
+}
+
+func TestVerifyIDTokenRejectsNonceMismatch(t *testing.T) {
+	m := newMockIssuer(t, false)
+	p := newTestProvider(t, m)
+
+	callback := login(t, p, "state", "nonce-sent", "verifier")
+
+	token, err := p.Exchange(context.Background(), callback.Get("code"), "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.VerifyIDToken(context.Background(), token.IDToken, "nonce-expected")
+	if !errors.Is(err, ErrInvalidNonce) {
+		t.Fatalf("expected ErrInvalidNonce, got %v", err)
+	}
+}
+
+func TestVerifyIDTokenRejectsBadClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(claims jwt.MapClaims)
+		want   error
+	}{
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, jwt.ErrTokenInvalidIssuer},
+		{"issuer without the discovered trailing slash", func(c jwt.MapClaims) { c["iss"] = strings.TrimRight(c["iss"].(string), "/") + "x" }, jwt.ErrTokenInvalidIssuer},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "someone-else" }, jwt.ErrTokenInvalidAudience},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, jwt.ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIssuer(t, true)
+			m.tamper = tt.tamper
+			p := newTestProvider(t, m)
+
+			callback := login(t, p, "state", "nonce", "verifier")
+
+			token, err := p.Exchange(context.Background(), callback.Get("code"), "verifier")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = p.VerifyIDToken(context.Background(), token.IDToken, "nonce")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
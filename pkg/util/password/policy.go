@@ -0,0 +1,136 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Character classes a policy can require
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassLetter = "letter"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+// MaxLength is the most bcrypt can hash; longer passwords are refused rather
+// than silently truncated
+const MaxLength = 72
+
+var classCheck = map[string]func(rune) bool{
+	ClassLower:  unicode.IsLower,
+	ClassUpper:  unicode.IsUpper,
+	ClassLetter: unicode.IsLetter,
+	ClassDigit:  unicode.IsDigit,
+	ClassSymbol: func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) || r == ' ' },
+}
+
+func IsValidClass(class string) bool {
+	_, ok := classCheck[class]
+	return ok
+}
+
+// commonPasswords is a small built-in denylist, extended by the configured file
+var commonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "12345678", "123456789",
+	"1234567890", "qwerty123", "qwertyuiop", "abc12345", "iloveyou1", "letmein1",
+	"welcome1", "admin123", "football1", "monkey123", "dragon123", "sunshine1",
+	"trustno1", "baseball1", "1q2w3e4r", "1qaz2wsx", "zaq12wsx", "changeme1",
+}
+
+// Policy describes what passwords are acceptable
+type Policy struct {
+	MinLength int
+
+	// Classes lists the character classes every password must contain
+	Classes []string
+
+	// Denylist holds lower-cased passwords that are refused outright
+	Denylist map[string]bool
+
+	// HistoryDepth is the number of most recent passwords, the current one
+	// included, that may not be reused
+	HistoryDepth int
+}
+
+// DefaultPolicy is the historical rule: 8 characters with a letter and a digit
+func DefaultPolicy() *Policy {
+	policy := &Policy{
+		MinLength:    8,
+		Classes:      []string{ClassLetter, ClassDigit},
+		Denylist:     make(map[string]bool),
+		HistoryDepth: 5,
+	}
+	policy.Deny(commonPasswords...)
+
+	return policy
+}
+
+// Deny adds passwords to the denylist
+func (p *Policy) Deny(passwords ...string) {
+	for _, password := range passwords {
+		password = strings.ToLower(strings.TrimSpace(password))
+		if len(password) > 0 {
+			p.Denylist[password] = true
+		}
+	}
+}
+
+// LoadDenylist adds every line of r to the denylist
+func (p *Policy) LoadDenylist(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		p.Deny(scanner.Text())
+	}
+
+	return scanner.Err()
+}
+
+// Validate returns the rules the password breaks, or nil when it is acceptable
+func (p *Policy) Validate(password string) []string {
+	violations := make([]string, 0)
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if len(password) > MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", MaxLength))
+	}
+
+	for _, class := range p.Classes {
+		if !containsClass(password, classCheck[class]) {
+			violations = append(violations, "must contain "+classNames[class])
+		}
+	}
+
+	if p.Denylist[strings.ToLower(password)] {
+		violations = append(violations, "is too common")
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violations
+}
+
+var classNames = map[string]string{
+	ClassLower:  "a lowercase letter",
+	ClassUpper:  "an uppercase letter",
+	ClassLetter: "a letter",
+	ClassDigit:  "a digit",
+	ClassSymbol: "a symbol",
+}
+
+func containsClass(password string, check func(rune) bool) bool {
+	for _, r := range password {
+		if check(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	strict := &Policy{
+		MinLength: 12,
+		Classes:   []string{ClassLower, ClassUpper, ClassDigit, ClassSymbol},
+		Denylist:  make(map[string]bool),
+	}
+	strict.Deny("Correct-Horse-1")
+
+	tests := []struct {
+		name     string
+		policy   *Policy
+		password string
+		want     []string
+	}{
+		{"default accepts letters and digits", DefaultPolicy(), "tr0ubadour", nil},
+		{"default too short", DefaultPolicy(), "abc123", []string{"must be at least 8 characters long"}},
+		{"default without digit", DefaultPolicy(), "troubadour", []string{"must contain a digit"}},
+		{"default without letter", DefaultPolicy(), "20240917", []string{"must contain a letter"}},
+		{"default denylisted", DefaultPolicy(), "password123", []string{"is too common"}},
+		{"denylist ignores case", DefaultPolicy(), "PassWord123", []string{"is too common"}},
+		{"length counts characters, not bytes", DefaultPolicy(), "ééééééé1", nil},
+		{"longer than bcrypt hashes", DefaultPolicy(), strings.Repeat("a1", 37), []string{"must be at most 72 bytes long"}},
+		{"empty breaks every rule", DefaultPolicy(), "", []string{"must be at least 8 characters long", "must contain a letter", "must contain a digit"}},
+		{"strict accepts all classes", strict, "Tr0ub4dour&3x", nil},
+		{"strict without upper and symbol", strict, "tr0ub4dour3x", []string{"must contain an uppercase letter", "must contain a symbol"}},
+		{"space is a symbol", strict, "Tr0ub4dour 3x", nil},
+		{"strict denylisted", strict, "correct-horse-1", []string{"must contain an uppercase letter", "is too common"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Validate(tt.password)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Validate(%q) = %q, want %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyLoadDenylist(t *testing.T) {
+	policy := &Policy{Denylist: make(map[string]bool)}
+
+	err := policy.LoadDenylist(strings.NewReader("Hunter2\n\n  letmein  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(policy.Denylist) != 2 || !policy.Denylist["hunter2"] || !policy.Denylist["letmein"] {
+		t.Fatalf("unexpected denylist %v", policy.Denylist)
+	}
+}
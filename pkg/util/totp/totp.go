@@ -0,0 +1,106 @@
+// Package totp implements time-based one-time passwords (RFC 6238) with the
+// parameters authenticator apps support universally: HMAC-SHA1, 6 digits and a
+// 30 second period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+
+	// Skew is the number of periods a code may be early or late, to allow for
+	// clock drift between server and phone
+	Skew = 1
+
+	secretSize = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random base32 encoded secret
+func GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(buf), nil
+}
+
+// Step returns the time step t falls into
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code computes the code of secret for a time step (RFC 4226 section 5.3)
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// Validate checks code against the steps around t and returns the step that
+// matched. Callers should refuse steps at or before the last one accepted, so a
+// code cannot be replayed.
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := Step(t)
+	for step := current - Skew; step <= current+Skew; step++ {
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI returns the otpauth:// provisioning URI that authenticator apps read from a
+// QR code
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
@@ -0,0 +1,141 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to the last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	lower, err := Code(strings.ToLower(rfcSecret), 1)
+	if err != nil {
+		t.Fatalf("lower-case secret: %v", err)
+	}
+
+	upper, _ := Code(rfcSecret, 1)
+	if lower != upper {
+		t.Fatal("secrets should not be case sensitive")
+	}
+
+	_, err = Code("not base32!", 1)
+	if err == nil {
+		t.Fatal("expected an invalid secret to fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	code := func(step int64) string {
+		c, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(current), current, true},
+		{"one step late", code(current - 1), current - 1, true},
+		{"one step early", code(current + 1), current + 1, true},
+		{"two steps late", code(current - 2), 0, false},
+		{"two steps early", code(current + 2), 0, false},
+		{"spaces are ignored", code(current)[:3] + " " + code(current)[3:], current, true},
+		{"surrounding whitespace is ignored", " " + code(current) + "\n", current, true},
+		{"too short", code(current)[:5], 0, false},
+		{"too long", code(current) + "0", 0, false},
+		{"wrong code", "000000", 0, false},
+		{"empty", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Fatalf("Validate(%q) = %d, %v, want %d, %v", tt.code, step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+// Callers refuse steps at or before the last accepted one. That only works if
+// Validate reports the step a code belongs to rather than the current one.
+func TestValidateReportsStepOfCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	previous, _ := Code(rfcSecret, current-1)
+
+	// The same code seen again a period later still names its own step, which
+	// is at or before the one recorded when it was first used
+	first, ok := Validate(rfcSecret, previous, now)
+	if !ok {
+		t.Fatal("expected the previous code to be valid within the skew")
+	}
+
+	again, ok := Validate(rfcSecret, previous, now.Add(Period/2))
+	if !ok || again != first {
+		t.Fatalf("replayed code matched step %d, %v, want %d", again, ok, first)
+	}
+
+	// Once its step leaves the window the code is refused outright
+	_, ok = Validate(rfcSecret, previous, now.Add(2*Period))
+	if ok {
+		t.Fatal("expected an expired code to be refused")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := GenerateSecret()
+	if secret == other {
+		t.Fatal("expected random secrets")
+	}
+
+	code, err := Code(secret, Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := Validate(secret, code, time.Now())
+	if !ok {
+		t.Fatal("expected a code of a generated secret to validate")
+	}
+}